@@ -7,6 +7,10 @@ import (
 
 type ChatDataResult httpsrv.ResultAnsw
 
+type MessageDataResult httpsrv.ResultAnsw
+
+type ChatReadDataResult httpsrv.ResultAnsw
+
 type ThemeDataResult httpsrv.ResultAnsw
 
 type DirectionsDataResult httpsrv.ResultAnsw
@@ -17,4 +21,16 @@ type ArrayOfThemesData []models.Theme
 
 type ArrayThemeDataResult httpsrv.ResultAnsw
 
+type ActiveThemesDataResult httpsrv.ResultAnsw
+
 type ArrayOfDirectionDetailedData []models.DirectionDetailed
+
+type TokenDataResult httpsrv.ResultAnsw
+
+type DirectionDataResult httpsrv.ResultAnsw
+
+type AttachmentsDataResult httpsrv.ResultAnsw
+
+type ArrayOfAttachmentData []models.Attachment
+
+type ThemePresenceDataResult httpsrv.ResultAnsw
@@ -1,23 +1,36 @@
 package centrifugov1
 
 import (
+	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
+	"mime"
 	"net/http"
+	"net/url"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+	moderationv1 "github.com/sqsinformatique/rosseti-innovation-back/domains/moderation/v1"
+	sessionv1 "github.com/sqsinformatique/rosseti-innovation-back/domains/session/v1"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/centrifugo"
 	echoSwagger "github.com/sqsinformatique/rosseti-innovation-back/internal/echo-swagger"
 	"github.com/sqsinformatique/rosseti-innovation-back/internal/httpsrv"
 	"github.com/sqsinformatique/rosseti-innovation-back/internal/logger"
 	"github.com/sqsinformatique/rosseti-innovation-back/models"
+	"github.com/sqsinformatique/rosseti-innovation-back/types"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 var (
 	ErrBadAuthRequest = errors.New("bad authorization request")
+	ErrUnauthorized   = errors.New("current user is not authorized")
+	ErrBadRequestID   = errors.New("id in request path must be a number")
 )
 
 // ExtractToken extract token from request
@@ -168,17 +181,346 @@ func (c *CentrifugoV1) AuthConnectHandler(ec echo.Context) (err error) {
 
 	hndlLog.Debug().Msgf("good session for userID %d", session.UserID)
 
+	_, err = c.proxyUser(strconv.Itoa(session.UserID))
+	if err != nil {
+		hndlLog.Err(err).Msgf("USER %d IS NOT ALLOWED TO CONNECT", session.UserID)
+
+		return ec.JSON(
+			http.StatusForbidden,
+			httpsrv.Forbidden(err),
+		)
+	}
+
 	return ec.JSON(
 		http.StatusOK,
 		models.CentrifugoIntrospectionResult{
 			Result: &models.CentrifugoIntrospection{
-				User: strconv.Itoa(session.UserID),
+				User:     strconv.Itoa(session.UserID),
+				ExpireAt: c.sessionV1.ExpiresAt(session).Unix(),
 				// Data: introspection,
 			},
 		},
 	)
 }
 
+// RefreshProxyHandler extends expired connection till the latest session of
+// its user expires. Connection of deleted user or user without valid
+// sessions is closed.
+func (c *CentrifugoV1) RefreshProxyHandler(ec echo.Context) (err error) {
+	// Swagger
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("refreshProxyHandler").
+			SetSummary("Refresh of expired centrifugo connection").
+			AddInBodyParameter("refresh", "Centrifugo refresh proxy request", &models.CentrifugoRefreshRequest{}, true).
+			AddResponse(http.StatusOK, "Result or error", &models.CentrifugoProxyReply{Result: &models.CentrifugoRefreshResult{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&c.log, ec)
+
+	var request models.CentrifugoRefreshRequest
+
+	err = ec.Bind(&request)
+	if err != nil {
+		hndlLog.Err(err).Msg("BAD REQUEST")
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	result := &models.CentrifugoRefreshResult{}
+
+	user, err := c.proxyUser(request.User)
+	if err == nil {
+		var expiresAt time.Time
+
+		expiresAt, err = c.sessionV1.UserSessionExpiresAt(user.ID)
+		result.ExpireAt = expiresAt.Unix()
+	}
+
+	if errors.Is(err, ErrProxyUnauthorized) || errors.Is(err, sessionv1.ErrSessionExpired) {
+		hndlLog.Debug().Msgf("connection of user %s expired", request.User)
+
+		result, err = &models.CentrifugoRefreshResult{Expired: true}, nil
+	}
+
+	if err != nil {
+		hndlLog.Err(err).Msgf("REFRESH FAILED, user %s", request.User)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		proxyReply(result, err),
+	)
+}
+
+// tokenExpireAt returns expiration of token issued to user, token doesn't
+// outlive session of the request. The session is the one user is
+// authenticated by, other sessions of user don't prolong the token.
+func (c *CentrifugoV1) tokenExpireAt(ec echo.Context, user *models.User) (int64, error) {
+	ttl, err := time.ParseDuration(c.config.Centrifugo.TokenTTL)
+	if err != nil {
+		return 0, err
+	}
+
+	idCookie, err := ec.Cookie("rosseti-session")
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrUnauthorized, err)
+	}
+
+	session, err := c.sessionV1.GetSession(idCookie.Value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("%w: %v", ErrUnauthorized, err)
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	if session.UserID != user.ID {
+		return 0, ErrUnauthorized
+	}
+
+	expiresAt := c.sessionV1.ExpiresAt(session)
+	if limit := time.Now().Add(ttl); limit.Before(expiresAt) {
+		expiresAt = limit
+	}
+
+	return expiresAt.Unix(), nil
+}
+
+// connInfo returns info attached to connection or subscription of user
+func (c *CentrifugoV1) connInfo(user *models.User) (*models.CentrifugoConnInfo, error) {
+	cards, err := c.profileCards([]int{user.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	info := &models.CentrifugoConnInfo{Role: user.Role.String()}
+	if card, ok := cards[user.ID]; ok {
+		info.Name, info.Position = card.Name, card.Position
+	}
+
+	return info, nil
+}
+
+func tokenErrorAnswer(err error) (int, httpsrv.ErrorAnsw) {
+	var denied *models.CentrifugoError
+
+	switch {
+	case errors.Is(err, centrifugo.ErrNoTokenSecret):
+		return http.StatusPreconditionFailed, httpsrv.PreconditionFailed(err)
+	case errors.Is(err, sessionv1.ErrSessionExpired), errors.Is(err, ErrUnauthorized):
+		return http.StatusUnauthorized, httpsrv.Unauthorized(err)
+	case errors.As(err, &denied):
+		return http.StatusForbidden, httpsrv.Forbidden(err)
+	}
+
+	return http.StatusInternalServerError, httpsrv.InternalServerError(err)
+}
+
+// ConnectionTokenHandler issues token to connect to centrifugo without
+// connect proxy
+func (c *CentrifugoV1) ConnectionTokenHandler(ec echo.Context) (err error) {
+	// Swagger
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("connectionTokenHandler").
+			SetSummary("Get centrifugo connection token").
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &TokenDataResult{Body: &models.CentrifugoToken{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&c.log, ec)
+
+	user, err := c.userV1.CurrentUser(ec)
+	if err != nil {
+		hndlLog.Err(err).Msg("GET CURRENT USER FAILED")
+
+		return ec.JSON(
+			http.StatusUnauthorized,
+			httpsrv.Unauthorized(err),
+		)
+	}
+
+	expireAt, err := c.tokenExpireAt(ec, user)
+	if err != nil {
+		hndlLog.Err(err).Msgf("GET TOKEN EXPIRATION FAILED, user %d", user.ID)
+
+		return ec.JSON(tokenErrorAnswer(err))
+	}
+
+	info, err := c.connInfo(user)
+	if err != nil {
+		hndlLog.Err(err).Msgf("GET CONNECTION INFO FAILED, user %d", user.ID)
+
+		return ec.JSON(tokenErrorAnswer(err))
+	}
+
+	token, err := centrifugo.ConnectionToken(c.config.Centrifugo.TokenHMACSecretKey, strconv.Itoa(user.ID), expireAt, info)
+	if err != nil {
+		hndlLog.Err(err).Msgf("SIGN CONNECTION TOKEN FAILED, user %d", user.ID)
+
+		return ec.JSON(tokenErrorAnswer(err))
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		TokenDataResult{Body: &models.CentrifugoToken{Token: token, ExpireAt: expireAt}},
+	)
+}
+
+// SubscriptionTokenHandler issues tokens to subscribe client connection to
+// private channels, reply is not wrapped to be read by centrifuge-js
+func (c *CentrifugoV1) SubscriptionTokenHandler(ec echo.Context) (err error) {
+	// Swagger
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("subscriptionTokenHandler").
+			SetSummary("Get centrifugo private channel subscription tokens").
+			AddInBodyParameter("subscribe", "Client id and private channels", &models.CentrifugoSubscriptionTokenRequest{}, true).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &models.CentrifugoSubscriptionTokens{})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&c.log, ec)
+
+	var request models.CentrifugoSubscriptionTokenRequest
+
+	err = ec.Bind(&request)
+	if err != nil || request.Client == "" {
+		if err == nil {
+			err = ErrBadAuthRequest
+		}
+
+		hndlLog.Err(err).Msg("BAD REQUEST")
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	user, err := c.userV1.CurrentUser(ec)
+	if err != nil {
+		hndlLog.Err(err).Msg("GET CURRENT USER FAILED")
+
+		return ec.JSON(
+			http.StatusUnauthorized,
+			httpsrv.Unauthorized(err),
+		)
+	}
+
+	expireAt, err := c.tokenExpireAt(ec, user)
+	if err != nil {
+		hndlLog.Err(err).Msgf("GET TOKEN EXPIRATION FAILED, user %d", user.ID)
+
+		return ec.JSON(tokenErrorAnswer(err))
+	}
+
+	info, err := c.connInfo(user)
+	if err != nil {
+		hndlLog.Err(err).Msgf("GET CONNECTION INFO FAILED, user %d", user.ID)
+
+		return ec.JSON(tokenErrorAnswer(err))
+	}
+
+	tokens := &models.CentrifugoSubscriptionTokens{Channels: []*models.CentrifugoToken{}}
+
+	for _, channel := range request.Channels {
+		err = c.AuthorizeChannel(user, channel)
+		if err != nil {
+			hndlLog.Err(err).Msgf("SUBSCRIPTION TO %s NOT AUTHORIZED, user %d", channel, user.ID)
+
+			return ec.JSON(tokenErrorAnswer(err))
+		}
+
+		token, err := centrifugo.SubscriptionToken(c.config.Centrifugo.TokenHMACSecretKey, request.Client, channel, expireAt, info)
+		if err != nil {
+			hndlLog.Err(err).Msgf("SIGN SUBSCRIPTION TOKEN FAILED, user %d channel %s", user.ID, channel)
+
+			return ec.JSON(tokenErrorAnswer(err))
+		}
+
+		tokens.Channels = append(tokens.Channels, &models.CentrifugoToken{Channel: channel, Token: token, ExpireAt: expireAt})
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		tokens,
+	)
+}
+
+// SubscribeProxyHandler authorizes subscription to channel, centrifugo
+// expects HTTP 200 with either result or error in body
+func (c *CentrifugoV1) SubscribeProxyHandler(ec echo.Context) (err error) {
+	// Swagger
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("subscribeProxyHandler").
+			SetSummary("Authorization for subscribe to centrifugo channel").
+			AddInBodyParameter("subscribe", "Centrifugo subscribe proxy request", &models.CentrifugoSubscribeRequest{}, true).
+			AddResponse(http.StatusOK, "Result or error", &models.CentrifugoProxyReply{})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&c.log, ec)
+
+	var request models.CentrifugoSubscribeRequest
+
+	err = ec.Bind(&request)
+	if err != nil {
+		hndlLog.Err(err).Msg("BAD REQUEST")
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	user, err := c.proxyUser(request.User)
+	if err == nil {
+		err = c.AuthorizeChannel(user, request.Channel)
+	}
+
+	result := &models.CentrifugoSubscribeResult{}
+
+	// Channel info is shown in presence and join/leave events
+	if err == nil && hasPresence(request.Channel) {
+		info, infoErr := c.connInfo(user)
+		if infoErr != nil {
+			hndlLog.Warn().Err(infoErr).Msgf("GET CHANNEL INFO FAILED, user %s", request.User)
+		} else {
+			result.Info = info
+		}
+	}
+
+	reply := proxyReply(result, err)
+	if reply.Error == ErrProxyInternal {
+		hndlLog.Err(err).Msgf("SUBSCRIBE FAILED, user %s channel %s", request.User, request.Channel)
+	} else if reply.Error != nil {
+		hndlLog.Debug().Msgf("subscribe denied, user %s channel %s: %s", request.User, request.Channel, reply.Error)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		reply,
+	)
+}
+
 func (c *CentrifugoV1) PublishHandler(ec echo.Context) (err error) {
 	// Swagger
 	if echoSwagger.IsBuildingSwagger(ec) {
@@ -229,19 +571,23 @@ func (c *CentrifugoV1) PublishHandler(ec echo.Context) (err error) {
 		)
 	}
 
-	err = c.Publish(&pub, session.UserID)
-	if err != nil {
-		hndlLog.Err(err).Msg("BAD REQUEST")
+	user, err := c.proxyUser(strconv.Itoa(session.UserID))
+	if err == nil {
+		err = c.AuthorizeChannel(user, pub.Channel)
+	}
+
+	var denied *models.CentrifugoError
+	if errors.As(err, &denied) {
+		hndlLog.Err(err).Msgf("PUBLISH FORBIDDEN %s", pub.Channel)
 
 		return ec.JSON(
-			http.StatusInternalServerError,
-			httpsrv.InternalServerError(err),
+			http.StatusForbidden,
+			httpsrv.Forbidden(err),
 		)
 	}
 
-	err = c.AddLastActive(&pub)
 	if err != nil {
-		hndlLog.Err(err).Msg("FAILED ADD TO LAST ACTIVE")
+		hndlLog.Err(err).Msgf("AUTHORIZE CHANNEL FAILED %s", pub.Channel)
 
 		return ec.JSON(
 			http.StatusInternalServerError,
@@ -249,6 +595,13 @@ func (c *CentrifugoV1) PublishHandler(ec echo.Context) (err error) {
 		)
 	}
 
+	err = c.Publish(&pub, user)
+	if err != nil {
+		hndlLog.Err(err).Msg("PUBLISH FAILED")
+
+		return ec.JSON(messageErrorAnswer(err))
+	}
+
 	return ec.JSON(
 		http.StatusOK,
 		httpsrv.OkResult(),
@@ -262,8 +615,11 @@ func (c *CentrifugoV1) GetHistoryHandler(ec echo.Context) error {
 			SetDescription("getHistoryHandler").
 			SetSummary("Get chat history").
 			AddInPathParameter("id", "Chat id", reflect.Int64).
+			AddInQueryParameter("before", "Return messages older than this message id", reflect.Int64, false).
+			AddInQueryParameter("after", "Return messages newer than this message id", reflect.Int64, false).
+			AddInQueryParameter("limit", "Max count of messages", reflect.Int64, false).
 			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
-			AddResponse(http.StatusOK, "OK", &ChatDataResult{Body: &models.ChatChannel{}})
+			AddResponse(http.StatusOK, "OK", &ChatDataResult{Body: &models.ChatHistory{}})
 		return nil
 	}
 
@@ -279,7 +635,67 @@ func (c *CentrifugoV1) GetHistoryHandler(ec echo.Context) error {
 		)
 	}
 
-	chatData, err := c.GetChat(int(chatID))
+	params := map[string]int{"before": 0, "after": 0, "limit": historyLimit}
+	for name := range params {
+		if ec.QueryParam(name) == "" {
+			continue
+		}
+
+		params[name], err = strconv.Atoi(ec.QueryParam(name))
+		if err != nil {
+			hndlLog.Err(err).Msgf("BAD REQUEST, %s %s", name, ec.QueryParam(name))
+
+			return ec.JSON(
+				http.StatusBadRequest,
+				httpsrv.BadRequest(err),
+			)
+		}
+	}
+
+	user, err := c.userV1.CurrentUser(ec)
+	if err != nil {
+		hndlLog.Err(err).Msg("GET CURRENT USER FAILED")
+
+		return ec.JSON(
+			http.StatusUnauthorized,
+			httpsrv.Unauthorized(err),
+		)
+	}
+
+	chat, err := c.GetChat(int(chatID))
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		hndlLog.Err(err).Msgf("CHAT NOT FOUND, id %d", chatID)
+
+		return ec.JSON(
+			http.StatusNotFound,
+			httpsrv.NotFound(err),
+		)
+	}
+
+	if err == nil {
+		err = c.AuthorizeChannel(user, chatChannel(chat))
+	}
+
+	var denied *models.CentrifugoError
+	if errors.As(err, &denied) {
+		hndlLog.Err(err).Msgf("GET HISTORY FORBIDDEN, id %d", chatID)
+
+		return ec.JSON(
+			http.StatusForbidden,
+			httpsrv.Forbidden(err),
+		)
+	}
+
+	if err != nil {
+		hndlLog.Err(err).Msgf("AUTHORIZE CHAT FAILED, id %d", chatID)
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	chatData, err := c.GetChatHistory(int(chatID), params["before"], params["after"], params["limit"])
 	if err != nil {
 		hndlLog.Err(err).Msgf("Failed get chat, id %s", ec.Param("id"))
 
@@ -321,6 +737,19 @@ func (c *CentrifugoV1) CreateThemeHandler(ec echo.Context) (err error) {
 		)
 	}
 
+	user, err := c.userV1.CurrentUser(ec)
+	if err != nil {
+		hndlLog.Err(err).Msg("GET CURRENT USER FAILED")
+
+		return ec.JSON(
+			http.StatusUnauthorized,
+			httpsrv.Unauthorized(err),
+		)
+	}
+
+	// Author gets management rights on theme, so it's never taken from body
+	theme.AuthorID = user.ID
+
 	themeData, err := c.CreateTheme(&theme)
 	if err != nil {
 		hndlLog.Err(err).Msgf("CREATE THEME FAILED %+v", &theme)
@@ -331,6 +760,11 @@ func (c *CentrifugoV1) CreateThemeHandler(ec echo.Context) (err error) {
 		)
 	}
 
+	err = c.searchV1.IndexTheme(themeData)
+	if err != nil {
+		hndlLog.Warn().Err(err).Msgf("INDEX THEME FAILED, id %d", themeData.ID)
+	}
+
 	return ec.JSON(
 		http.StatusOK,
 		ThemeDataResult{Body: themeData},
@@ -343,6 +777,7 @@ func (c *CentrifugoV1) GetDirectionsHandler(ec echo.Context) (err error) {
 			SetProduces("application/json").
 			SetDescription("GetDirectionsHandler").
 			SetSummary("Get directions").
+			AddInQueryParameter("archived", "Return archived directions too", reflect.Bool, false).
 			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
 			AddResponse(http.StatusOK, "OK", &DirectionsDataResult{Body: &ArrayOfDirectionData{}})
 		return nil
@@ -351,7 +786,20 @@ func (c *CentrifugoV1) GetDirectionsHandler(ec echo.Context) (err error) {
 	// Main code of handler
 	hndlLog := logger.HandlerLogger(&c.log, ec)
 
-	directionsData, err := c.SelectAllDirections()
+	includeArchived := false
+	if ec.QueryParam("archived") != "" {
+		includeArchived, err = strconv.ParseBool(ec.QueryParam("archived"))
+		if err != nil {
+			hndlLog.Err(err).Msgf("BAD REQUEST, archived %s", ec.QueryParam("archived"))
+
+			return ec.JSON(
+				http.StatusBadRequest,
+				httpsrv.BadRequest(err),
+			)
+		}
+	}
+
+	directionsData, err := c.SelectAllDirections(includeArchived)
 	if err != nil {
 		hndlLog.Err(err).Msg("SELECT ALL DIRECTIONS FAILED")
 
@@ -381,7 +829,7 @@ func (c *CentrifugoV1) GetDirectionsDetailedHandler(ec echo.Context) (err error)
 	// Main code of handler
 	hndlLog := logger.HandlerLogger(&c.log, ec)
 
-	directionsData, err := c.SelectAllDirections()
+	directionsData, err := c.SelectAllDirections(false)
 	if err != nil {
 		hndlLog.Err(err).Msg("SELECT ALL DIRECTIONS FAILED")
 
@@ -410,7 +858,16 @@ func (c *CentrifugoV1) GetDirectionsDetailedHandler(ec echo.Context) (err error)
 		detailed = append(detailed, item)
 	}
 
-	return ec.JSON(
+	themes := []*models.Theme{}
+	for i := range detailed {
+		for j := range detailed[i].Themes {
+			themes = append(themes, &detailed[i].Themes[j])
+		}
+	}
+
+	c.markThemes(ec, themes)
+
+	return ec.JSON(
 		http.StatusOK,
 		DirectionsDataResult{Body: detailed},
 	)
@@ -421,47 +878,160 @@ func (c *CentrifugoV1) GetLastActiveThemes(ec echo.Context) (err error) {
 		echoSwagger.AddToSwagger(ec).
 			SetProduces("application/json").
 			SetDescription("GetLastActiveThemes").
-			SetSummary("Get last active themes").
+			SetSummary("Get themes ordered by the last message").
+			AddInQueryParameter("direction", "Direction id", reflect.Int64, false).
+			AddInQueryParameter("limit", "Max count of themes", reflect.Int64, false).
+			AddInQueryParameter("offset", "Count of skipped themes", reflect.Int64, false).
 			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
-			AddResponse(http.StatusOK, "OK", &ArrayThemeDataResult{Body: &ArrayOfThemesData{}})
+			AddResponse(http.StatusOK, "OK", &ActiveThemesDataResult{Body: &models.ActiveThemes{}})
 		return nil
 	}
 
 	// Main code of handler
 	hndlLog := logger.HandlerLogger(&c.log, ec)
 
-	themesData, err := c.SelectLastActiveThemes()
+	params := map[string]int{"direction": 0, "limit": activeThemesLimit, "offset": 0}
+	for name := range params {
+		if ec.QueryParam(name) == "" {
+			continue
+		}
+
+		params[name], err = strconv.Atoi(ec.QueryParam(name))
+		if err != nil {
+			hndlLog.Err(err).Msgf("BAD REQUEST, %s %s", name, ec.QueryParam(name))
+
+			return ec.JSON(
+				http.StatusBadRequest,
+				httpsrv.BadRequest(err),
+			)
+		}
+	}
+
+	themesData, err := c.SelectLastActiveThemes(params["direction"], params["limit"], params["offset"])
 	if err != nil {
-		hndlLog.Err(err).Msgf("SELECT LAST ACTIVE THEMES FAILED, direction")
+		hndlLog.Err(err).Msgf("SELECT LAST ACTIVE THEMES FAILED, direction %d", params["direction"])
 
 		return ec.JSON(
-			http.StatusConflict,
-			httpsrv.CreateFailed(err),
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
 		)
 	}
 
+	themes := make([]*models.Theme, 0, len(themesData.Themes))
+	for _, theme := range themesData.Themes {
+		themes = append(themes, &theme.Theme)
+	}
+
+	c.markThemes(ec, themes)
+
 	return ec.JSON(
 		http.StatusOK,
-		ArrayThemeDataResult{Body: themesData},
+		ActiveThemesDataResult{Body: themesData},
 	)
 }
 
-func (c *CentrifugoV1) PutLikeThemes(ec echo.Context) (err error) {
+// markThemes sets like state and unread count of themes for current user,
+// themes stay unmarked for anonymous request or on failure
+func (c *CentrifugoV1) markThemes(ec echo.Context, themes []*models.Theme) {
+	c.markOnline(themes)
+
+	user, err := c.userV1.CurrentUser(ec)
+	if err != nil {
+		return
+	}
+
+	err = c.likeV1.MarkThemes(user.ID, themes)
+	if err != nil {
+		c.log.Warn().Err(err).Msgf("mark liked themes failed, user %d", user.ID)
+	}
+
+	ids := make([]int, 0, len(themes))
+	for _, theme := range themes {
+		ids = append(ids, theme.ID)
+	}
+
+	unread, err := c.UnreadCounts(user.ID, ids)
+	if err != nil {
+		c.log.Warn().Err(err).Msgf("count unread messages failed, user %d", user.ID)
+		return
+	}
+
+	for _, theme := range themes {
+		theme.Unread = unread[theme.ID]
+	}
+}
+
+// messageErrorAnswer maps error of message change to HTTP answer
+func messageErrorAnswer(err error) (int, httpsrv.ErrorAnsw) {
+	var denied *models.CentrifugoError
+
+	switch {
+	case errors.Is(err, ErrEmptyMessage), errors.Is(err, ErrBadReplyTo), errors.Is(err, ErrBadReaction),
+		errors.Is(err, moderationv1.ErrStopWord), errors.Is(err, ErrBadAttachment), errors.Is(err, ErrAttachmentTooLarge),
+		errors.Is(err, ErrNoAttachments), errors.Is(err, ErrBadRequestID):
+		return http.StatusBadRequest, httpsrv.BadRequest(err)
+	case errors.Is(err, ErrUnauthorized):
+		return http.StatusUnauthorized, httpsrv.Unauthorized(err)
+	case errors.Is(err, ErrNotMessageSender), errors.As(err, &denied), errors.Is(err, moderationv1.ErrThemeLocked),
+		errors.Is(err, ErrNotChatParticipant),
+		errors.Is(err, moderationv1.ErrUserMuted), errors.Is(err, moderationv1.ErrUserBanned),
+		errors.Is(err, ErrThemeClosed), errors.Is(err, ErrThemeArchived):
+		return http.StatusForbidden, httpsrv.Forbidden(err)
+	case errors.Is(err, ErrMessageNotFound), errors.Is(err, ErrChatNotFound), errors.Is(err, ErrThemeNotFound),
+		errors.Is(err, ErrAttachmentNotFound):
+		return http.StatusNotFound, httpsrv.NotFound(err)
+	case errors.Is(err, ErrMessageDeleted), errors.Is(err, ErrMessageChanged), errors.Is(err, ErrMessageHidden):
+		return http.StatusConflict, httpsrv.NotUpdated(err)
+	}
+
+	return http.StatusInternalServerError, httpsrv.InternalServerError(err)
+}
+
+// messageRequest returns chat and message of request path and current
+// user, who must have access to the chat channel
+func (c *CentrifugoV1) messageRequest(ec echo.Context) (chatID, msgID int, user *models.User, err error) {
+	msgID, err = strconv.Atoi(ec.Param("msgid"))
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("%w: %v", ErrBadRequestID, err)
+	}
+
+	chatID, user, err = c.chatRequest(ec)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	return chatID, msgID, user, nil
+}
+
+func (c *CentrifugoV1) EditMessageHandler(ec echo.Context) (err error) {
 	if echoSwagger.IsBuildingSwagger(ec) {
 		echoSwagger.AddToSwagger(ec).
 			SetProduces("application/json").
-			SetDescription("PutLikeThemes").
-			SetSummary("Like themes").
-			AddInPathParameter("id", "Theme id", reflect.Int64).
-			AddResponse(http.StatusOK, "OK", httpsrv.OkResult())
+			SetDescription("EditMessageHandler").
+			SetSummary("Edit own chat message").
+			AddInBodyParameter("message", "New message text", &models.MessageUpdate{}, true).
+			AddInPathParameter("id", "Chat id", reflect.Int64).
+			AddInPathParameter("msgid", "Message id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &MessageDataResult{Body: &models.Message{}})
 		return nil
 	}
 
 	// Main code of handler
 	hndlLog := logger.HandlerLogger(&c.log, ec)
-	themeID, err := strconv.ParseInt(ec.Param("id"), 10, 64)
+
+	chatID, msgID, user, err := c.messageRequest(ec)
 	if err != nil {
-		hndlLog.Err(err).Msgf("BAD REQUEST, id %s", ec.Param("id"))
+		hndlLog.Err(err).Msgf("BAD REQUEST, chat %s message %s", ec.Param("id"), ec.Param("msgid"))
+
+		return ec.JSON(messageErrorAnswer(err))
+	}
+
+	var update models.MessageUpdate
+
+	err = ec.Bind(&update)
+	if err != nil {
+		hndlLog.Err(err).Msg("BAD REQUEST")
 
 		return ec.JSON(
 			http.StatusBadRequest,
@@ -469,9 +1039,179 @@ func (c *CentrifugoV1) PutLikeThemes(ec echo.Context) (err error) {
 		)
 	}
 
-	err = c.LikeTheme(themeID)
+	chat, err := c.GetChat(chatID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		err = ErrChatNotFound
+	}
+
+	if err != nil {
+		hndlLog.Err(err).Msgf("GET CHAT FAILED, chat %d", chatID)
+
+		return ec.JSON(messageErrorAnswer(err))
+	}
+
+	text, err := c.moderateMessage(chatID, chat.Type, user, update.Message)
+	if err != nil {
+		hndlLog.Err(err).Msgf("MESSAGE REJECTED, chat %d message %d", chatID, msgID)
+
+		return ec.JSON(messageErrorAnswer(err))
+	}
+
+	msg, mentioned, err := c.EditMessage(chatID, msgID, user.ID, text)
+	if err != nil {
+		hndlLog.Err(err).Msgf("EDIT MESSAGE FAILED, chat %d message %d", chatID, msgID)
+
+		return ec.JSON(messageErrorAnswer(err))
+	}
+
+	err = c.PublishChange(models.ChatEventEdited, msg)
+	if err != nil {
+		hndlLog.Err(err).Msgf("PUBLISH EDITED MESSAGE FAILED, chat %d message %d", chatID, msgID)
+	}
+
+	c.notifyMentions(chat.Type, msg, mentioned)
+
+	return ec.JSON(
+		http.StatusOK,
+		MessageDataResult{Body: msg},
+	)
+}
+
+func (c *CentrifugoV1) DeleteMessageHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("DeleteMessageHandler").
+			SetSummary("Delete chat message, message is kept as a tombstone").
+			AddInPathParameter("id", "Chat id", reflect.Int64).
+			AddInPathParameter("msgid", "Message id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &MessageDataResult{Body: &models.Message{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&c.log, ec)
+
+	chatID, msgID, user, err := c.messageRequest(ec)
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, chat %s message %s", ec.Param("id"), ec.Param("msgid"))
+
+		return ec.JSON(messageErrorAnswer(err))
+	}
+
+	original, err := c.GetMessage(chatID, msgID)
+	if err != nil {
+		hndlLog.Err(err).Msgf("GET MESSAGE FAILED, chat %d message %d", chatID, msgID)
+
+		return ec.JSON(messageErrorAnswer(err))
+	}
+
+	msg, err := c.DeleteMessage(chatID, msgID, user)
+	if err != nil {
+		hndlLog.Err(err).Msgf("DELETE MESSAGE FAILED, chat %d message %d", chatID, msgID)
+
+		return ec.JSON(messageErrorAnswer(err))
+	}
+
+	if original.Sender != user.ID {
+		c.moderationV1.Log(user.ID, models.ModerationDeleteMessage, chatID, original.Sender, msgID,
+			map[string]interface{}{"text": original.Text})
+	}
+
+	err = c.PublishChange(models.ChatEventDeleted, msg)
+	if err != nil {
+		hndlLog.Err(err).Msgf("PUBLISH DELETED MESSAGE FAILED, chat %d message %d", chatID, msgID)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		MessageDataResult{Body: msg},
+	)
+}
+
+func (c *CentrifugoV1) hideHandler(ec echo.Context, hidden bool) (err error) {
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&c.log, ec)
+
+	chatID, msgID, user, err := c.messageRequest(ec)
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, chat %s message %s", ec.Param("id"), ec.Param("msgid"))
+
+		return ec.JSON(messageErrorAnswer(err))
+	}
+
+	msg, err := c.HideMessage(chatID, msgID, user.ID, hidden)
+	if err != nil {
+		hndlLog.Err(err).Msgf("HIDE MESSAGE FAILED, chat %d message %d hidden %t", chatID, msgID, hidden)
+
+		return ec.JSON(messageErrorAnswer(err))
+	}
+
+	event, action := models.ChatEventHidden, models.ModerationHideMessage
+	if !hidden {
+		event, action = models.ChatEventUnhidden, models.ModerationUnhideMessage
+	}
+
+	c.moderationV1.Log(user.ID, action, chatID, msg.Sender, msgID, map[string]interface{}{"text": msg.Text})
+
+	err = c.PublishChange(event, msg)
+	if err != nil {
+		hndlLog.Err(err).Msgf("PUBLISH %s MESSAGE FAILED, chat %d message %d", strings.ToUpper(event), chatID, msgID)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		MessageDataResult{Body: msg},
+	)
+}
+
+func (c *CentrifugoV1) HideMessageHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("HideMessageHandler").
+			SetSummary("Hide chat message from users").
+			AddInPathParameter("id", "Chat id", reflect.Int64).
+			AddInPathParameter("msgid", "Message id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &MessageDataResult{Body: &models.Message{}})
+		return nil
+	}
+
+	return c.hideHandler(ec, true)
+}
+
+func (c *CentrifugoV1) UnhideMessageHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("UnhideMessageHandler").
+			SetSummary("Show hidden chat message again").
+			AddInPathParameter("id", "Chat id", reflect.Int64).
+			AddInPathParameter("msgid", "Message id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &MessageDataResult{Body: &models.Message{}})
+		return nil
+	}
+
+	return c.hideHandler(ec, false)
+}
+
+func (c *CentrifugoV1) reactionHandler(ec echo.Context, add bool) (err error) {
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&c.log, ec)
+
+	chatID, msgID, user, err := c.messageRequest(ec)
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, chat %s message %s", ec.Param("id"), ec.Param("msgid"))
+
+		return ec.JSON(messageErrorAnswer(err))
+	}
+
+	emoji, err := url.PathUnescape(ec.Param("emoji"))
 	if err != nil {
-		hndlLog.Err(err).Msgf("FAILED LIKE THEME, id %s", ec.Param("id"))
+		hndlLog.Err(err).Msgf("BAD REQUEST, emoji %s", ec.Param("emoji"))
 
 		return ec.JSON(
 			http.StatusBadRequest,
@@ -479,8 +1219,878 @@ func (c *CentrifugoV1) PutLikeThemes(ec echo.Context) (err error) {
 		)
 	}
 
+	msg, err := c.ReactToMessage(chatID, msgID, user.ID, emoji, add)
+	if err != nil {
+		hndlLog.Err(err).Msgf("REACTION FAILED, chat %d message %d", chatID, msgID)
+
+		return ec.JSON(messageErrorAnswer(err))
+	}
+
+	err = c.PublishChange(models.ChatEventReaction, msg)
+	if err != nil {
+		hndlLog.Err(err).Msgf("PUBLISH REACTION FAILED, chat %d message %d", chatID, msgID)
+	}
+
 	return ec.JSON(
 		http.StatusOK,
-		httpsrv.OkResult(),
+		MessageDataResult{Body: msg},
+	)
+}
+
+func (c *CentrifugoV1) PutReactionHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("PutReactionHandler").
+			SetSummary("Add own emoji reaction to chat message").
+			AddInPathParameter("id", "Chat id", reflect.Int64).
+			AddInPathParameter("msgid", "Message id", reflect.Int64).
+			AddInPathParameter("emoji", "URL encoded emoji", reflect.String).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &MessageDataResult{Body: &models.Message{}})
+		return nil
+	}
+
+	return c.reactionHandler(ec, true)
+}
+
+func (c *CentrifugoV1) DeleteReactionHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("DeleteReactionHandler").
+			SetSummary("Remove own emoji reaction from chat message").
+			AddInPathParameter("id", "Chat id", reflect.Int64).
+			AddInPathParameter("msgid", "Message id", reflect.Int64).
+			AddInPathParameter("emoji", "URL encoded emoji", reflect.String).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &MessageDataResult{Body: &models.Message{}})
+		return nil
+	}
+
+	return c.reactionHandler(ec, false)
+}
+
+// chatRequest returns chat of request path and current user, who must have
+// access to the chat channel
+func (c *CentrifugoV1) chatRequest(ec echo.Context) (int, *models.User, error) {
+	chatID, err := strconv.Atoi(ec.Param("id"))
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: %v", ErrBadRequestID, err)
+	}
+
+	user, err := c.userV1.CurrentUser(ec)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: %v", ErrUnauthorized, err)
+	}
+
+	err = c.AuthorizeChannel(user, ec.Param("id"))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return chatID, user, nil
+}
+
+func (c *CentrifugoV1) ChatReadPostHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("ChatReadPostHandler").
+			SetSummary("Mark chat messages read").
+			AddInBodyParameter("read", "The last read message, the last message of chat if empty", &models.ChatReadRequest{}, false).
+			AddInPathParameter("id", "Chat id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &ChatReadDataResult{Body: &models.ChatRead{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&c.log, ec)
+
+	chatID, user, err := c.chatRequest(ec)
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, chat %s", ec.Param("id"))
+
+		return ec.JSON(messageErrorAnswer(err))
+	}
+
+	var request models.ChatReadRequest
+
+	if ec.Request().ContentLength != 0 {
+		err = ec.Bind(&request)
+		if err != nil {
+			hndlLog.Err(err).Msg("BAD REQUEST")
+
+			return ec.JSON(
+				http.StatusBadRequest,
+				httpsrv.BadRequest(err),
+			)
+		}
+	}
+
+	read, err := c.MarkRead(chatID, user.ID, request.MessageID)
+	if err != nil {
+		hndlLog.Err(err).Msgf("MARK READ FAILED, chat %d", chatID)
+
+		return ec.JSON(messageErrorAnswer(err))
+	}
+
+	// Other sessions of user update unread count
+	err = c.publishUnread(read)
+	if err != nil {
+		hndlLog.Warn().Err(err).Msgf("PUBLISH UNREAD FAILED, chat %d", chatID)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		ChatReadDataResult{Body: read},
+	)
+}
+
+func (c *CentrifugoV1) ChatReadsGetHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("ChatReadsGetHandler").
+			SetSummary("Get read receipts of chat").
+			AddInPathParameter("id", "Chat id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &ChatReadDataResult{Body: &[]*models.ChatRead{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&c.log, ec)
+
+	chatID, _, err := c.chatRequest(ec)
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, chat %s", ec.Param("id"))
+
+		return ec.JSON(messageErrorAnswer(err))
+	}
+
+	reads, err := c.GetChatReads(chatID)
+	if err != nil {
+		hndlLog.Err(err).Msgf("GET CHAT READS FAILED, chat %d", chatID)
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		ChatReadDataResult{Body: reads},
+	)
+}
+
+func themeErrorAnswer(err error) (int, httpsrv.ErrorAnsw) {
+	switch {
+	case errors.Is(err, ErrEmptyTitle), errors.Is(err, ErrBadDirectionIcon), errors.Is(err, ErrBadDirectionsOrder),
+		errors.Is(err, ErrDirectionArchived):
+		return http.StatusBadRequest, httpsrv.BadRequest(err)
+	case errors.Is(err, ErrUnauthorized):
+		return http.StatusUnauthorized, httpsrv.Unauthorized(err)
+	case errors.Is(err, ErrNotThemeAuthor):
+		return http.StatusForbidden, httpsrv.Forbidden(err)
+	case errors.Is(err, ErrThemeNotFound), errors.Is(err, ErrDirectionNotFound):
+		return http.StatusNotFound, httpsrv.NotFound(err)
+	}
+
+	return http.StatusInternalServerError, httpsrv.InternalServerError(err)
+}
+
+// themeRequest returns theme of request path and current user, who must be
+// allowed to manage the theme
+func (c *CentrifugoV1) themeRequest(ec echo.Context) (*models.Theme, *models.User, error) {
+	themeID, err := strconv.Atoi(ec.Param("id"))
+	if err != nil {
+		return nil, nil, ErrThemeNotFound
+	}
+
+	user, err := c.userV1.CurrentUser(ec)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrUnauthorized, err)
+	}
+
+	theme, err := c.GetTheme(themeID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = CanManageTheme(user, theme)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return theme, user, nil
+}
+
+// reindexThemes updates search index of themes changed by request
+func (c *CentrifugoV1) reindexThemes(hndlLog *zerolog.Logger, themes ...*models.Theme) {
+	for _, theme := range themes {
+		err := c.searchV1.IndexTheme(theme)
+		if err != nil {
+			hndlLog.Warn().Err(err).Msgf("INDEX THEME FAILED, id %d", theme.ID)
+		}
+	}
+}
+
+func (c *CentrifugoV1) UpdateThemeHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("UpdateThemeHandler").
+			SetSummary("Update theme by author or moderator").
+			AddInBodyParameter("theme", "Theme changes", &models.ThemeUpdate{}, true).
+			AddInPathParameter("id", "Theme id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &ThemeDataResult{Body: &models.Theme{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&c.log, ec)
+
+	var request models.ThemeUpdate
+
+	err = ec.Bind(&request)
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, theme %s", ec.Param("id"))
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	theme, user, err := c.themeRequest(ec)
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, theme %s", ec.Param("id"))
+
+		return ec.JSON(themeErrorAnswer(err))
+	}
+
+	request.Title = strings.TrimSpace(request.Title)
+
+	themeID := theme.ID
+
+	theme, err = c.UpdateTheme(themeID, &request)
+	if err != nil {
+		hndlLog.Err(err).Msgf("UPDATE THEME FAILED, theme %d user %d", themeID, user.ID)
+
+		return ec.JSON(themeErrorAnswer(err))
+	}
+
+	c.reindexThemes(&hndlLog, theme)
+
+	return ec.JSON(
+		http.StatusOK,
+		ThemeDataResult{Body: theme},
+	)
+}
+
+// themeStateHandler applies state assignment to theme of request
+func (c *CentrifugoV1) themeStateHandler(ec echo.Context, assignment string) (err error) {
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&c.log, ec)
+
+	theme, user, err := c.themeRequest(ec)
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, theme %s", ec.Param("id"))
+
+		return ec.JSON(themeErrorAnswer(err))
+	}
+
+	if assignment == themeUnarchive && theme.ArchivedAt.Valid {
+		direction, err := c.GetDirection(theme.Direction)
+		if err == nil && direction.ArchivedAt.Valid {
+			err = ErrDirectionArchived
+		}
+
+		if err != nil {
+			hndlLog.Err(err).Msgf("UNARCHIVE THEME FAILED, theme %d direction %d", theme.ID, theme.Direction)
+
+			return ec.JSON(themeErrorAnswer(err))
+		}
+	}
+
+	theme, err = c.SetThemeState(theme.ID, assignment)
+	if err != nil {
+		hndlLog.Err(err).Msgf("THEME STATE FAILED, theme %s user %d: %s", ec.Param("id"), user.ID, assignment)
+
+		return ec.JSON(themeErrorAnswer(err))
+	}
+
+	c.reindexThemes(&hndlLog, theme)
+
+	return ec.JSON(
+		http.StatusOK,
+		ThemeDataResult{Body: theme},
+	)
+}
+
+func (c *CentrifugoV1) DeleteThemeHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("DeleteThemeHandler").
+			SetSummary("Delete theme by author or moderator").
+			AddInPathParameter("id", "Theme id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &ThemeDataResult{Body: &models.Theme{}})
+		return nil
+	}
+
+	return c.themeStateHandler(ec, themeDelete)
+}
+
+func (c *CentrifugoV1) ArchiveThemeHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("ArchiveThemeHandler").
+			SetSummary("Archive theme, its chat becomes read only").
+			AddInPathParameter("id", "Theme id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &ThemeDataResult{Body: &models.Theme{}})
+		return nil
+	}
+
+	return c.themeStateHandler(ec, themeArchive)
+}
+
+func (c *CentrifugoV1) UnarchiveThemeHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("UnarchiveThemeHandler").
+			SetSummary("Restore archived theme").
+			AddInPathParameter("id", "Theme id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &ThemeDataResult{Body: &models.Theme{}})
+		return nil
+	}
+
+	return c.themeStateHandler(ec, themeUnarchive)
+}
+
+func (c *CentrifugoV1) CloseThemeHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("CloseThemeHandler").
+			SetSummary("Close theme for new messages").
+			AddInPathParameter("id", "Theme id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &ThemeDataResult{Body: &models.Theme{}})
+		return nil
+	}
+
+	return c.themeStateHandler(ec, themeClose)
+}
+
+func (c *CentrifugoV1) ReopenThemeHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("ReopenThemeHandler").
+			SetSummary("Reopen closed theme").
+			AddInPathParameter("id", "Theme id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &ThemeDataResult{Body: &models.Theme{}})
+		return nil
+	}
+
+	return c.themeStateHandler(ec, themeReopen)
+}
+
+func (c *CentrifugoV1) PinThemeHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("PinThemeHandler").
+			SetSummary("Pin theme on top of direction").
+			AddInPathParameter("id", "Theme id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &ThemeDataResult{Body: &models.Theme{}})
+		return nil
+	}
+
+	return c.themeStateHandler(ec, themePin)
+}
+
+func (c *CentrifugoV1) UnpinThemeHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("UnpinThemeHandler").
+			SetSummary("Unpin theme").
+			AddInPathParameter("id", "Theme id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &ThemeDataResult{Body: &models.Theme{}})
+		return nil
+	}
+
+	return c.themeStateHandler(ec, themeUnpin)
+}
+
+func (c *CentrifugoV1) CreateDirectionHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("CreateDirectionHandler").
+			SetSummary("Create direction").
+			AddInBodyParameter("direction", "Direction title, position and meta with icon", &models.Direction{}, true).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &DirectionDataResult{Body: &models.Direction{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&c.log, ec)
+
+	var request models.Direction
+
+	err = ec.Bind(&request)
+	if err != nil {
+		hndlLog.Err(err).Msg("BAD REQUEST")
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	direction, err := c.CreateDirection(&request)
+	if err != nil {
+		hndlLog.Err(err).Msgf("CREATE DIRECTION FAILED %+v", &request)
+
+		return ec.JSON(themeErrorAnswer(err))
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		DirectionDataResult{Body: direction},
+	)
+}
+
+func (c *CentrifugoV1) UpdateDirectionHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("UpdateDirectionHandler").
+			SetSummary("Update direction").
+			AddInBodyParameter("direction", "Direction title, position and meta with icon", &models.Direction{}, true).
+			AddInPathParameter("id", "Direction id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &DirectionDataResult{Body: &models.Direction{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&c.log, ec)
+
+	var request models.Direction
+
+	err = ec.Bind(&request)
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, direction %s", ec.Param("id"))
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	directionID, err := strconv.Atoi(ec.Param("id"))
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, direction %s", ec.Param("id"))
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	direction, err := c.UpdateDirection(directionID, &request)
+	if err != nil {
+		hndlLog.Err(err).Msgf("UPDATE DIRECTION FAILED, direction %d", directionID)
+
+		return ec.JSON(themeErrorAnswer(err))
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		DirectionDataResult{Body: direction},
+	)
+}
+
+func (c *CentrifugoV1) ReorderDirectionsHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("ReorderDirectionsHandler").
+			SetSummary("Set order of directions").
+			AddInBodyParameter("order", "Ids of all directions in display order", &models.DirectionOrder{}, true).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &DirectionsDataResult{Body: &ArrayOfDirectionData{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&c.log, ec)
+
+	var request models.DirectionOrder
+
+	err = ec.Bind(&request)
+	if err != nil {
+		hndlLog.Err(err).Msg("BAD REQUEST")
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	directionsData, err := c.ReorderDirections(request.IDs)
+	if err != nil {
+		hndlLog.Err(err).Msgf("REORDER DIRECTIONS FAILED %v", request.IDs)
+
+		return ec.JSON(themeErrorAnswer(err))
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		DirectionsDataResult{Body: directionsData},
+	)
+}
+
+func (c *CentrifugoV1) DeleteDirectionHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("DeleteDirectionHandler").
+			SetSummary("Delete direction with its themes").
+			AddInPathParameter("id", "Direction id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &DirectionDataResult{Body: &models.Direction{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&c.log, ec)
+
+	directionID, err := strconv.Atoi(ec.Param("id"))
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, direction %s", ec.Param("id"))
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	direction, themes, err := c.DeleteDirection(directionID)
+	if err != nil {
+		hndlLog.Err(err).Msgf("DELETE DIRECTION FAILED, direction %d", directionID)
+
+		return ec.JSON(themeErrorAnswer(err))
+	}
+
+	c.reindexThemes(&hndlLog, themes...)
+
+	return ec.JSON(
+		http.StatusOK,
+		DirectionDataResult{Body: direction},
+	)
+}
+
+func (c *CentrifugoV1) archiveDirectionHandler(ec echo.Context, archived bool) (err error) {
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&c.log, ec)
+
+	directionID, err := strconv.Atoi(ec.Param("id"))
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, direction %s", ec.Param("id"))
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	direction, themes, err := c.ArchiveDirection(directionID, archived)
+	if err != nil {
+		hndlLog.Err(err).Msgf("ARCHIVE DIRECTION FAILED, direction %d archived %t", directionID, archived)
+
+		return ec.JSON(themeErrorAnswer(err))
+	}
+
+	c.reindexThemes(&hndlLog, themes...)
+
+	return ec.JSON(
+		http.StatusOK,
+		DirectionDataResult{Body: direction},
+	)
+}
+
+func (c *CentrifugoV1) ArchiveDirectionHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("ArchiveDirectionHandler").
+			SetSummary("Archive direction with its themes, chats of themes become read only").
+			AddInPathParameter("id", "Direction id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &DirectionDataResult{Body: &models.Direction{}})
+		return nil
+	}
+
+	return c.archiveDirectionHandler(ec, true)
+}
+
+func (c *CentrifugoV1) UnarchiveDirectionHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("UnarchiveDirectionHandler").
+			SetSummary("Restore direction with themes archived together with it").
+			AddInPathParameter("id", "Direction id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &DirectionDataResult{Body: &models.Direction{}})
+		return nil
+	}
+
+	return c.archiveDirectionHandler(ec, false)
+}
+
+func (c *CentrifugoV1) UploadAttachmentHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("UploadAttachmentHandler").
+			SetSummary("Upload files to chat, IDs of files are sent in attachments of published message").
+			AddInPathParameter("id", "Chat id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &AttachmentsDataResult{Body: &ArrayOfAttachmentData{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&c.log, ec)
+
+	chatID, user, err := c.chatRequest(ec)
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, chat %s", ec.Param("id"))
+
+		return ec.JSON(messageErrorAnswer(err))
+	}
+
+	multipartForm, err := ec.MultipartForm()
+	if err != nil {
+		hndlLog.Err(err).Msg("failed to read multipartform")
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	files := 0
+	for _, fileHeaders := range multipartForm.File {
+		files += len(fileHeaders)
+	}
+
+	if files == 0 || files > maxAttachments {
+		hndlLog.Error().Msgf("BAD REQUEST, chat %d files %d", chatID, files)
+
+		return ec.JSON(messageErrorAnswer(ErrNoAttachments))
+	}
+
+	attachments := ArrayOfAttachmentData{}
+
+	for _, fileHeaders := range multipartForm.File {
+		for _, fileHeader := range fileHeaders {
+			attachment, err := c.UploadAttachment(chatID, user.ID, fileHeader)
+			if err != nil {
+				hndlLog.Err(err).Msgf("UPLOAD ATTACHMENT FAILED, chat %d file %s", chatID, fileHeader.Filename)
+
+				return ec.JSON(messageErrorAnswer(err))
+			}
+
+			attachments = append(attachments, *attachment)
+		}
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		AttachmentsDataResult{Body: attachments},
+	)
+}
+
+func (c *CentrifugoV1) attachmentHandler(ec echo.Context, thumb bool) (err error) {
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&c.log, ec)
+
+	chatID, user, err := c.chatRequest(ec)
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, chat %s", ec.Param("id"))
+
+		return ec.JSON(messageErrorAnswer(err))
+	}
+
+	attachment, stream, err := c.OpenAttachment(chatID, ec.Param("attid"), user, thumb)
+	if err != nil {
+		hndlLog.Err(err).Msgf("OPEN ATTACHMENT FAILED, chat %d attachment %s", chatID, ec.Param("attid"))
+
+		return ec.JSON(messageErrorAnswer(err))
+	}
+	defer stream.Close()
+
+	disposition := "attachment"
+	if strings.HasPrefix(attachment.ContentType, "image/") {
+		disposition = "inline"
+	}
+
+	header := ec.Response().Header()
+	header.Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	header.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.FileName}))
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("Cache-Control", "private, max-age=86400")
+
+	return ec.Stream(http.StatusOK, attachment.ContentType, stream)
+}
+
+func (c *CentrifugoV1) GetAttachmentHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/octet-stream").
+			SetDescription("GetAttachmentHandler").
+			SetSummary("Download file of chat message").
+			AddInPathParameter("id", "Chat id", reflect.Int64).
+			AddInPathParameter("attid", "Attachment id", reflect.String).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", nil)
+		return nil
+	}
+
+	return c.attachmentHandler(ec, false)
+}
+
+func (c *CentrifugoV1) GetAttachmentThumbnailHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("image/jpeg").
+			SetDescription("GetAttachmentThumbnailHandler").
+			SetSummary("Download JPEG thumbnail of image attached to chat message").
+			AddInPathParameter("id", "Chat id", reflect.Int64).
+			AddInPathParameter("attid", "Attachment id", reflect.String).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", nil)
+		return nil
+	}
+
+	return c.attachmentHandler(ec, true)
+}
+
+func (c *CentrifugoV1) ExportChatHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json", "text/csv", "text/html").
+			SetDescription("ExportChatHandler").
+			SetSummary("Export full chat transcript, allowed to moderators and participants").
+			AddInPathParameter("id", "Chat id", reflect.Int64).
+			AddInQueryParameter("format", "Transcript format: json, csv or html", reflect.String, false).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", nil)
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&c.log, ec)
+
+	format := ec.QueryParam("format")
+	if format == "" {
+		format = models.ChatExportJSON
+	}
+
+	contentType, ext, err := exportContentType(format)
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, format %s", format)
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	chatID, user, err := c.chatRequest(ec)
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, chat %s", ec.Param("id"))
+
+		return ec.JSON(messageErrorAnswer(err))
+	}
+
+	chat, err := c.CanExportChat(chatID, user)
+	if err != nil {
+		hndlLog.Err(err).Msgf("EXPORT CHAT DENIED, chat %d user %d", chatID, user.ID)
+
+		return ec.JSON(messageErrorAnswer(err))
+	}
+
+	header := ec.Response().Header()
+	header.Set(echo.HeaderContentType, contentType)
+	header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": "chat_" + strconv.Itoa(chatID) + "." + ext,
+	}))
+	ec.Response().WriteHeader(http.StatusOK)
+
+	// Response is already started, failure can only be logged
+	err = c.ExportChat(chat, user.Role >= types.Moderator, format, ec.Response())
+	if err != nil {
+		hndlLog.Err(err).Msgf("EXPORT CHAT FAILED, chat %d format %s", chatID, format)
+	}
+
+	return nil
+}
+
+func (c *CentrifugoV1) ThemePresenceHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("ThemePresenceHandler").
+			SetSummary("Get users reading theme discussion right now").
+			AddInPathParameter("id", "Theme id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &ThemePresenceDataResult{Body: &models.ThemePresence{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&c.log, ec)
+
+	themeID, user, err := c.chatRequest(ec)
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, theme %s", ec.Param("id"))
+
+		return ec.JSON(messageErrorAnswer(err))
+	}
+
+	presence, err := c.ThemePresence(themeID)
+	if err != nil {
+		hndlLog.Err(err).Msgf("GET PRESENCE FAILED, theme %d user %d", themeID, user.ID)
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		ThemePresenceDataResult{Body: presence},
 	)
 }
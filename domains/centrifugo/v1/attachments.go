@@ -0,0 +1,425 @@
+package centrifugov1
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/thumbnail"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+	"github.com/sqsinformatique/rosseti-innovation-back/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxAttachments limits files of one message
+const maxAttachments = 10
+
+// Kinds of files in attachments bucket
+const (
+	attachmentKindFile      = "file"
+	attachmentKindThumbnail = "thumbnail"
+)
+
+var (
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	ErrAttachmentTooLarge = errors.New("attachment is too large")
+	ErrBadAttachment      = errors.New("attachment can't be added to message")
+	ErrNoAttachments      = errors.New("no files in request")
+)
+
+// attachmentMeta is stored in metadata of GridFS file. MessageID is zero
+// until the file is sent with a message, such file is available to its
+// uploader only.
+type attachmentMeta struct {
+	Kind        string             `bson:"kind"`
+	ChannelID   int                `bson:"channel_id"`
+	Uploader    int                `bson:"uploader"`
+	MessageID   int                `bson:"message_id"`
+	ContentType string             `bson:"content_type"`
+	Width       int                `bson:"width,omitempty"`
+	Height      int                `bson:"height,omitempty"`
+	Thumbnail   primitive.ObjectID `bson:"thumbnail,omitempty"`
+}
+
+// attachmentFile is a document of GridFS files collection
+type attachmentFile struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	Length     int64              `bson:"length"`
+	FileName   string             `bson:"filename"`
+	UploadDate time.Time          `bson:"uploadDate"`
+	Meta       attachmentMeta     `bson:"metadata"`
+}
+
+func (f *attachmentFile) attachment() *models.Attachment {
+	return &models.Attachment{
+		ID:           f.ID.Hex(),
+		FileName:     f.FileName,
+		ContentType:  f.Meta.ContentType,
+		Size:         f.Length,
+		Width:        f.Meta.Width,
+		Height:       f.Meta.Height,
+		HasThumbnail: !f.Meta.Thumbnail.IsZero(),
+	}
+}
+
+func (c *CentrifugoV1) attachmentsBucket() (*gridfs.Bucket, error) {
+	mongoconn := *c.mongoDB
+	return gridfs.NewBucket(
+		mongoconn.Database(c.config.Mongo.ChatDB),
+		options.GridFSBucket().SetName("attachments"),
+	)
+}
+
+// detectContentType sniffs content of file, declared type of upload is not
+// trusted because it is served back to other users
+func detectContentType(file io.ReadSeeker) (string, error) {
+	head := make([]byte, 512)
+
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+
+	contentType := http.DetectContentType(head[:n])
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		contentType = mediaType
+	}
+
+	return contentType, nil
+}
+
+// uploadThumbnail stores thumbnail of image and fills its dimensions in
+// meta, image which can't be decoded is stored without thumbnail
+func (c *CentrifugoV1) uploadThumbnail(bucket *gridfs.Bucket, fileName string, file io.ReadSeeker, meta *attachmentMeta) error {
+	width, height, err := thumbnail.Config(file)
+	if err != nil {
+		return err
+	}
+
+	meta.Width, meta.Height = width, height
+
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	thumb, err := thumbnail.Make(file, c.config.Attachments.ThumbnailSize)
+	if err != nil {
+		return err
+	}
+
+	thumbMeta := &attachmentMeta{
+		Kind:        attachmentKindThumbnail,
+		ChannelID:   meta.ChannelID,
+		Uploader:    meta.Uploader,
+		ContentType: "image/jpeg",
+	}
+
+	thumbName := fileName[:len(fileName)-len(filepath.Ext(fileName))] + "_thumb.jpg"
+
+	thumbID, err := bucket.UploadFromStream(thumbName, thumb, options.GridFSUpload().SetMetadata(thumbMeta))
+	if err != nil {
+		return err
+	}
+
+	meta.Thumbnail = thumbID
+
+	return nil
+}
+
+// UploadAttachment stores file uploaded by user to chat, the file is added
+// to message by its ID on publish
+func (c *CentrifugoV1) UploadAttachment(chatID, userID int, fileHeader *multipart.FileHeader) (*models.Attachment, error) {
+	if fileHeader.Size > c.config.Attachments.MaxSize {
+		return nil, ErrAttachmentTooLarge
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	contentType, err := detectContentType(file)
+	if err != nil {
+		return nil, err
+	}
+
+	bucket, err := c.attachmentsBucket()
+	if err != nil {
+		return nil, err
+	}
+
+	fileName := filepath.Base(fileHeader.Filename)
+
+	meta := &attachmentMeta{
+		Kind:        attachmentKindFile,
+		ChannelID:   chatID,
+		Uploader:    userID,
+		ContentType: contentType,
+	}
+
+	if thumbnail.Supported(contentType) {
+		// Image is stored even if thumbnail is not made
+		err = c.uploadThumbnail(bucket, fileName, file, meta)
+		if err != nil {
+			c.log.Warn().Err(err).Msgf("make thumbnail failed, chat %d file %s", chatID, fileName)
+		}
+
+		_, err = file.Seek(0, io.SeekStart)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	fileID, err := bucket.UploadFromStream(fileName, file, options.GridFSUpload().SetMetadata(meta))
+	if err != nil {
+		return nil, err
+	}
+
+	uploaded := &attachmentFile{
+		ID:       fileID,
+		Length:   fileHeader.Size,
+		FileName: fileName,
+		Meta:     *meta,
+	}
+
+	return uploaded.attachment(), nil
+}
+
+func (c *CentrifugoV1) getAttachmentFile(bucket *gridfs.Bucket, id string) (*attachmentFile, error) {
+	fileID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrAttachmentNotFound
+	}
+
+	file := &attachmentFile{}
+
+	err = bucket.GetFilesCollection().FindOne(context.TODO(), bson.M{"_id": fileID}).Decode(file)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrAttachmentNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// resolveAttachments checks that files were uploaded to chat by user and
+// were not sent yet, concurrent sending is resolved by bindAttachments
+func (c *CentrifugoV1) resolveAttachments(chatID, userID int, ids []string) ([]*models.Attachment, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	if len(ids) > maxAttachments {
+		return nil, ErrBadAttachment
+	}
+
+	bucket, err := c.attachmentsBucket()
+	if err != nil {
+		return nil, err
+	}
+
+	attachments := make([]*models.Attachment, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+
+		seen[id] = true
+
+		file, err := c.getAttachmentFile(bucket, id)
+		if errors.Is(err, ErrAttachmentNotFound) {
+			return nil, ErrBadAttachment
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if file.Meta.Kind != attachmentKindFile || file.Meta.ChannelID != chatID ||
+			file.Meta.Uploader != userID || file.Meta.MessageID != 0 {
+			return nil, ErrBadAttachment
+		}
+
+		attachments = append(attachments, file.attachment())
+	}
+
+	return attachments, nil
+}
+
+// bindAttachments links sent files to message, so they become available to
+// all readers of chat. File is bound only if it is still unbound, so the
+// same upload can't be sent with two messages. Nothing is bound and
+// ErrBadAttachment is returned if any file is already bound.
+func (c *CentrifugoV1) bindAttachments(msg *models.Message) error {
+	if len(msg.Attachments) == 0 {
+		return nil
+	}
+
+	bucket, err := c.attachmentsBucket()
+	if err != nil {
+		return err
+	}
+
+	files := bucket.GetFilesCollection()
+
+	for _, attachment := range msg.Attachments {
+		id, err := primitive.ObjectIDFromHex(attachment.ID)
+		if err != nil {
+			return ErrBadAttachment
+		}
+
+		var file attachmentFile
+
+		err = files.FindOneAndUpdate(
+			context.TODO(),
+			bson.M{
+				"_id":                 id,
+				"metadata.kind":       attachmentKindFile,
+				"metadata.channel_id": msg.ChannelID,
+				"metadata.uploader":   msg.Sender,
+				"metadata.message_id": 0,
+			},
+			bson.M{"$set": bson.M{"metadata.message_id": msg.ID}},
+		).Decode(&file)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			err = ErrBadAttachment
+		}
+
+		if err == nil && !file.Meta.Thumbnail.IsZero() {
+			_, err = files.UpdateOne(
+				context.TODO(),
+				bson.M{"_id": file.Meta.Thumbnail, "metadata.message_id": 0},
+				bson.M{"$set": bson.M{"metadata.message_id": msg.ID}},
+			)
+		}
+
+		if err != nil {
+			c.unbindAttachments(msg)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unbindAttachments releases files bound to message which was not saved,
+// failure is only logged
+func (c *CentrifugoV1) unbindAttachments(msg *models.Message) {
+	bucket, err := c.attachmentsBucket()
+	if err == nil {
+		_, err = bucket.GetFilesCollection().UpdateMany(
+			context.TODO(),
+			bson.M{"metadata.channel_id": msg.ChannelID, "metadata.message_id": msg.ID},
+			bson.M{"$set": bson.M{"metadata.message_id": 0}},
+		)
+	}
+
+	if err != nil {
+		c.log.Warn().Err(err).Msgf("unbind attachments failed, chat %d message %d", msg.ChannelID, msg.ID)
+	}
+}
+
+// deleteAttachments removes files of deleted message with their thumbnails
+func (c *CentrifugoV1) deleteAttachments(msg *models.Message) {
+	if len(msg.Attachments) == 0 {
+		return
+	}
+
+	bucket, err := c.attachmentsBucket()
+	if err != nil {
+		c.log.Warn().Err(err).Msgf("delete attachments failed, chat %d message %d", msg.ChannelID, msg.ID)
+		return
+	}
+
+	for _, attachment := range msg.Attachments {
+		file, err := c.getAttachmentFile(bucket, attachment.ID)
+		if err == nil && !file.Meta.Thumbnail.IsZero() {
+			err = bucket.Delete(file.Meta.Thumbnail)
+		}
+
+		if err == nil {
+			err = bucket.Delete(file.ID)
+		}
+
+		if err != nil {
+			c.log.Warn().Err(err).Msgf("delete attachment %s failed, chat %d message %d", attachment.ID, msg.ChannelID, msg.ID)
+		}
+	}
+}
+
+// OpenAttachment returns file or its thumbnail for download. File which
+// is not sent yet is available to uploader only, file of hidden message is
+// available to moderators only.
+func (c *CentrifugoV1) OpenAttachment(chatID int, id string, user *models.User, thumb bool) (*models.Attachment, io.ReadCloser, error) {
+	bucket, err := c.attachmentsBucket()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := c.getAttachmentFile(bucket, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if file.Meta.Kind != attachmentKindFile || file.Meta.ChannelID != chatID {
+		return nil, nil, ErrAttachmentNotFound
+	}
+
+	if file.Meta.MessageID == 0 {
+		if file.Meta.Uploader != user.ID {
+			return nil, nil, ErrAttachmentNotFound
+		}
+	} else {
+		msg, err := c.GetMessage(chatID, file.Meta.MessageID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if msg.Deleted {
+			return nil, nil, ErrAttachmentNotFound
+		}
+
+		if msg.Hidden && user.Role < types.Moderator {
+			return nil, nil, ErrAttachmentNotFound
+		}
+	}
+
+	if thumb {
+		if file.Meta.Thumbnail.IsZero() {
+			return nil, nil, ErrAttachmentNotFound
+		}
+
+		file, err = c.getAttachmentFile(bucket, file.Meta.Thumbnail.Hex())
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	stream, err := bucket.OpenDownloadStream(file.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return file.attachment(), stream, nil
+}
@@ -0,0 +1,241 @@
+package centrifugov1
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/centrifugo"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+	"github.com/sqsinformatique/rosseti-innovation-back/types"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Channel namespaces, channel name is "namespace:id". Channel without
+// namespace is a theme chat created before namespaces were introduced.
+// Personal channels are user limited: "personal:#42". Private channel
+// "$theme:1" requires subscription token and is authorized as "theme:1".
+const (
+	NamespaceTheme      = "theme"
+	NamespaceInnovation = "innovation"
+	NamespacePersonal   = "personal"
+	NamespaceExpert     = "expert"
+
+	namespaceSeparator = ":"
+	userBoundary       = "#"
+	privatePrefix      = "$"
+)
+
+// Errors replied to centrifugo proxy requests, codes are centrifugo
+// client protocol ones
+var (
+	ErrProxyInternal         = &models.CentrifugoError{Code: 100, Message: "internal server error"}
+	ErrProxyUnauthorized     = &models.CentrifugoError{Code: 101, Message: "unauthorized"}
+	ErrProxyUnknownChannel   = &models.CentrifugoError{Code: 102, Message: "unknown channel"}
+	ErrProxyPermissionDenied = &models.CentrifugoError{Code: 103, Message: "permission denied"}
+)
+
+// Channel returns channel name of resource in namespace
+func Channel(namespace string, id int) string {
+	if namespace == NamespacePersonal {
+		return centrifugo.PersonalChannel(id)
+	}
+
+	return namespace + namespaceSeparator + strconv.Itoa(id)
+}
+
+// parseChannel splits channel name to namespace and resource id
+func parseChannel(channel string) (string, int, error) {
+	channel = strings.TrimPrefix(channel, privatePrefix)
+	namespace, resource := "", channel
+
+	if i := strings.Index(channel, namespaceSeparator); i >= 0 {
+		namespace, resource = channel[:i], channel[i+1:]
+	}
+
+	if namespace == NamespacePersonal {
+		if !strings.HasPrefix(resource, userBoundary) {
+			return "", 0, ErrProxyUnknownChannel
+		}
+
+		resource = strings.TrimPrefix(resource, userBoundary)
+	}
+
+	id, err := strconv.Atoi(resource)
+	if err != nil || id <= 0 {
+		return "", 0, ErrProxyUnknownChannel
+	}
+
+	return namespace, id, nil
+}
+
+// isThemeChat tells whether chat of type is a theme discussion, chats
+// created before types were introduced are theme ones
+func isThemeChat(chatType string) bool {
+	return chatType == "" || chatType == NamespaceTheme
+}
+
+// chatChannel returns channel of stored chat, chats without type are
+// theme ones
+func chatChannel(chat *models.ChatChannel) string {
+	if isThemeChat(chat.Type) {
+		return Channel(NamespaceTheme, chat.ID)
+	}
+
+	return Channel(chat.Type, chat.ID)
+}
+
+// channelChat returns type and id of chat published to channel. Type is
+// taken from channel namespace, which is what the channel is authorized
+// by, bare channels are theme chats. Chats of different namespaces share
+// ids, so existing chat of another type is not published to. Personal
+// channels have no chat.
+func (c *CentrifugoV1) channelChat(channel string) (string, int, error) {
+	namespace, id, err := parseChannel(channel)
+	if err != nil {
+		return "", 0, err
+	}
+
+	chatType := namespace
+
+	switch namespace {
+	case "", NamespaceTheme:
+		chatType = NamespaceTheme
+	case NamespaceInnovation, NamespaceExpert:
+	default:
+		return "", 0, ErrProxyUnknownChannel
+	}
+
+	chat, err := c.GetChat(id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return chatType, id, nil
+	}
+
+	if err != nil {
+		return "", 0, err
+	}
+
+	stored := chat.Type
+	if isThemeChat(stored) {
+		stored = NamespaceTheme
+	}
+
+	if stored != chatType {
+		return "", 0, ErrProxyUnknownChannel
+	}
+
+	return chatType, id, nil
+}
+
+// proxyUser returns user of centrifugo connection, deleted users and
+// anonymous connections are not authorized
+func (c *CentrifugoV1) proxyUser(userID string) (*models.User, error) {
+	id, err := strconv.ParseInt(userID, 10, 64)
+	if err != nil {
+		return nil, ErrProxyUnauthorized
+	}
+
+	user, err := c.userV1.GetUserByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrProxyUnauthorized
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if user.DeletedAt.Valid {
+		return nil, ErrProxyUnauthorized
+	}
+
+	return user, nil
+}
+
+// AuthorizeChannel checks user access to channel. Returned error is
+// *models.CentrifugoError if access is denied, any other error is internal.
+//
+// Theme chats are open to users except banned ones, innovation discussion is open to author,
+// assigned expert and moderators while innovation is a draft and to users
+// afterwards, expert rooms are open to assigned expert and moderators,
+// personal channel is open to its owner only.
+func (c *CentrifugoV1) AuthorizeChannel(user *models.User, channel string) error {
+	namespace, id, err := parseChannel(channel)
+	if err != nil {
+		return err
+	}
+
+	switch namespace {
+	case "", NamespaceTheme:
+		if user.Role < types.User {
+			return ErrProxyPermissionDenied
+		}
+
+		exists, err := c.themeExists(id)
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			return ErrProxyUnknownChannel
+		}
+
+		if user.Role >= types.Moderator {
+			return nil
+		}
+
+		banned, err := c.moderationV1.IsBanned(id, user.ID)
+		if err != nil {
+			return err
+		}
+
+		if banned {
+			return ErrProxyPermissionDenied
+		}
+
+		return nil
+	case NamespaceInnovation, NamespaceExpert:
+		access, err := c.innovationAccess(id, user.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrProxyUnknownChannel
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if user.Role >= types.Moderator || access.Expert {
+			return nil
+		}
+
+		if namespace == NamespaceInnovation && user.Role >= types.User &&
+			(access.AuthorID == user.ID || access.State != types.Draft) {
+			return nil
+		}
+
+		return ErrProxyPermissionDenied
+	case NamespacePersonal:
+		if id != user.ID {
+			return ErrProxyPermissionDenied
+		}
+
+		return nil
+	}
+
+	return ErrProxyUnknownChannel
+}
+
+// proxyReply converts result of authorization to centrifugo proxy reply,
+// internal errors are hidden from client
+func proxyReply(result interface{}, err error) *models.CentrifugoProxyReply {
+	if err == nil {
+		return &models.CentrifugoProxyReply{Result: result}
+	}
+
+	var proxyErr *models.CentrifugoError
+	if errors.As(err, &proxyErr) {
+		return &models.CentrifugoProxyReply{Error: proxyErr}
+	}
+
+	return &models.CentrifugoProxyReply{Error: ErrProxyInternal}
+}
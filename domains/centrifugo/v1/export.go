@@ -0,0 +1,449 @@
+package centrifugov1
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/db"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+	"github.com/sqsinformatique/rosseti-innovation-back/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// exportFlushEvery is a count of messages written between flushes of
+// response, so client gets transcript while it is read from database
+const exportFlushEvery = 100
+
+var (
+	ErrBadExportFormat    = errors.New("export format must be json, csv or html")
+	ErrNotChatParticipant = errors.New("only moderators and participants can export chat")
+)
+
+// attachmentURL is a download link of chat attachment
+func attachmentURL(chatID int, attachmentID string) string {
+	return fmt.Sprintf("/api/v1/centrifugo/chat/%d/attachments/%s", chatID, attachmentID)
+}
+
+// chatExport is a transcript header
+type chatExport struct {
+	ChatID     int       `json:"chat_id"`
+	Title      string    `json:"title"`
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+// exportWriter writes transcript in one of export formats
+type exportWriter interface {
+	begin(chat *chatExport) error
+	message(msg *models.ChatExportMessage) error
+	end() error
+}
+
+// exportContentType returns content type and file extension of export
+// format, error is returned for unknown format
+func exportContentType(format string) (contentType, ext string, err error) {
+	switch format {
+	case models.ChatExportJSON:
+		return "application/json; charset=utf-8", "json", nil
+	case models.ChatExportCSV:
+		return "text/csv; charset=utf-8", "csv", nil
+	case models.ChatExportHTML:
+		return "text/html; charset=utf-8", "html", nil
+	}
+
+	return "", "", ErrBadExportFormat
+}
+
+func newExportWriter(format string, w io.Writer) (exportWriter, error) {
+	switch format {
+	case models.ChatExportJSON:
+		return &jsonExport{w: w}, nil
+	case models.ChatExportCSV:
+		return &csvExport{w: w, csv: csv.NewWriter(w)}, nil
+	case models.ChatExportHTML:
+		return &htmlExport{w: w}, nil
+	}
+
+	return nil, ErrBadExportFormat
+}
+
+// jsonExport writes transcript as {"chat": {...}, "messages": [...]}
+type jsonExport struct {
+	w     io.Writer
+	count int
+}
+
+func (e *jsonExport) begin(chat *chatExport) error {
+	header, err := json.Marshal(chat)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(e.w, `{"chat":%s,"messages":[`, header)
+
+	return err
+}
+
+func (e *jsonExport) message(msg *models.ChatExportMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	if e.count > 0 {
+		if _, err = io.WriteString(e.w, ","); err != nil {
+			return err
+		}
+	}
+
+	e.count++
+
+	_, err = e.w.Write(data)
+
+	return err
+}
+
+func (e *jsonExport) end() error {
+	_, err := io.WriteString(e.w, "]}\n")
+	return err
+}
+
+// csvExport writes transcript as a table, BOM makes spreadsheet editors
+// read cyrillic text as UTF-8
+type csvExport struct {
+	w   io.Writer
+	csv *csv.Writer
+}
+
+func (e *csvExport) begin(chat *chatExport) error {
+	if _, err := io.WriteString(e.w, "\ufeff"); err != nil {
+		return err
+	}
+
+	return e.csv.Write([]string{
+		"id", "timestamp", "sender", "sender_name", "reply_to", "text", "attachments", "edited_at", "status",
+	})
+}
+
+func (e *csvExport) message(msg *models.ChatExportMessage) error {
+	replyTo, editedAt, status := "", "", ""
+
+	if msg.ReplyTo != 0 {
+		replyTo = strconv.Itoa(msg.ReplyTo)
+	}
+
+	if msg.EditedAt != nil {
+		editedAt = msg.EditedAt.Format(time.RFC3339)
+	}
+
+	switch {
+	case msg.Deleted:
+		status = "deleted"
+	case msg.Hidden:
+		status = "hidden"
+	}
+
+	links := make([]string, 0, len(msg.Attachments))
+	for _, attachment := range msg.Attachments {
+		links = append(links, attachment.URL)
+	}
+
+	return e.csv.Write([]string{
+		strconv.Itoa(msg.ID),
+		msg.TimeStamp.Format(time.RFC3339),
+		strconv.Itoa(msg.Sender),
+		msg.SenderName,
+		replyTo,
+		msg.Text,
+		strings.Join(links, " "),
+		editedAt,
+		status,
+	})
+}
+
+func (e *csvExport) end() error {
+	e.csv.Flush()
+	return e.csv.Error()
+}
+
+var htmlExportTemplate = template.Must(template.New("export").Funcs(template.FuncMap{
+	"datetime": func(t time.Time) string { return t.Format("02.01.2006 15:04:05") },
+}).Parse(`
+{{- define "begin" -}}
+<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 960px; margin: 2em auto; }
+.message { border-bottom: 1px solid #ddd; padding: .5em 0; }
+.meta { color: #666; font-size: .85em; }
+.text { white-space: pre-wrap; margin: .3em 0; }
+.status { color: #a00; font-style: italic; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="meta">Выгружено {{datetime .ExportedAt}}</p>
+{{end}}
+{{- define "message" -}}
+<div class="message" id="m{{.ID}}">
+<div class="meta">#{{.ID}} {{.SenderName}} {{datetime .TimeStamp}}
+{{- if .ReplyTo}}, ответ на <a href="#m{{.ReplyTo}}">#{{.ReplyTo}}</a>{{end}}
+{{- if .EditedAt}}, изменено {{datetime .EditedAt}}{{end}}</div>
+{{- if .Deleted}}
+<div class="status">Сообщение удалено</div>
+{{- else if and .Hidden (not .Text)}}
+<div class="status">Сообщение скрыто модератором</div>
+{{- else}}
+{{- if .Hidden}}
+<div class="status">Скрыто модератором</div>
+{{- end}}
+<div class="text">{{.Text}}</div>
+{{- range .Attachments}}
+<div><a href="{{.URL}}">{{.FileName}}</a> ({{.Size}} байт)</div>
+{{- end}}
+{{- end}}
+</div>
+{{end}}
+{{- define "end" -}}
+</body>
+</html>
+{{end}}`))
+
+// htmlExport writes transcript as a standalone page
+type htmlExport struct {
+	w io.Writer
+}
+
+func (e *htmlExport) begin(chat *chatExport) error {
+	return htmlExportTemplate.ExecuteTemplate(e.w, "begin", chat)
+}
+
+func (e *htmlExport) message(msg *models.ChatExportMessage) error {
+	return htmlExportTemplate.ExecuteTemplate(e.w, "message", msg)
+}
+
+func (e *htmlExport) end() error {
+	return htmlExportTemplate.ExecuteTemplate(e.w, "end", nil)
+}
+
+// CanExportChat allows export to moderators, author of theme and users who
+// wrote to the chat
+func (c *CentrifugoV1) CanExportChat(chatID int, user *models.User) (*models.ChatChannel, error) {
+	chat, err := c.GetChat(chatID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrChatNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Role >= types.Moderator {
+		return chat, nil
+	}
+
+	if isThemeChat(chat.Type) {
+		theme, err := c.GetTheme(chatID)
+		if err != nil && !errors.Is(err, ErrThemeNotFound) {
+			return nil, err
+		}
+
+		if theme != nil && theme.AuthorID == user.ID {
+			return chat, nil
+		}
+	}
+
+	count, err := c.messagesDB().CountDocuments(
+		context.TODO(),
+		bson.M{"channel_id": chatID, "sender": user.ID},
+		options.Count().SetLimit(1),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if count == 0 {
+		return nil, ErrNotChatParticipant
+	}
+
+	return chat, nil
+}
+
+// chatTitle is a title of theme for theme chat and channel name otherwise
+func (c *CentrifugoV1) chatTitle(chat *models.ChatChannel) string {
+	if isThemeChat(chat.Type) {
+		theme, err := c.GetTheme(chat.ID)
+		if err == nil {
+			return theme.Title
+		}
+	}
+
+	if chat.Name != "" {
+		return chat.Name
+	}
+
+	return "Обсуждение " + strconv.Itoa(chat.ID)
+}
+
+// senderNames resolves names of all senders of chat by their profiles
+func (c *CentrifugoV1) senderNames(chatID int) (map[int]string, error) {
+	senders, err := c.messagesDB().Distinct(context.TODO(), "sender", bson.M{"channel_id": chatID})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[int]string, len(senders))
+	if len(senders) == 0 {
+		return names, nil
+	}
+
+	ids := make([]int, 0, len(senders))
+	for _, sender := range senders {
+		switch id := sender.(type) {
+		case int32:
+			ids = append(ids, int(id))
+		case int64:
+			ids = append(ids, int(id))
+		}
+	}
+
+	conn := *c.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	query, args, err := sqlx.In(`
+		select id, trim(coalesce(user_first_name, '') || ' ' || coalesce(user_last_name, '')) as name
+		from production.profiles
+		where deleted_at is null and id in (?)`, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.Queryx(conn.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id   int
+			name string
+		)
+
+		err = rows.Scan(&id, &name)
+		if err != nil {
+			return nil, err
+		}
+
+		names[id] = name
+	}
+
+	return names, rows.Err()
+}
+
+// exportMessage converts message to transcript entry, text of hidden
+// message is exported if showHidden is set
+func exportMessage(msg *models.Message, names map[int]string, showHidden bool) *models.ChatExportMessage {
+	if !showHidden {
+		msg = concealHidden(msg)
+	}
+
+	name := names[msg.Sender]
+	if name == "" {
+		name = "Пользователь " + strconv.Itoa(msg.Sender)
+	}
+
+	exported := &models.ChatExportMessage{
+		ID:         msg.ID,
+		TimeStamp:  msg.TimeStamp,
+		Sender:     msg.Sender,
+		SenderName: name,
+		Text:       msg.Text,
+		ReplyTo:    msg.ReplyTo,
+		EditedAt:   msg.EditedAt,
+		Deleted:    msg.Deleted,
+		Hidden:     msg.Hidden,
+	}
+
+	for _, attachment := range msg.Attachments {
+		exported.Attachments = append(exported.Attachments, &models.ChatExportAttachment{
+			FileName: attachment.FileName,
+			Size:     attachment.Size,
+			URL:      attachmentURL(msg.ChannelID, attachment.ID),
+		})
+	}
+
+	return exported
+}
+
+// ExportChat streams full history of chat to w in format, messages are read
+// by cursor and written one by one. Text of hidden messages is exported if
+// showHidden is set.
+func (c *CentrifugoV1) ExportChat(chat *models.ChatChannel, showHidden bool, format string, w io.Writer) error {
+	writer, err := newExportWriter(format, w)
+	if err != nil {
+		return err
+	}
+
+	names, err := c.senderNames(chat.ID)
+	if err != nil {
+		return err
+	}
+
+	cursor, err := c.messagesDB().Find(
+		context.TODO(),
+		bson.M{"channel_id": chat.ID},
+		options.Find().SetSort(bson.D{{Key: "id", Value: 1}}),
+	)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.TODO())
+
+	err = writer.begin(&chatExport{ChatID: chat.ID, Title: c.chatTitle(chat), ExportedAt: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	flusher, _ := w.(http.Flusher)
+
+	for count := 1; cursor.Next(context.TODO()); count++ {
+		msg := &models.Message{}
+
+		err = cursor.Decode(msg)
+		if err != nil {
+			return err
+		}
+
+		err = writer.message(exportMessage(msg, names, showHidden))
+		if err != nil {
+			return err
+		}
+
+		if flusher != nil && count%exportFlushEvery == 0 {
+			flusher.Flush()
+		}
+	}
+
+	if err = cursor.Err(); err != nil {
+		return err
+	}
+
+	return writer.end()
+}
@@ -1,43 +1,74 @@
 package centrifugov1
 
 import (
-	"bytes"
-	"container/list"
-	"encoding/json"
 	"errors"
-	"net/http"
 	"strconv"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo/v4"
 	"github.com/rs/zerolog"
+	likev1 "github.com/sqsinformatique/rosseti-innovation-back/domains/like/v1"
+	moderationv1 "github.com/sqsinformatique/rosseti-innovation-back/domains/moderation/v1"
+	notificationv1 "github.com/sqsinformatique/rosseti-innovation-back/domains/notification/v1"
+	searchv1 "github.com/sqsinformatique/rosseti-innovation-back/domains/search/v1"
 	sessionv1 "github.com/sqsinformatique/rosseti-innovation-back/domains/session/v1"
+	userv1 "github.com/sqsinformatique/rosseti-innovation-back/domains/user/v1"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/centrifugo"
 	"github.com/sqsinformatique/rosseti-innovation-back/internal/cfg"
 	"github.com/sqsinformatique/rosseti-innovation-back/internal/context"
 	"github.com/sqsinformatique/rosseti-innovation-back/internal/httpsrv"
 	"github.com/sqsinformatique/rosseti-innovation-back/internal/orm"
 	"github.com/sqsinformatique/rosseti-innovation-back/models"
+	"github.com/sqsinformatique/rosseti-innovation-back/types"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type empty struct{}
 
+// Unread counts are pushed by a fixed number of workers, pushes above queue
+// size are dropped and clients get counts on the next change of chat
+const (
+	unreadWorkers   = 4
+	unreadQueueSize = 1024
+)
+
+// unreadPush is a chat whose unread counts must be sent to readers
+type unreadPush struct {
+	chatID   int
+	senderID int
+}
+
 type CentrifugoV1 struct {
-	log                 zerolog.Logger
-	privateV1           *echo.Group
-	publicV1            *echo.Group
-	sessionV1           *sessionv1.SessionV1
-	config              *cfg.AppCfg
-	orm                 *orm.ORM
-	mongoDB             **mongo.Client
-	db                  **sqlx.DB
-	lastActiveThemes    list.List
-	lastActiveThemesMap map[string]string
+	log          zerolog.Logger
+	privateV1    *echo.Group
+	publicV1     *echo.Group
+	sessionV1    *sessionv1.SessionV1
+	userV1       *userv1.UserV1
+	likeV1       *likev1.LikeV1
+	notifier     *notificationv1.NotificationV1
+	moderationV1 *moderationv1.ModerationV1
+	searchV1     *searchv1.SearchV1
+	centrifugo   centrifugo.Client
+	config       *cfg.AppCfg
+	orm          *orm.ORM
+	mongoDB      **mongo.Client
+	db           **sqlx.DB
+	unreadQueue  chan unreadPush
+	channels     channelsCache
 }
 
-func NewCentrifugoV1(ctx *context.Context, orm *orm.ORM, sessionV1 *sessionv1.SessionV1) (*CentrifugoV1, error) {
-	if ctx == nil {
-		return nil, errors.New("empty context or orm client")
+func NewCentrifugoV1(ctx *context.Context,
+	orm *orm.ORM,
+	sessionV1 *sessionv1.SessionV1,
+	userV1 *userv1.UserV1,
+	likeV1 *likev1.LikeV1,
+	notificationV1 *notificationv1.NotificationV1,
+	moderationV1 *moderationv1.ModerationV1,
+	searchV1 *searchv1.SearchV1,
+	centrifugoClient centrifugo.Client,
+) (*CentrifugoV1, error) {
+	if ctx == nil || sessionV1 == nil || userV1 == nil || likeV1 == nil || notificationV1 == nil || moderationV1 == nil || searchV1 == nil || centrifugoClient == nil {
+		return nil, errors.New("empty context or sessionV1 or userV1 or likeV1 or notificationV1 or moderationV1 or searchV1 client or centrifugo client")
 	}
 
 	c := &CentrifugoV1{}
@@ -45,94 +76,299 @@ func NewCentrifugoV1(ctx *context.Context, orm *orm.ORM, sessionV1 *sessionv1.Se
 	c.privateV1 = ctx.GetHTTPGroup(httpsrv.PrivateSrv, httpsrv.V1)
 	c.publicV1 = ctx.GetHTTPGroup(httpsrv.PublicSrv, httpsrv.V1)
 	c.sessionV1 = sessionV1
+	c.userV1 = userV1
+	c.likeV1 = likeV1
+	c.notifier = notificationV1
+	c.moderationV1 = moderationV1
+	c.searchV1 = searchV1
+	c.centrifugo = centrifugoClient
 	c.config = ctx.Config
 	c.mongoDB = ctx.GetMongoDB()
 	c.orm = orm
 	c.db = ctx.GetDatabase()
+	c.unreadQueue = make(chan unreadPush, unreadQueueSize)
 
-	c.lastActiveThemesMap = make(map[string]string)
+	for i := 0; i < unreadWorkers; i++ {
+		go c.unreadWorker()
+	}
+
+	ctx.RegisterMongoMigration(c.migrateChats)
+	ctx.RegisterMongoMigration(c.backfillThemeActivity)
+	c.searchV1.RegisterReindexer(c.reindexMessages)
+	c.sessionV1.RegisterRevokeHook(c.disconnectUser)
 
 	c.privateV1.POST("/centrifugo/connect", c.AuthConnectHandler)
+	c.privateV1.POST("/centrifugo/subscribe", c.SubscribeProxyHandler)
+	c.privateV1.POST("/centrifugo/refresh", c.RefreshProxyHandler)
 	c.publicV1.POST("/centrifugo/publish", c.PublishHandler)
-	c.publicV1.GET("/centrifugo/chat/:id", c.GetHistoryHandler)
-	c.publicV1.POST("/themes", c.CreateThemeHandler)
+	c.publicV1.POST("/centrifugo/token", c.userV1.Introspect(c.ConnectionTokenHandler, types.User))
+	c.publicV1.POST("/centrifugo/token/subscribe", c.userV1.Introspect(c.SubscriptionTokenHandler, types.User))
+	c.publicV1.GET("/centrifugo/chat/:id", c.userV1.Introspect(c.GetHistoryHandler, types.User))
+	c.publicV1.POST("/centrifugo/chat/:id/attachments", c.userV1.Introspect(c.UploadAttachmentHandler, types.User))
+	c.publicV1.GET("/centrifugo/chat/:id/attachments/:attid", c.userV1.Introspect(c.GetAttachmentHandler, types.User))
+	c.publicV1.GET("/centrifugo/chat/:id/attachments/:attid/thumbnail", c.userV1.Introspect(c.GetAttachmentThumbnailHandler, types.User))
+	c.publicV1.GET("/centrifugo/chat/:id/export", c.userV1.Introspect(c.ExportChatHandler, types.User))
+	c.publicV1.PUT("/centrifugo/chat/:id/messages/:msgid", c.userV1.Introspect(c.EditMessageHandler, types.User))
+	c.publicV1.DELETE("/centrifugo/chat/:id/messages/:msgid", c.userV1.Introspect(c.DeleteMessageHandler, types.User))
+	c.publicV1.PUT("/centrifugo/chat/:id/messages/:msgid/reactions/:emoji", c.userV1.Introspect(c.PutReactionHandler, types.User))
+	c.publicV1.DELETE("/centrifugo/chat/:id/messages/:msgid/reactions/:emoji", c.userV1.Introspect(c.DeleteReactionHandler, types.User))
+	c.publicV1.PUT("/centrifugo/chat/:id/messages/:msgid/hidden", c.userV1.Introspect(c.HideMessageHandler, types.Moderator))
+	c.publicV1.DELETE("/centrifugo/chat/:id/messages/:msgid/hidden", c.userV1.Introspect(c.UnhideMessageHandler, types.Moderator))
+	c.publicV1.POST("/chats/:id/read", c.userV1.Introspect(c.ChatReadPostHandler, types.User))
+	c.publicV1.GET("/chats/:id/reads", c.userV1.Introspect(c.ChatReadsGetHandler, types.User))
+	c.publicV1.POST("/themes", c.userV1.Introspect(c.CreateThemeHandler, types.User))
+	c.publicV1.PUT("/themes/:id", c.userV1.Introspect(c.UpdateThemeHandler, types.User))
+	c.publicV1.DELETE("/themes/:id", c.userV1.Introspect(c.DeleteThemeHandler, types.User))
+	c.publicV1.PUT("/themes/:id/archive", c.userV1.Introspect(c.ArchiveThemeHandler, types.User))
+	c.publicV1.DELETE("/themes/:id/archive", c.userV1.Introspect(c.UnarchiveThemeHandler, types.User))
+	c.publicV1.PUT("/themes/:id/close", c.userV1.Introspect(c.CloseThemeHandler, types.User))
+	c.publicV1.DELETE("/themes/:id/close", c.userV1.Introspect(c.ReopenThemeHandler, types.User))
+	c.publicV1.GET("/themes/:id/presence", c.userV1.Introspect(c.ThemePresenceHandler, types.User))
+	c.publicV1.PUT("/themes/:id/pin", c.userV1.Introspect(c.PinThemeHandler, types.Moderator))
+	c.publicV1.DELETE("/themes/:id/pin", c.userV1.Introspect(c.UnpinThemeHandler, types.Moderator))
 	c.publicV1.GET("/directionsdetailed", c.GetDirectionsDetailedHandler)
 	c.publicV1.GET("/directions", c.GetDirectionsHandler)
+	c.publicV1.POST("/directions", c.userV1.Introspect(c.CreateDirectionHandler, types.Admin))
+	c.publicV1.PUT("/directions/order", c.userV1.Introspect(c.ReorderDirectionsHandler, types.Admin))
+	c.publicV1.PUT("/directions/:id", c.userV1.Introspect(c.UpdateDirectionHandler, types.Admin))
+	c.publicV1.DELETE("/directions/:id", c.userV1.Introspect(c.DeleteDirectionHandler, types.Admin))
+	c.publicV1.PUT("/directions/:id/archive", c.userV1.Introspect(c.ArchiveDirectionHandler, types.Admin))
+	c.publicV1.DELETE("/directions/:id/archive", c.userV1.Introspect(c.UnarchiveDirectionHandler, types.Admin))
 	c.publicV1.GET("/lastactivethems", c.GetLastActiveThemes)
-	c.publicV1.PUT("/themes/:id/like", c.PutLikeThemes)
 
 	return c, nil
 }
 
-func (c *CentrifugoV1) AddLastActive(pub *models.Publish) error {
-	if pub.Type != "theme" {
-		return nil
+// Publish saves message and sends it to channel. Payload keeps message text
+// and sender fields read by clients before chat events were introduced.
+// Message is moderated before it is saved. Chat type is defined by channel
+// namespace, type of publish request is ignored.
+func (c *CentrifugoV1) Publish(pub *models.Publish, user *models.User) error {
+	chatType, channelID, err := c.channelChat(pub.Channel)
+	if err != nil {
+		return err
 	}
 
-	channelID, err := strconv.Atoi(pub.Channel)
+	text, err := c.moderateMessage(channelID, chatType, user, pub.Message)
 	if err != nil {
 		return err
 	}
 
-	c.log.Debug().Msgf("last active theme: %s", pub.Channel)
+	userID := user.ID
 
-	if c.lastActiveThemes.Len() < 10 {
-		c.lastActiveThemes.PushFront(channelID)
-		c.lastActiveThemesMap[pub.Channel] = "saved"
-	} else {
-		e := c.lastActiveThemes.Front()
-		c.lastActiveThemes.Remove(e)
-		delete(c.lastActiveThemesMap, e.Value.(string))
+	attachments, err := c.resolveAttachments(channelID, userID, pub.Attachments)
+	if err != nil {
+		return err
+	}
 
-		c.lastActiveThemes.PushFront(channelID)
-		c.lastActiveThemesMap[pub.Channel] = "saved"
+	msg, err := c.SaveToDB(channelID, userID, "", chatType, text, pub.ReplyTo, attachments)
+	if err != nil {
+		return err
 	}
 
-	return nil
-}
+	err = c.centrifugo.Publish(pub.Channel, map[string]interface{}{
+		"event":       models.ChatEventCreated,
+		"id":          msg.ID,
+		"message":     msg.Text,
+		"sender":      msg.Sender,
+		"timestamp":   msg.TimeStamp,
+		"reply_to":    msg.ReplyTo,
+		"entities":    msg.Entities,
+		"attachments": msg.Attachments,
+	})
+	if err != nil {
+		return err
+	}
 
-func (c *CentrifugoV1) Publish(pub *models.Publish, userID int) error {
-	client := &http.Client{}
+	read, err := c.MarkRead(channelID, userID, msg.ID)
+	if err == nil {
+		err = c.publishUnread(read)
+	}
 
-	centrifugoReq := &models.CentrifugoAPIRequest{
-		Method: "publish",
-		Params: &models.CentrifugoParams{
-			Channel: pub.Channel,
-		},
+	if err != nil {
+		c.log.Warn().Err(err).Msgf("mark own message read failed, channel %s", pub.Channel)
 	}
-	centrifugoReqData := make(map[string]interface{})
-	centrifugoReqData["message"] = pub.Message
-	centrifugoReqData["sender"] = userID
-	centrifugoReq.Params.Data = centrifugoReqData
 
-	jsonData, err := json.Marshal(centrifugoReq)
+	c.queueUnread(channelID, userID)
+
+	c.notifyMessage(chatType, msg)
+	c.notifyMentions(chatType, msg, msg.Mentions)
+
+	if isThemeChat(chatType) {
+		err = c.TouchThemeActivity(channelID, userID, msg.TimeStamp)
+		if err != nil {
+			c.log.Warn().Err(err).Msgf("update theme activity failed, channel %s", pub.Channel)
+		}
+	}
+
+	err = c.searchV1.IndexMessage(strconv.Itoa(channelID), chatType, msg)
 	if err != nil {
-		return err
+		c.log.Warn().Err(err).Msgf("index message failed, channel %s", pub.Channel)
 	}
 
-	req, err := http.NewRequest(http.MethodPost, c.config.Centrifugo.DSN, bytes.NewBuffer(jsonData))
+	return nil
+}
+
+// PublishChange sends changed message to its channel and updates search
+// index, failure of indexing is only logged
+func (c *CentrifugoV1) PublishChange(event string, msg *models.Message) error {
+	channel := strconv.Itoa(msg.ChannelID)
+
+	err := c.centrifugo.Publish(channel, &models.ChatEvent{Event: event, Message: concealHidden(msg)})
 	if err != nil {
 		return err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := client.Do(req)
+	switch event {
+	case models.ChatEventEdited, models.ChatEventUnhidden:
+		chat, err := c.GetChat(msg.ChannelID)
+		if err == nil {
+			err = c.searchV1.IndexMessage(channel, chat.Type, msg)
+		}
+
+		if err != nil {
+			c.log.Warn().Err(err).Msgf("index message failed, channel %s", channel)
+		}
+	case models.ChatEventDeleted, models.ChatEventHidden:
+		err = c.searchV1.RemoveMessage(channel, msg.ID)
+		if err != nil {
+			c.log.Warn().Err(err).Msgf("remove message from index failed, channel %s", channel)
+		}
+
+		if event == models.ChatEventDeleted {
+			c.queueUnread(msg.ChannelID, msg.Sender)
+		}
+	}
+
+	return nil
+}
+
+// disconnectUser closes all connections of user whose session is revoked.
+// Centrifugo can't tell connections of different sessions apart, so the
+// user's other clients are reconnected through connect proxy.
+func (c *CentrifugoV1) disconnectUser(userID int) {
+	err := c.centrifugo.Disconnect(strconv.Itoa(userID))
 	if err != nil {
-		return err
+		c.log.Warn().Err(err).Msgf("disconnect user %d failed", userID)
 	}
+}
+
+// publishUnread sends unread count of chat to personal channel of user
+func (c *CentrifugoV1) publishUnread(read *models.ChatRead) error {
+	return c.centrifugo.Publish(
+		Channel(NamespacePersonal, read.UserID),
+		&models.UnreadEvent{Event: models.ChatEventUnread, ChatRead: read},
+	)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return errors.New("failed handle request on centrinfugo side")
+// queueUnread schedules push of unread counts of chat, push is dropped if
+// workers are behind
+func (c *CentrifugoV1) queueUnread(chatID, senderID int) {
+	select {
+	case c.unreadQueue <- unreadPush{chatID: chatID, senderID: senderID}:
+	default:
+		c.log.Warn().Msgf("unread push queue is full, chat %d", chatID)
 	}
-	channelID, err := strconv.Atoi(pub.Channel)
+}
+
+// unreadWorker pushes queued unread counts
+func (c *CentrifugoV1) unreadWorker() {
+	for push := range c.unreadQueue {
+		c.pushUnread(push.chatID, push.senderID)
+	}
+}
+
+// pushUnread sends new unread counts of chat to users who opened it except
+// sender of changed message, failures are only logged
+func (c *CentrifugoV1) pushUnread(chatID, senderID int) {
+	reads, err := c.GetChatReads(chatID)
 	if err != nil {
-		return err
+		c.log.Warn().Err(err).Msgf("get chat reads failed, chat %d", chatID)
+		return
+	}
+
+	for _, read := range reads {
+		if read.UserID == senderID {
+			continue
+		}
+
+		read.Unread, err = c.unreadCount(chatID, read.UserID, read.LastRead)
+		if err == nil {
+			err = c.publishUnread(read)
+		}
+
+		if err != nil {
+			c.log.Warn().Err(err).Msgf("push unread count failed, chat %d user %d", chatID, read.UserID)
+		}
+	}
+}
+
+// notifyMessage tells sender of replied message about reply and author of
+// innovation about comment in its discussion
+func (c *CentrifugoV1) notifyMessage(chatType string, msg *models.Message) {
+	data := map[string]interface{}{
+		"channel_id": msg.ChannelID,
+		"message_id": msg.ID,
 	}
 
-	err = c.SaveToDB(channelID, userID, "", pub.Message)
+	if msg.ReplyTo != 0 {
+		replied, err := c.GetMessage(msg.ChannelID, msg.ReplyTo)
+		if err != nil {
+			c.log.Warn().Err(err).Msgf("get replied message failed, chat %d message %d", msg.ChannelID, msg.ReplyTo)
+		} else if replied.Sender != msg.Sender && !replied.Deleted {
+			data["reply_to"] = msg.ReplyTo
+			c.notifier.Notify(replied.Sender, models.NotificationChatReply, "Ответ на ваше сообщение", data)
+		}
+	}
+
+	if chatType != NamespaceInnovation {
+		return
+	}
+
+	access, err := c.innovationAccess(msg.ChannelID, msg.Sender)
 	if err != nil {
-		return err
+		c.log.Warn().Err(err).Msgf("get innovation %d failed", msg.ChannelID)
+		return
 	}
 
-	return nil
+	if access.AuthorID != msg.Sender {
+		data["innovation_id"] = msg.ChannelID
+		c.notifier.Notify(access.AuthorID, models.NotificationInnovationComment, "Новый комментарий к вашему предложению", data)
+	}
+}
+
+// moderateMessage checks that user may post to chat and filters stop words
+// of text. Theme chat must be open and user must not be muted or banned in
+// it, chatType must come from the authorized channel.
+func (c *CentrifugoV1) moderateMessage(chatID int, chatType string, user *models.User, text string) (string, error) {
+	if isThemeChat(chatType) {
+		err := c.themePostable(chatID)
+		if err != nil {
+			return "", err
+		}
+
+		err = c.moderationV1.CheckPost(chatID, user)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return c.moderationV1.FilterText(text)
+}
+
+// concealHidden returns copy of hidden message without text, message which
+// is not hidden is returned as is
+func concealHidden(msg *models.Message) *models.Message {
+	if !msg.Hidden {
+		return msg
+	}
+
+	concealed := *msg
+	concealed.Text = ""
+	concealed.Edits = nil
+	concealed.Mentions = nil
+	concealed.Entities = nil
+	concealed.Attachments = nil
+
+	return &concealed
 }
@@ -0,0 +1,76 @@
+package centrifugov1
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/centrifugo/centrifugotest"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+)
+
+func newTestCentrifugo(fake *centrifugotest.Fake) *CentrifugoV1 {
+	return &CentrifugoV1{log: zerolog.Nop(), centrifugo: fake}
+}
+
+func TestPublishUnread(t *testing.T) {
+	fake := centrifugotest.NewFake()
+	c := newTestCentrifugo(fake)
+
+	err := c.publishUnread(&models.ChatRead{UserID: 10, ChannelID: 1, Unread: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := fake.History(Channel(NamespacePersonal, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(history) != 1 {
+		t.Fatalf("%d publications in personal channel, expected 1", len(history))
+	}
+
+	event := &models.UnreadEvent{}
+	if err = json.Unmarshal(history[0].Data, event); err != nil {
+		t.Fatal(err)
+	}
+
+	if event.Event != models.ChatEventUnread || event.ChatRead == nil || event.ChannelID != 1 || event.Unread != 3 {
+		t.Errorf("published %s, expected unread event of chat 1", history[0].Data)
+	}
+}
+
+func TestDisconnectUser(t *testing.T) {
+	fake := centrifugotest.NewFake()
+	fake.Subscribe("1", "10", "a")
+	fake.Subscribe("1", "20", "b")
+
+	c := newTestCentrifugo(fake)
+	c.disconnectUser(10)
+
+	presence, err := fake.Presence("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(presence) != 1 || presence["b"].User != "20" {
+		t.Errorf("presence %v, expected only user 20", presence)
+	}
+}
+
+func TestQueueUnreadFull(t *testing.T) {
+	c := newTestCentrifugo(centrifugotest.NewFake())
+	c.unreadQueue = make(chan unreadPush, 1)
+
+	c.queueUnread(1, 10)
+	c.queueUnread(2, 10)
+
+	if len(c.unreadQueue) != 1 {
+		t.Fatalf("%d pushes queued, expected 1", len(c.unreadQueue))
+	}
+
+	if push := <-c.unreadQueue; push.chatID != 1 || push.senderID != 10 {
+		t.Errorf("queued %+v, expected the first push", push)
+	}
+}
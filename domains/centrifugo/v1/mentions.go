@@ -0,0 +1,205 @@
+package centrifugov1
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/db"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+)
+
+// maxMentions limits users resolved and notified by one message
+const maxMentions = 20
+
+// Mention is "@handle" where handle is a part of user email before "@",
+// e.g. "@ivanov" mentions ivanov@rosseti.ru
+var mentionRegexp = regexp.MustCompile(`@([\p{L}\p{N}_.+-]+)`)
+
+// mention is a handle found in message text, start and end are byte offsets
+// of "@handle"
+type mention struct {
+	handle string
+	start  int
+	end    int
+}
+
+// parseMentions finds mentions in text. Address inside email or word is not
+// a mention, trailing punctuation is not a part of handle.
+func parseMentions(text string) []*mention {
+	mentions := []*mention{}
+
+	for _, match := range mentionRegexp.FindAllStringSubmatchIndex(text, -1) {
+		if match[0] > 0 {
+			prev, _ := utf8.DecodeLastRuneInString(text[:match[0]])
+			if unicode.IsLetter(prev) || unicode.IsDigit(prev) || prev == '_' {
+				continue
+			}
+		}
+
+		handle := strings.TrimRight(text[match[2]:match[3]], ".-+")
+		if handle == "" {
+			continue
+		}
+
+		mentions = append(mentions, &mention{
+			handle: strings.ToLower(handle),
+			start:  match[0],
+			end:    match[2] + len(handle),
+		})
+	}
+
+	return mentions
+}
+
+// mentionedProfile is user resolved by mention handle
+type mentionedProfile struct {
+	ID        int    `db:"id"`
+	Handle    string `db:"handle"`
+	FirstName string `db:"user_first_name"`
+	LastName  string `db:"user_last_name"`
+}
+
+func (p *mentionedProfile) name() string {
+	return strings.TrimSpace(p.FirstName + " " + p.LastName)
+}
+
+// resolveMentions returns profiles by handles, handle shared by several
+// users is ambiguous and not resolved
+func (c *CentrifugoV1) resolveMentions(handles []string) (map[string]*mentionedProfile, error) {
+	conn := *c.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	query, args, err := sqlx.In(`
+		select u.id, lower(split_part(u.user_email, '@', 1)) as handle,
+			coalesce(p.user_first_name, '') as user_first_name, coalesce(p.user_last_name, '') as user_last_name
+		from production.users u
+		left join production.profiles p on p.id = u.id and p.deleted_at is null
+		where u.deleted_at is null and lower(split_part(u.user_email, '@', 1)) in (?)`, handles)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.Queryx(conn.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profiles := make(map[string]*mentionedProfile)
+	ambiguous := make(map[string]bool)
+
+	for rows.Next() {
+		profile := &mentionedProfile{}
+
+		err = rows.StructScan(profile)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := profiles[profile.Handle]; ok {
+			ambiguous[profile.Handle] = true
+		}
+
+		profiles[profile.Handle] = profile
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for handle := range ambiguous {
+		delete(profiles, handle)
+	}
+
+	return profiles, nil
+}
+
+// mentionEntities resolves mentions of text to entities and IDs of mentioned
+// users, unknown handles are left as plain text
+func (c *CentrifugoV1) mentionEntities(text string) ([]*models.MessageEntity, []int, error) {
+	mentions := parseMentions(text)
+	if len(mentions) == 0 {
+		return nil, nil, nil
+	}
+
+	handles := []string{}
+	seen := make(map[string]bool)
+
+	for _, m := range mentions {
+		if !seen[m.handle] && len(handles) < maxMentions {
+			seen[m.handle] = true
+			handles = append(handles, m.handle)
+		}
+	}
+
+	profiles, err := c.resolveMentions(handles)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entities := []*models.MessageEntity{}
+	userIDs := []int{}
+	mentioned := make(map[int]bool)
+
+	for _, m := range mentions {
+		profile, ok := profiles[m.handle]
+		if !ok {
+			continue
+		}
+
+		entities = append(entities, &models.MessageEntity{
+			Type:   models.MessageEntityMention,
+			Offset: utf8.RuneCountInString(text[:m.start]),
+			Length: utf8.RuneCountInString(text[m.start:m.end]),
+			UserID: profile.ID,
+			Name:   profile.name(),
+		})
+
+		if !mentioned[profile.ID] {
+			mentioned[profile.ID] = true
+			userIDs = append(userIDs, profile.ID)
+		}
+	}
+
+	if len(entities) == 0 {
+		return nil, nil, nil
+	}
+
+	return entities, userIDs, nil
+}
+
+// notifyMentions tells mentioned users about message, users who can't read
+// the chat and sender himself are skipped
+func (c *CentrifugoV1) notifyMentions(chatType string, msg *models.Message, userIDs []int) {
+	channel := strconv.Itoa(msg.ChannelID)
+	if chatType != "" {
+		channel = Channel(chatType, msg.ChannelID)
+	}
+
+	for _, userID := range userIDs {
+		if userID == msg.Sender {
+			continue
+		}
+
+		user, err := c.proxyUser(strconv.Itoa(userID))
+		if err == nil {
+			err = c.AuthorizeChannel(user, channel)
+		}
+
+		if err != nil {
+			c.log.Debug().Err(err).Msgf("mentioned user %d is not notified, channel %s", userID, channel)
+			continue
+		}
+
+		c.notifier.Notify(userID, models.NotificationChatMention, "Вас упомянули в обсуждении", map[string]interface{}{
+			"channel_id": msg.ChannelID,
+			"message_id": msg.ID,
+		})
+	}
+}
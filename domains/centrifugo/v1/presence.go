@@ -0,0 +1,179 @@
+package centrifugov1
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+)
+
+// activeChannelsTTL is how long list of channels with subscribers is reused
+// by theme lists, online counts lag behind by this time at most
+const activeChannelsTTL = 5 * time.Second
+
+// channelsCache keeps the last list of channels with subscribers, zero
+// value is an empty cache
+type channelsCache struct {
+	mu        sync.Mutex
+	active    map[string]bool
+	fetchedAt time.Time
+}
+
+// hasPresence tells whether presence and join/leave events are enabled for
+// channel in centrifugo config
+func hasPresence(channel string) bool {
+	namespace, _, err := parseChannel(channel)
+	if err != nil {
+		return false
+	}
+
+	switch namespace {
+	case "", NamespaceTheme, NamespaceInnovation, NamespaceExpert:
+		return true
+	}
+
+	return false
+}
+
+// themeChannels returns all channels clients use for theme chat: legacy
+// channel without namespace, theme channel and its private version
+func themeChannels(themeID int) []string {
+	channel := Channel(NamespaceTheme, themeID)
+	return []string{strconv.Itoa(themeID), channel, privatePrefix + channel}
+}
+
+// onlineClients counts connections of users subscribed to channels,
+// anonymous connections are skipped
+func (c *CentrifugoV1) onlineClients(channels []string) (map[int]int, error) {
+	clients := make(map[int]int)
+
+	for _, channel := range channels {
+		presence, err := c.centrifugo.Presence(channel)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, info := range presence {
+			userID, err := strconv.Atoi(info.User)
+			if err != nil {
+				continue
+			}
+
+			clients[userID]++
+		}
+	}
+
+	return clients, nil
+}
+
+// ThemePresence returns users subscribed to theme chat with names and
+// positions from profiles
+func (c *CentrifugoV1) ThemePresence(themeID int) (*models.ThemePresence, error) {
+	clients, err := c.onlineClients(themeChannels(themeID))
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, 0, len(clients))
+	for userID := range clients {
+		ids = append(ids, userID)
+	}
+
+	cards, err := c.profileCards(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	presence := &models.ThemePresence{
+		ThemeID: themeID,
+		Users:   make([]*models.PresenceUser, 0, len(ids)),
+	}
+
+	for _, userID := range ids {
+		user, ok := cards[userID]
+		if !ok {
+			user = &models.PresenceUser{ID: userID}
+		}
+
+		user.Clients = clients[userID]
+
+		presence.Users = append(presence.Users, user)
+		presence.NumClients += user.Clients
+	}
+
+	presence.NumUsers = len(presence.Users)
+
+	sort.Slice(presence.Users, func(i, j int) bool {
+		if presence.Users[i].Name != presence.Users[j].Name {
+			return presence.Users[i].Name < presence.Users[j].Name
+		}
+
+		return presence.Users[i].ID < presence.Users[j].ID
+	})
+
+	return presence, nil
+}
+
+// activeChannels returns channels with subscribers, list is asked from
+// centrifugo once in activeChannelsTTL
+func (c *CentrifugoV1) activeChannels() (map[string]bool, error) {
+	c.channels.mu.Lock()
+	defer c.channels.mu.Unlock()
+
+	if c.channels.active != nil && time.Since(c.channels.fetchedAt) < activeChannelsTTL {
+		return c.channels.active, nil
+	}
+
+	channels, err := c.centrifugo.Channels()
+	if err != nil {
+		return nil, err
+	}
+
+	active := make(map[string]bool, len(channels))
+	for _, channel := range channels {
+		active[channel] = true
+	}
+
+	c.channels.active, c.channels.fetchedAt = active, time.Now()
+
+	return active, nil
+}
+
+// markOnline sets count of users reading every theme. Presence is asked
+// for channels with subscribers only, centrifugo failure leaves zero
+// counts.
+func (c *CentrifugoV1) markOnline(themes []*models.Theme) {
+	if len(themes) == 0 {
+		return
+	}
+
+	active, err := c.activeChannels()
+	if err != nil {
+		c.log.Warn().Err(err).Msg("get active channels failed")
+		return
+	}
+
+	for _, theme := range themes {
+		themeActive := []string{}
+
+		for _, channel := range themeChannels(theme.ID) {
+			if active[channel] {
+				themeActive = append(themeActive, channel)
+			}
+		}
+
+		if len(themeActive) == 0 {
+			continue
+		}
+
+		clients, err := c.onlineClients(themeActive)
+		if err != nil {
+			c.log.Warn().Err(err).Msgf("get presence failed, theme %d", theme.ID)
+			continue
+		}
+
+		theme.Online = len(clients)
+	}
+}
@@ -0,0 +1,91 @@
+package centrifugov1
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/centrifugo/centrifugotest"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+)
+
+func TestOnlineClients(t *testing.T) {
+	fake := centrifugotest.NewFake()
+	fake.Subscribe("1", "10", "a")
+	fake.Subscribe(Channel(NamespaceTheme, 1), "10", "b")
+	fake.Subscribe(Channel(NamespaceTheme, 1), "20", "c")
+	fake.Subscribe(Channel(NamespaceTheme, 1), "", "anonymous")
+
+	c := newTestCentrifugo(fake)
+
+	clients, err := c.onlineClients(themeChannels(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(clients) != 2 || clients[10] != 2 || clients[20] != 1 {
+		t.Errorf("clients %v, expected 2 connections of user 10 and 1 of user 20", clients)
+	}
+}
+
+func TestMarkOnline(t *testing.T) {
+	fake := centrifugotest.NewFake()
+	fake.Subscribe(Channel(NamespaceTheme, 1), "10", "a")
+	fake.Subscribe(privatePrefix+Channel(NamespaceTheme, 1), "20", "b")
+
+	c := newTestCentrifugo(fake)
+
+	themes := []*models.Theme{{ID: 1}, {ID: 2}}
+	c.markOnline(themes)
+
+	if themes[0].Online != 2 || themes[1].Online != 0 {
+		t.Errorf("online %d and %d, expected 2 and 0", themes[0].Online, themes[1].Online)
+	}
+
+	// Presence of channels without subscribers is not asked
+	for _, call := range fake.CallsOf("presence") {
+		if call.Channels[0] != Channel(NamespaceTheme, 1) && call.Channels[0] != privatePrefix+Channel(NamespaceTheme, 1) {
+			t.Errorf("presence of inactive channel %s is asked", call.Channels[0])
+		}
+	}
+}
+
+func TestMarkOnlineFailure(t *testing.T) {
+	fake := centrifugotest.NewFake()
+	fake.Subscribe(Channel(NamespaceTheme, 1), "10", "a")
+	fake.Err = errors.New("centrifugo is down")
+
+	c := newTestCentrifugo(fake)
+
+	themes := []*models.Theme{{ID: 1}}
+	c.markOnline(themes)
+
+	if themes[0].Online != 0 {
+		t.Errorf("online %d on centrifugo failure, expected 0", themes[0].Online)
+	}
+}
+
+func TestActiveChannelsCached(t *testing.T) {
+	fake := centrifugotest.NewFake()
+	fake.Subscribe("1", "10", "a")
+
+	c := newTestCentrifugo(fake)
+
+	if _, err := c.activeChannels(); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.Err = errors.New("centrifugo is down")
+
+	active, err := c.activeChannels()
+	if err != nil {
+		t.Fatalf("cached channels are not reused: %v", err)
+	}
+
+	if !active["1"] {
+		t.Errorf("active channels %v, expected channel 1", active)
+	}
+
+	if calls := fake.CallsOf("channels"); len(calls) != 1 {
+		t.Errorf("channels asked %d times, expected once", len(calls))
+	}
+}
@@ -2,190 +2,1626 @@ package centrifugov1
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/sqsinformatique/rosseti-innovation-back/internal/db"
 	"github.com/sqsinformatique/rosseti-innovation-back/models"
+	"github.com/sqsinformatique/rosseti-innovation-back/types"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+const (
+	historyLimit    = 50
+	historyMaxLimit = 200
+
+	activeThemesLimit    = 10
+	activeThemesMaxLimit = 100
+)
+
+var (
+	ErrBadHistoryCursor = errors.New("only one of before and after cursors is allowed")
+	ErrEmptyMessage     = errors.New("empty message")
+	ErrBadReplyTo       = errors.New("replied message not found in channel")
+	ErrMessageNotFound  = errors.New("message not found")
+	ErrChatNotFound     = errors.New("chat has no messages")
+	ErrMessageDeleted   = errors.New("message is deleted")
+	ErrMessageHidden    = errors.New("message is hidden by moderator")
+	ErrMessageChanged   = errors.New("message was changed concurrently")
+	ErrNotMessageSender = errors.New("message belongs to another user")
+	ErrBadReaction      = errors.New("reaction must be an emoji")
+
+	ErrThemeNotFound      = errors.New("theme not found")
+	ErrNotThemeAuthor     = errors.New("theme belongs to another user")
+	ErrThemeClosed        = errors.New("theme is closed")
+	ErrThemeArchived      = errors.New("theme is archived")
+	ErrEmptyTitle         = errors.New("empty title")
+	ErrDirectionNotFound  = errors.New("direction not found")
+	ErrDirectionArchived  = errors.New("direction is archived")
+	ErrBadDirectionIcon   = errors.New("direction icon must be a string")
+	ErrBadDirectionsOrder = errors.New("order must list every direction once")
+)
+
+// Reaction is a single emoji, possibly composed of several code points
+const maxReactionLength = 32
+
 func (c *CentrifugoV1) chatsDB() *mongo.Collection {
 	mongoconn := *c.mongoDB
 	return mongoconn.Database(c.config.Mongo.ChatDB).Collection("chats")
 }
 
-func (c *CentrifugoV1) GetChat(id int) (*models.ChatChannel, error) {
-	filter := bson.D{{"id", id}}
+func (c *CentrifugoV1) messagesDB() *mongo.Collection {
+	mongoconn := *c.mongoDB
+	return mongoconn.Database(c.config.Mongo.ChatDB).Collection("messages")
+}
+
+func (c *CentrifugoV1) readsDB() *mongo.Collection {
+	mongoconn := *c.mongoDB
+	return mongoconn.Database(c.config.Mongo.ChatDB).Collection("reads")
+}
+
+func isDuplicateKey(err error) bool {
+	var writeException mongo.WriteException
+	if errors.As(err, &writeException) {
+		for _, writeError := range writeException.WriteErrors {
+			if writeError.Code == 11000 {
+				return true
+			}
+		}
+	}
+
+	var commandError mongo.CommandError
+	if errors.As(err, &commandError) {
+		return commandError.Code == 11000
+	}
+
+	return false
+}
+
+func (c *CentrifugoV1) GetChat(id int) (*models.ChatChannel, error) {
+	filter := bson.D{{Key: "id", Value: id}}
+
+	var result models.ChatChannel
+	err := c.chatsDB().FindOne(context.TODO(), filter).Decode(&result)
+	if err != nil {
+		c.log.Debug().Err(err).Msg("failed GetChat")
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// nextMessageID atomically allocates message ID in channel, channel is
+// created on the first message
+func (c *CentrifugoV1) nextMessageID(chatID int, name, chatType string) (int, error) {
+	var chatChannel models.ChatChannel
+
+	update := func() error {
+		return c.chatsDB().FindOneAndUpdate(
+			context.TODO(),
+			bson.M{"id": chatID},
+			bson.M{
+				"$inc":         bson.M{"lastid": 1},
+				"$setOnInsert": bson.M{"name": name, "type": chatType},
+			},
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+		).Decode(&chatChannel)
+	}
+
+	err := update()
+	// Concurrent upsert of the same new channel fails on unique index,
+	// the channel exists now and the update succeeds
+	if isDuplicateKey(err) {
+		err = update()
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return chatChannel.LastMsgID, nil
+}
+
+func (c *CentrifugoV1) SaveToDB(chatID, userID int, name, chatType, message string, replyTo int,
+	attachments []*models.Attachment) (*models.Message, error) {
+	if strings.TrimSpace(message) == "" && len(attachments) == 0 {
+		return nil, ErrEmptyMessage
+	}
+
+	if replyTo != 0 {
+		_, err := c.GetMessage(chatID, replyTo)
+		if errors.Is(err, ErrMessageNotFound) {
+			return nil, ErrBadReplyTo
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	entities, mentions, err := c.mentionEntities(message)
+	if err != nil {
+		c.log.Warn().Err(err).Msgf("resolve mentions failed, chat %d", chatID)
+	}
+
+	msgID, err := c.nextMessageID(chatID, name, chatType)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &models.Message{
+		ChannelID:   chatID,
+		ID:          msgID,
+		Sender:      userID,
+		Text:        message,
+		TimeStamp:   time.Now(),
+		ReplyTo:     replyTo,
+		Mentions:    mentions,
+		Entities:    entities,
+		Attachments: attachments,
+	}
+
+	err = c.bindAttachments(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = c.messagesDB().InsertOne(context.TODO(), msg)
+	if err != nil {
+		c.unbindAttachments(msg)
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+func (c *CentrifugoV1) hasMessages(chatID int, idFilter bson.M) (bool, error) {
+	count, err := c.messagesDB().CountDocuments(
+		context.TODO(),
+		bson.M{"channel_id": chatID, "id": idFilter},
+		options.Count().SetLimit(1),
+	)
+
+	return count > 0, err
+}
+
+// GetChatHistory returns page of messages in ascending order. Messages
+// older than before or newer than after are returned, the latest ones if
+// both cursors are zero.
+func (c *CentrifugoV1) GetChatHistory(chatID, before, after, limit int) (*models.ChatHistory, error) {
+	if before > 0 && after > 0 {
+		return nil, ErrBadHistoryCursor
+	}
+
+	if limit <= 0 {
+		limit = historyLimit
+	}
+
+	if limit > historyMaxLimit {
+		limit = historyMaxLimit
+	}
+
+	history := &models.ChatHistory{Messages: []*models.Message{}, PrevCursor: before, NextCursor: after}
+
+	chatChannel, err := c.GetChat(chatID)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	if err == mongo.ErrNoDocuments {
+		chatChannel = &models.ChatChannel{ID: chatID}
+	}
+
+	history.Channel = chatChannel
+
+	filter := bson.M{"channel_id": chatID}
+	sort := -1
+
+	switch {
+	case after > 0:
+		filter["id"] = bson.M{"$gt": after}
+		sort = 1
+	case before > 0:
+		filter["id"] = bson.M{"$lt": before}
+	}
+
+	cursor, err := c.messagesDB().Find(
+		context.TODO(),
+		filter,
+		options.Find().SetSort(bson.D{{Key: "id", Value: sort}}).SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	err = cursor.All(context.TODO(), &history.Messages)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(history.Messages) == 0 {
+		return history, nil
+	}
+
+	if sort < 0 {
+		for i, j := 0, len(history.Messages)-1; i < j; i, j = i+1, j-1 {
+			history.Messages[i], history.Messages[j] = history.Messages[j], history.Messages[i]
+		}
+	}
+
+	for i, msg := range history.Messages {
+		history.Messages[i] = concealHidden(msg)
+	}
+
+	history.PrevCursor = history.Messages[0].ID
+	history.NextCursor = history.Messages[len(history.Messages)-1].ID
+
+	history.HasPrev, err = c.hasMessages(chatID, bson.M{"$lt": history.PrevCursor})
+	if err != nil {
+		return nil, err
+	}
+
+	history.HasNext, err = c.hasMessages(chatID, bson.M{"$gt": history.NextCursor})
+	if err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// legacyChatChannel is a channel document which keeps messages in array
+type legacyChatChannel struct {
+	ID       int               `bson:"id"`
+	Messages []*models.Message `bson:"messages"`
+}
+
+// migrateChat moves messages of legacy channel document to messages
+// collection. Messages are matched by content, so interrupted migration
+// can be repeated. Duplicated IDs left by concurrent writes are renumbered.
+func (c *CentrifugoV1) migrateChat(chat *legacyChatChannel) error {
+	lastID := 0
+	for _, msg := range chat.Messages {
+		if msg.ID > lastID {
+			lastID = msg.ID
+		}
+	}
+
+	seen := make(map[int]bool, len(chat.Messages))
+
+	for _, msg := range chat.Messages {
+		if seen[msg.ID] {
+			lastID++
+			msg.ID = lastID
+		}
+
+		seen[msg.ID] = true
+		msg.ChannelID = chat.ID
+
+		_, err := c.messagesDB().UpdateOne(
+			context.TODO(),
+			bson.M{"channel_id": chat.ID, "sender": msg.Sender, "timestamp": msg.TimeStamp, "text": msg.Text},
+			bson.M{"$setOnInsert": msg},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := c.chatsDB().UpdateOne(
+		context.TODO(),
+		bson.M{"id": chat.ID},
+		bson.M{
+			"$unset": bson.M{"messages": ""},
+			"$max":   bson.M{"lastid": lastID},
+		},
+	)
+
+	return err
+}
+
+// migrateChats moves messages out of channel documents and creates indices
+func (c *CentrifugoV1) migrateChats() error {
+	cursor, err := c.chatsDB().Find(context.TODO(), bson.M{"messages": bson.M{"$exists": true}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+		var chat legacyChatChannel
+
+		err = cursor.Decode(&chat)
+		if err != nil {
+			return err
+		}
+
+		c.log.Info().Msgf("migrating %d messages of chat %d", len(chat.Messages), chat.ID)
+
+		err = c.migrateChat(&chat)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = cursor.Err(); err != nil {
+		return err
+	}
+
+	_, err = c.chatsDB().Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.messagesDB().Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "channel_id", Value: 1}, {Key: "id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.readsDB().Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "channel_id", Value: 1}, {Key: "user_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+
+	return err
+}
+
+// reindexMessages puts messages of all stored chats to search index
+func (c *CentrifugoV1) reindexMessages() error {
+	chatTypes := make(map[int]string)
+
+	chats, err := c.chatsDB().Find(context.TODO(), bson.D{})
+	if err != nil {
+		return err
+	}
+	defer chats.Close(context.TODO())
+
+	for chats.Next(context.TODO()) {
+		var chat models.ChatChannel
+
+		err = chats.Decode(&chat)
+		if err != nil {
+			return err
+		}
+
+		chatTypes[chat.ID] = chat.Type
+	}
+
+	if err = chats.Err(); err != nil {
+		return err
+	}
+
+	cursor, err := c.messagesDB().Find(context.TODO(), bson.D{})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+		var msg models.Message
+
+		err = cursor.Decode(&msg)
+		if err != nil {
+			return err
+		}
+
+		if msg.Deleted || msg.Hidden {
+			continue
+		}
+
+		err = c.searchV1.IndexMessage(strconv.Itoa(msg.ChannelID), chatTypes[msg.ChannelID], &msg)
+		if err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+func (c *CentrifugoV1) GetMessage(chatID, msgID int) (*models.Message, error) {
+	msg := &models.Message{}
+
+	err := c.messagesDB().FindOne(context.TODO(), bson.M{"channel_id": chatID, "id": msgID}).Decode(msg)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrMessageNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// updateMessage applies update to message which is not deleted and
+// returns its new state
+func (c *CentrifugoV1) updateMessage(chatID, msgID int, filter, update bson.M) (*models.Message, error) {
+	filter["channel_id"] = chatID
+	filter["id"] = msgID
+	filter["deleted"] = bson.M{"$ne": true}
+
+	msg := &models.Message{}
+
+	err := c.messagesDB().FindOneAndUpdate(
+		context.TODO(),
+		filter,
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(msg)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Find out why message was not matched
+		current, err := c.GetMessage(chatID, msgID)
+		if err != nil {
+			return nil, err
+		}
+
+		if current.Deleted {
+			return nil, ErrMessageDeleted
+		}
+
+		return nil, ErrMessageChanged
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// EditMessage replaces text of user's own message, the previous text is
+// appended to edit history
+func (c *CentrifugoV1) EditMessage(chatID, msgID, userID int, text string) (*models.Message, []int, error) {
+	msg, err := c.GetMessage(chatID, msgID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if strings.TrimSpace(text) == "" && len(msg.Attachments) == 0 {
+		return nil, nil, ErrEmptyMessage
+	}
+
+	if msg.Deleted {
+		return nil, nil, ErrMessageDeleted
+	}
+
+	if msg.Hidden {
+		return nil, nil, ErrMessageHidden
+	}
+
+	if msg.Sender != userID {
+		return nil, nil, ErrNotMessageSender
+	}
+
+	if msg.Text == text {
+		return msg, nil, nil
+	}
+
+	previous := &models.MessageEdit{Text: msg.Text, TimeStamp: msg.TimeStamp}
+	if msg.EditedAt != nil {
+		previous.TimeStamp = *msg.EditedAt
+	}
+
+	entities, mentions, err := c.mentionEntities(text)
+	if err != nil {
+		c.log.Warn().Err(err).Msgf("resolve mentions failed, chat %d", chatID)
+	}
+
+	set := bson.M{"text": text, "edited_at": time.Now()}
+	unset := bson.M{}
+
+	if len(entities) > 0 {
+		set["mentions"], set["entities"] = mentions, entities
+	} else {
+		unset["mentions"], unset["entities"] = "", ""
+	}
+
+	update := bson.M{"$set": set, "$push": bson.M{"edits": previous}}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	// Previous text in filter prevents losing concurrent edit in history
+	edited, err := c.updateMessage(chatID, msgID, bson.M{"text": msg.Text}, update)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return edited, addedMentions(msg.Mentions, mentions), nil
+}
+
+// addedMentions returns users mentioned in current but not in previous
+// version of message
+func addedMentions(previous, current []int) []int {
+	known := make(map[int]bool, len(previous))
+	for _, id := range previous {
+		known[id] = true
+	}
+
+	added := []int{}
+	for _, id := range current {
+		if !known[id] {
+			added = append(added, id)
+		}
+	}
+
+	return added
+}
+
+// DeleteMessage turns message to tombstone, sender or moderator is allowed
+// to delete it
+func (c *CentrifugoV1) DeleteMessage(chatID, msgID int, user *models.User) (*models.Message, error) {
+	msg, err := c.GetMessage(chatID, msgID)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg.Deleted {
+		return nil, ErrMessageDeleted
+	}
+
+	if msg.Sender != user.ID && user.Role < types.Moderator {
+		return nil, ErrNotMessageSender
+	}
+
+	deleted, err := c.updateMessage(chatID, msgID,
+		bson.M{},
+		bson.M{
+			"$set": bson.M{"text": "", "deleted": true, "deleted_at": time.Now(), "deleted_by": user.ID},
+			"$unset": bson.M{
+				"edited_at": "", "edits": "", "reactions": "", "mentions": "", "entities": "", "attachments": "",
+			},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	c.deleteAttachments(msg)
+
+	return deleted, nil
+}
+
+// HideMessage hides message from clients or shows hidden message again,
+// the message is returned with its text for moderation log
+func (c *CentrifugoV1) HideMessage(chatID, msgID, moderatorID int, hidden bool) (*models.Message, error) {
+	update := bson.M{
+		"$set": bson.M{"hidden": true, "hidden_at": time.Now(), "hidden_by": moderatorID},
+	}
+
+	if !hidden {
+		update = bson.M{"$unset": bson.M{"hidden": "", "hidden_at": "", "hidden_by": ""}}
+	}
+
+	return c.updateMessage(chatID, msgID, bson.M{}, update)
+}
+
+// validateReaction accepts short strings of non-ASCII characters only, so
+// reaction can't be an arbitrary text or break mongo field path
+func validateReaction(emoji string) error {
+	if emoji == "" || len(emoji) > maxReactionLength {
+		return ErrBadReaction
+	}
+
+	for _, r := range emoji {
+		if r <= unicode.MaxASCII || r == utf8.RuneError || unicode.IsSpace(r) {
+			return ErrBadReaction
+		}
+	}
+
+	return nil
+}
+
+// ReactToMessage adds or removes user's reaction, every user has at most
+// one reaction of each kind on message
+func (c *CentrifugoV1) ReactToMessage(chatID, msgID, userID int, emoji string, add bool) (*models.Message, error) {
+	err := validateReaction(emoji)
+	if err != nil {
+		return nil, err
+	}
+
+	field := "reactions." + emoji
+
+	if add {
+		return c.updateMessage(chatID, msgID, bson.M{}, bson.M{"$addToSet": bson.M{field: userID}})
+	}
+
+	msg, err := c.updateMessage(chatID, msgID, bson.M{}, bson.M{"$pull": bson.M{field: userID}})
+	if err != nil {
+		return nil, err
+	}
+
+	if users, ok := msg.Reactions[emoji]; ok && len(users) == 0 {
+		// Drop reaction nobody has anymore, concurrent reaction keeps it
+		_, err = c.messagesDB().UpdateOne(
+			context.TODO(),
+			bson.M{"channel_id": chatID, "id": msgID, field: bson.M{"$size": 0}},
+			bson.M{"$unset": bson.M{field: ""}},
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		delete(msg.Reactions, emoji)
+	}
+
+	return msg, nil
+}
+
+// unreadFilter matches messages of other users after read pointer
+func unreadFilter(chatID, userID, lastRead int) bson.M {
+	return bson.M{
+		"channel_id": chatID,
+		"id":         bson.M{"$gt": lastRead},
+		"sender":     bson.M{"$ne": userID},
+		"deleted":    bson.M{"$ne": true},
+	}
+}
+
+// MarkRead moves read pointer of user in chat forward to message, pointer
+// never moves back. Zero msgID means the last message of chat.
+func (c *CentrifugoV1) MarkRead(chatID, userID, msgID int) (*models.ChatRead, error) {
+	chat, err := c.GetChat(chatID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrChatNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if msgID == 0 || msgID > chat.LastMsgID {
+		msgID = chat.LastMsgID
+	}
+
+	read := &models.ChatRead{}
+
+	update := func() error {
+		return c.readsDB().FindOneAndUpdate(
+			context.TODO(),
+			bson.M{"channel_id": chatID, "user_id": userID},
+			bson.M{
+				"$max": bson.M{"last_read": msgID},
+				"$set": bson.M{"updated_at": time.Now()},
+			},
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+		).Decode(read)
+	}
+
+	err = update()
+	// Concurrent upsert of the same pointer fails on unique index, the
+	// pointer exists now and the update succeeds
+	if isDuplicateKey(err) {
+		err = update()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	read.Unread, err = c.unreadCount(chatID, userID, read.LastRead)
+	if err != nil {
+		return nil, err
+	}
+
+	return read, nil
+}
+
+func (c *CentrifugoV1) unreadCount(chatID, userID, lastRead int) (int, error) {
+	count, err := c.messagesDB().CountDocuments(context.TODO(), unreadFilter(chatID, userID, lastRead))
+
+	return int(count), err
+}
+
+// GetChatReads returns read pointers of all users who opened chat
+func (c *CentrifugoV1) GetChatReads(chatID int) ([]*models.ChatRead, error) {
+	cursor, err := c.readsDB().Find(
+		context.TODO(),
+		bson.M{"channel_id": chatID},
+		options.Find().SetSort(bson.D{{Key: "last_read", Value: -1}, {Key: "user_id", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	reads := []*models.ChatRead{}
+
+	err = cursor.All(context.TODO(), &reads)
+	if err != nil {
+		return nil, err
+	}
+
+	return reads, nil
+}
+
+// UnreadCounts returns count of unread messages of user in chats, chat
+// never opened by user has all messages of other users unread
+func (c *CentrifugoV1) UnreadCounts(userID int, chatIDs []int) (map[int]int, error) {
+	counts := make(map[int]int, len(chatIDs))
+	if len(chatIDs) == 0 {
+		return counts, nil
+	}
+
+	lastRead := make(map[int]int, len(chatIDs))
+
+	cursor, err := c.readsDB().Find(context.TODO(), bson.M{"user_id": userID, "channel_id": bson.M{"$in": chatIDs}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+		var read models.ChatRead
+
+		err = cursor.Decode(&read)
+		if err != nil {
+			return nil, err
+		}
+
+		lastRead[read.ChannelID] = read.LastRead
+	}
+
+	if err = cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	filters := make(bson.A, 0, len(chatIDs))
+	for _, chatID := range chatIDs {
+		filters = append(filters, unreadFilter(chatID, userID, lastRead[chatID]))
+	}
+
+	unread, err := c.messagesDB().Aggregate(context.TODO(), mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"$or": filters}}},
+		{{Key: "$group", Value: bson.M{"_id": "$channel_id", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer unread.Close(context.TODO())
+
+	for unread.Next(context.TODO()) {
+		var item struct {
+			ChannelID int `bson:"_id"`
+			Count     int `bson:"count"`
+		}
+
+		err = unread.Decode(&item)
+		if err != nil {
+			return nil, err
+		}
+
+		counts[item.ChannelID] = item.Count
+	}
+
+	return counts, unread.Err()
+}
+
+func (c *CentrifugoV1) CreateTheme(request *models.Theme) (*models.Theme, error) {
+
+	request.CreateTimestamp()
+	// Counter is changed by likes only
+	request.LikeCounter = 0
+
+	result, err := c.orm.InsertInto("theme", request)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(*models.Theme), nil
+}
+
+// SelectAllDirections returns directions in display order, archived ones
+// are returned if includeArchived is set
+func (c *CentrifugoV1) SelectAllDirections(includeArchived bool) (data *ArrayOfDirectionData, err error) {
+	conn := *c.db
+	if c.db == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	rows, err := conn.Queryx(`
+		select * from production.direction
+		where deleted_at is null and ($1 or archived_at is null)
+		order by position, id`, includeArchived)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	data = &ArrayOfDirectionData{}
+
+	for rows.Next() {
+		var item models.Direction
+
+		err = rows.StructScan(&item)
+		if err != nil {
+			return nil, err
+		}
+
+		*data = append(*data, item)
+	}
+
+	return data, nil
+}
+
+func (c *CentrifugoV1) SelectThemesByDirection(id int) (data *ArrayOfThemesData, err error) {
+	conn := *c.db
+	if c.db == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	rows, err := conn.Queryx(conn.Rebind(`
+		select * from production.theme
+		where direction=$1 and deleted_at is null and archived_at is null
+		order by pinned desc, id`), id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	data = &ArrayOfThemesData{}
+
+	for rows.Next() {
+		var item models.Theme
+
+		err = rows.StructScan(&item)
+		if err != nil {
+			return nil, err
+		}
+
+		*data = append(*data, item)
+	}
+
+	return data, nil
+}
+
+func (c *CentrifugoV1) GetTheme(id int) (*models.Theme, error) {
+	conn := *c.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	theme := &models.Theme{}
+
+	err := conn.Get(theme, "select * from production.theme where id=$1 and deleted_at is null", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrThemeNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return theme, nil
+}
+
+// CanManageTheme allows author and moderators to change theme
+func CanManageTheme(user *models.User, theme *models.Theme) error {
+	if theme.AuthorID != user.ID && user.Role < types.Moderator {
+		return ErrNotThemeAuthor
+	}
+
+	return nil
+}
+
+// UpdateTheme changes title, tags, meta and direction of theme, counters
+// and state of theme are changed by their own requests only
+func (c *CentrifugoV1) UpdateTheme(id int, update *models.ThemeUpdate) (*models.Theme, error) {
+	theme, err := c.GetTheme(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Direction != 0 && update.Direction != theme.Direction {
+		direction, err := c.GetDirection(update.Direction)
+		if err != nil {
+			return nil, err
+		}
+
+		if direction.ArchivedAt.Valid {
+			return nil, ErrDirectionArchived
+		}
+
+		theme.Direction = direction.ID
+	}
+
+	if update.Title != "" {
+		theme.Title = update.Title
+	}
+
+	if update.Tags != nil {
+		theme.Tags = *update.Tags
+	}
+
+	if update.Meta != nil {
+		theme.Meta = *update.Meta
+	}
+
+	conn := *c.db
+
+	err = conn.Get(theme, `
+		update production.theme
+		set direction = $2, title = $3, tags = $4, meta = $5, updated_at = now()
+		where id = $1 and deleted_at is null
+		returning *`,
+		id, theme.Direction, theme.Title, theme.Tags, theme.Meta)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrThemeNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return theme, nil
+}
+
+// Theme state changes, assignment is a part of update statement
+const (
+	themeArchive   = "archived_at = now()"
+	themeUnarchive = "archived_at = null"
+	themeClose     = "closed_at = now()"
+	themeReopen    = "closed_at = null"
+	themePin       = "pinned = true"
+	themeUnpin     = "pinned = false"
+	themeDelete    = "deleted_at = now()"
+)
+
+// SetThemeState applies one of theme state assignments
+func (c *CentrifugoV1) SetThemeState(id int, assignment string) (*models.Theme, error) {
+	conn := *c.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	theme := &models.Theme{}
+
+	err := conn.Get(theme, `
+		update production.theme
+		set `+assignment+`, updated_at = now()
+		where id = $1 and deleted_at is null
+		returning *`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrThemeNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return theme, nil
+}
+
+// themePostable returns error if new messages are not accepted in theme
+// chat because theme is closed, archived or deleted. Chats created before
+// themes have no theme and are always postable.
+func (c *CentrifugoV1) themePostable(id int) error {
+	conn := *c.db
+	if conn == nil {
+		return db.ErrDBConnNotEstablished
+	}
+
+	theme := &models.Theme{}
+
+	err := conn.Get(theme, "select * from production.theme where id=$1", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if theme.DeletedAt.Valid {
+		return ErrThemeNotFound
+	}
+
+	if theme.ArchivedAt.Valid {
+		return ErrThemeArchived
+	}
+
+	if theme.ClosedAt.Valid {
+		return ErrThemeClosed
+	}
+
+	return nil
+}
+
+func (c *CentrifugoV1) GetDirection(id int) (*models.Direction, error) {
+	conn := *c.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	direction := &models.Direction{}
+
+	err := conn.Get(direction, "select * from production.direction where id=$1 and deleted_at is null", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDirectionNotFound
+	}
 
-	var result models.ChatChannel
-	err := c.chatsDB().FindOne(context.TODO(), filter).Decode(&result)
 	if err != nil {
-		c.log.Debug().Err(err).Msg("failed GetChat")
 		return nil, err
 	}
 
-	return &result, nil
+	return direction, nil
 }
 
-func (c *CentrifugoV1) SaveToDB(chatID, userID int, name, message string) error {
-	chatChannel, err := c.GetChat(chatID)
-	if err != nil && err != mongo.ErrNoDocuments {
-		return err
+// validateDirection checks title and icon of direction meta
+func validateDirection(direction *models.Direction) error {
+	if strings.TrimSpace(direction.Title) == "" {
+		return ErrEmptyTitle
 	}
 
-	if err == mongo.ErrNoDocuments {
-		c.log.Debug().Msgf("not found chat: %d", chatID)
-		chatChannel = &models.ChatChannel{
-			ID:       chatID,
-			Name:     name,
-			Messages: []*models.Message{},
-		}
-		_, err1 := c.chatsDB().InsertOne(context.TODO(), chatChannel)
-		if err1 != nil {
-			return err1
+	if icon, ok := direction.Meta.Map["icon"]; ok {
+		if _, ok := icon.(string); !ok {
+			return ErrBadDirectionIcon
 		}
 	}
 
-	chatChannel.LastMsgID += 1
-	_, err = c.chatsDB().UpdateOne(
-		context.TODO(),
-		bson.M{"id": chatID},
-		bson.M{"$push": bson.M{"messages": &models.Message{Sender: userID, Text: message, TimeStamp: time.Now(), ID: chatChannel.LastMsgID}}},
-	)
-	if err != nil {
-		return err
+	return nil
+}
+
+// CreateDirection adds direction, direction without position is put after
+// the last one
+func (c *CentrifugoV1) CreateDirection(request *models.Direction) (*models.Direction, error) {
+	if err := validateDirection(request); err != nil {
+		return nil, err
 	}
 
-	_, err = c.chatsDB().UpdateOne(
-		context.TODO(),
-		bson.M{"id": chatID},
-		bson.M{"$set": bson.M{"lastid": chatChannel.LastMsgID}},
-	)
+	conn := *c.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	direction := &models.Direction{}
+
+	err := conn.Get(direction, `
+		insert into production.direction (title, position, meta)
+		values ($1, coalesce(nullif($2, 0), (select coalesce(max(position), 0) + 1 from production.direction)), $3)
+		returning *`,
+		request.Title, request.Position, request.Meta)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return direction, nil
 }
 
-func (c *CentrifugoV1) CreateTheme(request *models.Theme) (*models.Theme, error) {
+// UpdateDirection changes title, position and meta of direction
+func (c *CentrifugoV1) UpdateDirection(id int, request *models.Direction) (*models.Direction, error) {
+	if err := validateDirection(request); err != nil {
+		return nil, err
+	}
 
-	request.CreateTimestamp()
+	conn := *c.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	direction := &models.Direction{}
+
+	err := conn.Get(direction, `
+		update production.direction
+		set title = $2, position = coalesce(nullif($3, 0), position), meta = $4, updated_at = now()
+		where id = $1 and deleted_at is null
+		returning *`,
+		id, request.Title, request.Position, request.Meta)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDirectionNotFound
+	}
 
-	result, err := c.orm.InsertInto("theme", request)
 	if err != nil {
 		return nil, err
 	}
 
-	return result.(*models.Theme), nil
+	return direction, nil
 }
 
-func (c *CentrifugoV1) SelectAllDirections() (data *ArrayOfDirectionData, err error) {
+// ReorderDirections sets positions of directions in order of ids, every
+// direction which is not deleted must be listed
+func (c *CentrifugoV1) ReorderDirections(ids []int) (*ArrayOfDirectionData, error) {
 	conn := *c.db
-	if c.db == nil {
+	if conn == nil {
 		return nil, db.ErrDBConnNotEstablished
 	}
 
-	rows, err := conn.Queryx("select * from production.direction")
+	tx, err := conn.Beginx()
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	defer tx.Rollback() // nolint
 
-	data = &ArrayOfDirectionData{}
+	var count int
 
-	for rows.Next() {
-		var item models.Direction
+	err = tx.Get(&count, "select count(*) from production.direction where deleted_at is null")
+	if err != nil {
+		return nil, err
+	}
 
-		err = rows.StructScan(&item)
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		seen[id] = true
+	}
+
+	if len(ids) != count || len(seen) != count {
+		return nil, ErrBadDirectionsOrder
+	}
+
+	for i, id := range ids {
+		result, err := tx.Exec(`
+			update production.direction set position = $2, updated_at = now()
+			where id = $1 and deleted_at is null`, id, i+1)
 		if err != nil {
 			return nil, err
 		}
 
-		*data = append(*data, item)
+		if updated, err := result.RowsAffected(); err != nil || updated == 0 {
+			return nil, ErrBadDirectionsOrder
+		}
 	}
 
-	return data, nil
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return c.SelectAllDirections(true)
 }
 
-func (c *CentrifugoV1) SelectThemesByDirection(id int) (data *ArrayOfThemesData, err error) {
+// ArchiveDirection archives direction together with its themes, themes
+// get the archive time of direction. Unarchiving restores themes archived
+// with direction only, themes archived by their authors stay archived.
+// Changed themes are returned to update search index.
+func (c *CentrifugoV1) ArchiveDirection(id int, archived bool) (*models.Direction, []*models.Theme, error) {
 	conn := *c.db
-	if c.db == nil {
+	if conn == nil {
+		return nil, nil, db.ErrDBConnNotEstablished
+	}
+
+	tx, err := conn.Beginx()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback() // nolint
+
+	current := &models.Direction{}
+
+	err = tx.Get(current, "select * from production.direction where id = $1 and deleted_at is null for update", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, ErrDirectionNotFound
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	direction := &models.Direction{}
+	themes := []*models.Theme{}
+
+	if archived {
+		err = tx.Get(direction, `
+			update production.direction set archived_at = coalesce(archived_at, now()), updated_at = now()
+			where id = $1
+			returning *`, id)
+		if err == nil {
+			err = tx.Select(&themes, `
+				update production.theme set archived_at = $2, updated_at = now()
+				where direction = $1 and deleted_at is null and archived_at is null
+				returning *`, id, direction.ArchivedAt)
+		}
+	} else {
+		err = tx.Get(direction, `
+			update production.direction set archived_at = null, updated_at = now()
+			where id = $1
+			returning *`, id)
+		if err == nil && current.ArchivedAt.Valid {
+			err = tx.Select(&themes, `
+				update production.theme set archived_at = null, updated_at = now()
+				where direction = $1 and deleted_at is null and archived_at = $2
+				returning *`, id, current.ArchivedAt)
+		}
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	return direction, themes, nil
+}
+
+// DeleteDirection deletes direction and its themes, chats of deleted
+// themes are kept but nobody can subscribe to them. Deleted themes are
+// returned to update search index.
+func (c *CentrifugoV1) DeleteDirection(id int) (*models.Direction, []*models.Theme, error) {
+	conn := *c.db
+	if conn == nil {
+		return nil, nil, db.ErrDBConnNotEstablished
+	}
+
+	tx, err := conn.Beginx()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback() // nolint
+
+	direction := &models.Direction{}
+
+	err = tx.Get(direction, `
+		update production.direction set deleted_at = now(), updated_at = now()
+		where id = $1 and deleted_at is null
+		returning *`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, ErrDirectionNotFound
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	themes := []*models.Theme{}
+
+	err = tx.Select(&themes, `
+		update production.theme set deleted_at = now(), updated_at = now()
+		where direction = $1 and deleted_at is null
+		returning *`, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	return direction, themes, nil
+}
+
+// ActiveParticipants returns users who wrote the most messages to chat,
+// deleted and hidden messages are not counted
+func (c *CentrifugoV1) ActiveParticipants(chatID, limit int, exclude ...int) ([]*models.ThemeParticipant, error) {
+	match := bson.M{"channel_id": chatID, "deleted": bson.M{"$ne": true}, "hidden": bson.M{"$ne": true}}
+	if len(exclude) > 0 {
+		match["sender"] = bson.M{"$nin": exclude}
+	}
+
+	cursor, err := c.messagesDB().Aggregate(context.TODO(), mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{"_id": "$sender", "messages": bson.M{"$sum": 1}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "messages", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	participants := []*models.ThemeParticipant{}
+
+	err = cursor.All(context.TODO(), &participants)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, 0, len(participants))
+	for _, participant := range participants {
+		ids = append(ids, participant.UserID)
+	}
+
+	cards, err := c.profileCards(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, participant := range participants {
+		if card, ok := cards[participant.UserID]; ok {
+			participant.Name = card.Name
+		}
+	}
+
+	return participants, nil
+}
+
+// TouchThemeActivity counts message of user in theme chat. Participant is
+// counted once, concurrent updates of the same theme are serialized by
+// the upsert row lock.
+func (c *CentrifugoV1) TouchThemeActivity(themeID, userID int, at time.Time) error {
+	conn := *c.db
+	if conn == nil {
+		return db.ErrDBConnNotEstablished
+	}
+
+	_, err := conn.Exec(`
+		with participant as (
+			insert into production.theme_participants (theme_id, user_id) values ($1, $2)
+			on conflict do nothing
+			returning user_id
+		)
+		insert into production.theme_activity (theme_id, last_message_at, message_count, participants)
+		values ($1, $3, 1, (select count(*) from participant))
+		on conflict (theme_id) do update set
+			last_message_at = greatest(production.theme_activity.last_message_at, excluded.last_message_at),
+			message_count = production.theme_activity.message_count + 1,
+			participants = production.theme_activity.participants + excluded.participants`,
+		themeID, userID, at)
+
+	return err
+}
+
+// SelectLastActiveThemes returns page of themes ordered by the last message,
+// direction is not filtered if zero
+func (c *CentrifugoV1) SelectLastActiveThemes(direction, limit, offset int) (*models.ActiveThemes, error) {
+	conn := *c.db
+	if conn == nil {
 		return nil, db.ErrDBConnNotEstablished
 	}
 
-	rows, err := conn.Queryx(conn.Rebind("select * from production.theme where direction=$1"), id)
+	if limit <= 0 || limit > activeThemesMaxLimit {
+		limit = activeThemesLimit
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	var filter interface{}
+	if direction != 0 {
+		filter = direction
+	}
+
+	rows, err := conn.Queryx(`
+		select t.*, a.last_message_at, a.message_count, a.participants, count(*) over () as total
+		from production.theme_activity a
+		join production.theme t on t.id = a.theme_id
+		where t.deleted_at is null and t.archived_at is null and ($1::integer is null or t.direction = $1)
+		order by a.last_message_at desc, a.theme_id desc
+		limit $2 offset $3`,
+		filter, limit, offset)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	data = &ArrayOfThemesData{}
+	data := &models.ActiveThemes{Themes: []*models.ActiveTheme{}, Limit: limit, Offset: offset}
 
 	for rows.Next() {
-		var item models.Theme
+		var item struct {
+			models.ActiveTheme
+			Total int `db:"total"`
+		}
 
 		err = rows.StructScan(&item)
 		if err != nil {
 			return nil, err
 		}
 
-		*data = append(*data, item)
+		data.Total = item.Total
+		data.Themes = append(data.Themes, &item.ActiveTheme)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Page beyond the last one has no rows to take total from
+	if len(data.Themes) == 0 && offset > 0 {
+		err = conn.Get(&data.Total, `
+			select count(*)
+			from production.theme_activity a
+			join production.theme t on t.id = a.theme_id
+			where t.deleted_at is null and t.archived_at is null and ($1::integer is null or t.direction = $1)`,
+			filter)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return data, nil
 }
 
-func (c *CentrifugoV1) SelectLastActiveThemes() (data *ArrayOfThemesData, err error) {
+// backfillThemeActivity fills empty activity table from messages stored
+// before activity was tracked
+func (c *CentrifugoV1) backfillThemeActivity() error {
 	conn := *c.db
-	if c.db == nil {
-		return nil, db.ErrDBConnNotEstablished
+	if conn == nil {
+		return db.ErrDBConnNotEstablished
+	}
+
+	var tracked bool
+
+	err := conn.Get(&tracked, "select exists(select 1 from production.theme_activity)")
+	if err != nil || tracked {
+		return err
+	}
+
+	themes := []int{}
+
+	// Chats created before types were introduced are theme ones, like in
+	// isThemeChat
+	cursor, err := c.chatsDB().Find(context.TODO(), bson.M{"$or": bson.A{
+		bson.M{"type": NamespaceTheme},
+		bson.M{"type": ""},
+		bson.M{"type": bson.M{"$exists": false}},
+	}})
+	if err != nil {
+		return err
 	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+		var chat models.ChatChannel
 
-	query := "("
-	i := 0
-	for k := range c.lastActiveThemesMap {
-		if i < len(c.lastActiveThemesMap)-1 {
-			query = query + k + ","
-		} else {
-			query = query + k
+		err = cursor.Decode(&chat)
+		if err != nil {
+			return err
 		}
-		i++
+
+		themes = append(themes, chat.ID)
+	}
+
+	if err = cursor.Err(); err != nil {
+		return err
 	}
 
-	query = query + ")"
+	if len(themes) == 0 {
+		return nil
+	}
 
-	c.log.Debug().Msgf("query: %s", "select * from production.theme where id in "+query)
+	senders, err := c.messagesDB().Aggregate(context.TODO(), mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"channel_id": bson.M{"$in": themes}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"channel_id": "$channel_id", "sender": "$sender"},
+			"last":  bson.M{"$max": "$timestamp"},
+			"count": bson.M{"$sum": 1},
+		}}},
+	})
+	if err != nil {
+		return err
+	}
+	defer senders.Close(context.TODO())
 
-	rows, err := conn.Queryx("select * from production.theme where id in " + query)
+	tx, err := conn.Beginx()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
+	defer func() { _ = tx.Rollback() }()
 
-	data = &ArrayOfThemesData{}
+	for senders.Next(context.TODO()) {
+		var item struct {
+			ID struct {
+				ChannelID int `bson:"channel_id"`
+				Sender    int `bson:"sender"`
+			} `bson:"_id"`
+			Last  time.Time `bson:"last"`
+			Count int       `bson:"count"`
+		}
 
-	for rows.Next() {
-		var item models.Theme
+		err = senders.Decode(&item)
+		if err != nil {
+			return err
+		}
 
-		err = rows.StructScan(&item)
+		_, err = tx.Exec(`
+			insert into production.theme_participants (theme_id, user_id) values ($1, $2)
+			on conflict do nothing`,
+			item.ID.ChannelID, item.ID.Sender)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		*data = append(*data, item)
+		_, err = tx.Exec(`
+			insert into production.theme_activity (theme_id, last_message_at, message_count, participants)
+			values ($1, $2, $3, 1)
+			on conflict (theme_id) do update set
+				last_message_at = greatest(production.theme_activity.last_message_at, excluded.last_message_at),
+				message_count = production.theme_activity.message_count + excluded.message_count,
+				participants = production.theme_activity.participants + 1`,
+			item.ID.ChannelID, item.Last, item.Count)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = senders.Err(); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (c *CentrifugoV1) themeExists(id int) (exists bool, err error) {
+	conn := *c.db
+	if conn == nil {
+		return false, db.ErrDBConnNotEstablished
+	}
+
+	err = conn.Get(&exists, "select exists(select 1 from production.theme where id=$1 and deleted_at is null)", id)
+
+	return exists, err
+}
+
+// innovationAccess describes relationship of user to innovation
+type innovationAccess struct {
+	AuthorID int          `db:"author_id"`
+	State    types.Status `db:"state"`
+	Expert   bool         `db:"expert"`
+}
+
+func (c *CentrifugoV1) innovationAccess(id, userID int) (*innovationAccess, error) {
+	conn := *c.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	data := &innovationAccess{}
+
+	err := conn.Get(data, `
+		select i.author_id, i.state,
+			exists(select 1 from production.experts e where e.id = i.id and e.expert_id = $2 and e.deleted_at is null) as expert
+		from production.innovation i
+		where i.id = $1 and i.deleted_at is null`, id, userID)
+	if err != nil {
+		return nil, err
 	}
 
 	return data, nil
 }
 
-func (c *CentrifugoV1) LikeTheme(id int64) (err error) {
+// profileCards returns names and positions of users from profiles, users
+// without profile are not returned
+func (c *CentrifugoV1) profileCards(ids []int) (map[int]*models.PresenceUser, error) {
+	cards := make(map[int]*models.PresenceUser, len(ids))
+	if len(ids) == 0 {
+		return cards, nil
+	}
+
 	conn := *c.db
-	if c.db == nil {
-		return db.ErrDBConnNotEstablished
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
 	}
 
-	_, err = conn.Exec(conn.Rebind("update production.theme SET like_counter = like_counter + 1 where id=$1)"), id)
-	return err
+	query, args, err := sqlx.In(`
+		select id, trim(coalesce(user_first_name, '') || ' ' || coalesce(user_last_name, '')) as name,
+			coalesce(user_position, '') as position
+		from production.profiles
+		where deleted_at is null and id in (?)`, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.Queryx(conn.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		card := &models.PresenceUser{}
+
+		err = rows.StructScan(card)
+		if err != nil {
+			return nil, err
+		}
+
+		cards[card.ID] = card
+	}
+
+	return cards, rows.Err()
 }
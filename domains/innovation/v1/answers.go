@@ -14,3 +14,23 @@ type InnovationDataArrayResult httpsrv.ResultAnsw
 type ArrayOfInnovationData []models.Innovation
 
 type ArrayOfInnovationDetailData []models.InnovationDetail
+
+type SimilarDataResult httpsrv.ResultAnsw
+
+type ArrayOfSimilarData []*SimilarHit
+
+type DuplicateDataResult httpsrv.ResultAnsw
+
+type ArrayOfInnovationDuplicateData []models.InnovationDuplicate
+
+type ThemePromotionDataResult httpsrv.ResultAnsw
+
+type SignatureDataResult httpsrv.ResultAnsw
+
+type ArrayOfSignatureData []*models.InnovationSignature
+
+type CoAuthorsDataResult httpsrv.ResultAnsw
+
+type ArrayOfCoAuthorsData []*models.InnovationCoAuthorsDetail
+
+type DocumentVerificationDataResult httpsrv.ResultAnsw
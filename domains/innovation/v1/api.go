@@ -2,21 +2,23 @@ package innovationv1
 
 import (
 	"bytes"
-	"context"
-	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"mime"
 	"net/http"
 	"path/filepath"
 	"reflect"
 	"strconv"
+	"strings"
+	"time"
 
-	"github.com/elastic/go-elasticsearch/v8/esapi"
 	"github.com/labstack/echo/v4"
+	centrifugov1 "github.com/sqsinformatique/rosseti-innovation-back/domains/centrifugo/v1"
 	echoSwagger "github.com/sqsinformatique/rosseti-innovation-back/internal/echo-swagger"
 	"github.com/sqsinformatique/rosseti-innovation-back/internal/httpsrv"
 	"github.com/sqsinformatique/rosseti-innovation-back/internal/logger"
 	"github.com/sqsinformatique/rosseti-innovation-back/models"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
 )
 
 func (inn *InnovationV1) innovationPostHandler(ec echo.Context) (err error) {
@@ -27,7 +29,7 @@ func (inn *InnovationV1) innovationPostHandler(ec echo.Context) (err error) {
 			SetSummary("Create Innovation").
 			AddInBodyParameter("innovation", "Request for create innovation", &models.Innovation{}, false).
 			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
-			AddResponse(http.StatusOK, "OK", &InnovationDataResult{Body: &models.Innovation{}})
+			AddResponse(http.StatusOK, "OK", &InnovationDataResult{Body: &InnovationWithSimilar{}})
 		return nil
 	}
 
@@ -55,48 +57,26 @@ func (inn *InnovationV1) innovationPostHandler(ec echo.Context) (err error) {
 		)
 	}
 
-	jsonData, err := json.Marshal(innovationData)
+	// Similar proposals are only a hint, so failed search doesn't fail creation
+	similar, err := inn.searcher.SearchSimilar(innovationData, similarLimit)
 	if err != nil {
-		hndlLog.Err(err).Msgf("MASHAL INNOVATION FAILED %+v", &innovation)
-
-		return ec.JSON(
-			http.StatusConflict,
-			httpsrv.CreateFailed(err),
-		)
-	}
-
-	req := esapi.IndexRequest{
-		Index:      "innovation",
-		DocumentID: strconv.Itoa(innovationData.ID),
-		Body:       bytes.NewReader(jsonData),
-		Refresh:    "true",
+		hndlLog.Warn().Err(err).Msgf("SEARCH SIMILAR INNOVATION FAILED %d", innovationData.ID)
 	}
 
-	// Perform the request with the client.
-	res, err := req.Do(context.Background(), *inn.elasticDB)
+	err = inn.searcher.IndexInnovation(innovationData)
 	if err != nil {
 		hndlLog.Err(err).Msgf("POST TO ELASTIC FAILED %+v", &innovation)
 		return err
 	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		hndlLog.Err(err).Msgf("[%s] Error indexing document ID=%d", res.Status(), innovationData.ID)
-	} else {
-		// Deserialize the response into a map.
-		var r map[string]interface{}
-		if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
-			hndlLog.Err(err).Msgf("DECODE ELASTIC RESPONSE FAILED %+v", &innovation)
-			return err
-		} else {
-			// Print the response status and indexed document version.
-			hndlLog.Debug().Msgf("[%s] %s; version=%d", res.Status(), r["result"], int(r["_version"].(float64)))
-		}
+
+	err = inn.searchV1.IndexInnovationSuggestions(innovationData)
+	if err != nil {
+		hndlLog.Warn().Err(err).Msgf("INDEX INNOVATION SUGGESTIONS FAILED, id %d", innovationData.ID)
 	}
 
 	return ec.JSON(
 		http.StatusOK,
-		InnovationDataResult{Body: innovationData},
+		InnovationDataResult{Body: &InnovationWithSimilar{Innovation: innovationData, Similar: similar}},
 	)
 }
 
@@ -141,9 +121,9 @@ func (inn *InnovationV1) innovationPutHandler(ec echo.Context) (err error) {
 		}
 	}
 
-	innovationData, err := inn.UpdateInnovationByID(innovationID, &bodyBytes)
+	previous, err := inn.GetInnovationByID(innovationID)
 	if err != nil {
-		hndlLog.Err(err).Msgf("BAD REQUEST, id %d, body %s", innovationID, string(bodyBytes))
+		hndlLog.Err(err).Msgf("GET INNOVATION FAILED, id %d", innovationID)
 
 		return ec.JSON(
 			http.StatusConflict,
@@ -151,43 +131,34 @@ func (inn *InnovationV1) innovationPutHandler(ec echo.Context) (err error) {
 		)
 	}
 
-	jsonData, err := json.Marshal(innovationData)
+	innovationData, err := inn.UpdateInnovationByID(innovationID, &bodyBytes)
 	if err != nil {
-		hndlLog.Err(err).Msgf("MASHAL INNOVATION FAILED %d", innovationID)
+		hndlLog.Err(err).Msgf("BAD REQUEST, id %d, body %s", innovationID, string(bodyBytes))
 
 		return ec.JSON(
 			http.StatusConflict,
-			httpsrv.CreateFailed(err),
+			httpsrv.NotUpdated(err),
 		)
 	}
 
-	req := esapi.IndexRequest{
-		Index:      "innovation",
-		DocumentID: strconv.Itoa(innovationData.ID),
-		Body:       bytes.NewReader(jsonData),
-		Refresh:    "true",
+	if innovationData.State != previous.State {
+		actorID := 0
+		if user, err := inn.userV1.CurrentUser(ec); err == nil {
+			actorID = user.ID
+		}
+
+		inn.notifyStateChanged(innovationData, actorID)
 	}
 
-	// Perform the request with the client.
-	res, err := req.Do(context.Background(), *inn.elasticDB)
+	err = inn.searcher.IndexInnovation(innovationData)
 	if err != nil {
 		hndlLog.Err(err).Msgf("POST TO ELASTIC FAILED %d", innovationID)
 		return err
 	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		hndlLog.Err(err).Msgf("[%s] Error indexing document ID=%d", res.Status(), innovationData.ID)
-	} else {
-		// Deserialize the response into a map.
-		var r map[string]interface{}
-		if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
-			hndlLog.Err(err).Msgf("DECODE ELASTIC RESPONSE FAILED %d", innovationID)
-			return err
-		} else {
-			// Print the response status and indexed document version.
-			hndlLog.Debug().Msgf("[%s] %s; version=%d", res.Status(), r["result"], int(r["_version"].(float64)))
-		}
+
+	err = inn.searchV1.IndexInnovationSuggestions(innovationData)
+	if err != nil {
+		hndlLog.Warn().Err(err).Msgf("INDEX INNOVATION SUGGESTIONS FAILED, id %d", innovationID)
 	}
 
 	return ec.JSON(
@@ -215,7 +186,7 @@ func (inn *InnovationV1) searchPostHandler(ec echo.Context) error {
 	q := ec.QueryParam("q")
 	a := ec.QueryParam("a")
 
-	searchResult, err := inn.Search(q, a)
+	searchResult, err := inn.searcher.Search(q, a)
 	if err != nil {
 		hndlLog.Err(err).Msgf("SEARCH INNOVATION FAILED: query %s", q)
 
@@ -225,6 +196,13 @@ func (inn *InnovationV1) searchPostHandler(ec echo.Context) error {
 		)
 	}
 
+	innovations := make([]*models.Innovation, 0, len(searchResult.Hits))
+	for _, hit := range searchResult.Hits {
+		innovations = append(innovations, &hit.Innovation)
+	}
+
+	inn.markLiked(ec, innovations)
+
 	return ec.JSON(
 		http.StatusOK,
 		SearchDataResult{Body: searchResult},
@@ -250,7 +228,7 @@ func (inn *InnovationV1) searchTitlePostHandler(ec echo.Context) error {
 	q := ec.QueryParam("q")
 	a := ec.QueryParam("a")
 
-	searchResult, err := inn.SearchTitle(q, a)
+	searchResult, err := inn.searcher.SearchTitle(q, a)
 	if err != nil {
 		hndlLog.Err(err).Msgf("SEARCH INNOVATION BY TITLE FAILED: query %s", q)
 
@@ -260,17 +238,201 @@ func (inn *InnovationV1) searchTitlePostHandler(ec echo.Context) error {
 		)
 	}
 
+	innovations := make([]*models.Innovation, 0, len(searchResult.Hits))
+	for _, hit := range searchResult.Hits {
+		innovations = append(innovations, &hit.Innovation)
+	}
+
+	inn.markLiked(ec, innovations)
+
 	return ec.JSON(
 		http.StatusOK,
 		SearchDataResult{Body: searchResult},
 	)
 }
 
+func (inn *InnovationV1) similarPostHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("similarPostHandler").
+			SetSummary("Find similar innovations without creating").
+			AddInBodyParameter("innovation", "Draft of innovation", &models.Innovation{}, false).
+			AddInQueryParameter("limit", "Max count of similar innovations", reflect.Int64, false).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &SimilarDataResult{Body: &ArrayOfSimilarData{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&inn.log, ec)
+
+	limit := similarLimit
+	if ec.QueryParam("limit") != "" {
+		limit, err = strconv.Atoi(ec.QueryParam("limit"))
+		if err != nil {
+			hndlLog.Err(err).Msgf("BAD REQUEST, limit %s", ec.QueryParam("limit"))
+
+			return ec.JSON(
+				http.StatusBadRequest,
+				httpsrv.BadRequest(err),
+			)
+		}
+	}
+
+	var innovation models.Innovation
+	err = ec.Bind(&innovation)
+	if err != nil {
+		hndlLog.Err(err).Msgf("SEARCH SIMILAR INNOVATION FAILED %+v", &innovation)
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	similar, err := inn.searcher.SearchSimilar(&innovation, limit)
+	if err != nil {
+		hndlLog.Err(err).Msgf("SEARCH SIMILAR INNOVATION FAILED %+v", &innovation)
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	innovations := make([]*models.Innovation, 0, len(similar))
+	for _, hit := range similar {
+		innovations = append(innovations, &hit.Innovation)
+	}
+
+	inn.markLiked(ec, innovations)
+
+	return ec.JSON(
+		http.StatusOK,
+		SimilarDataResult{Body: similar},
+	)
+}
+
+func (inn *InnovationV1) duplicatePostHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("duplicatePostHandler").
+			SetSummary("Confirm innovation duplicate").
+			AddInBodyParameter("duplicate", "Confirmed duplicate", &models.InnovationDuplicate{}, true).
+			AddInPathParameter("id", "Original innovation id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &DuplicateDataResult{Body: &models.InnovationDuplicate{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&inn.log, ec)
+
+	innovationID, err := strconv.ParseInt(ec.Param("id"), 10, 64)
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, id %s", ec.Param("id"))
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	var duplicate models.InnovationDuplicate
+	err = ec.Bind(&duplicate)
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, id %d", innovationID)
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	moderator, err := inn.userV1.CurrentUser(ec)
+	if err != nil {
+		hndlLog.Err(err).Msg("GET USER FAILED")
+
+		return ec.JSON(
+			http.StatusUnauthorized,
+			httpsrv.Unauthorized(err),
+		)
+	}
+
+	duplicate.ModeratorID = moderator.ID
+
+	duplicateData, err := inn.MarkDuplicate(innovationID, &duplicate)
+	if err != nil {
+		hndlLog.Err(err).Msgf("MARK DUPLICATE FAILED, id %d, %+v", innovationID, &duplicate)
+
+		return ec.JSON(
+			http.StatusConflict,
+			httpsrv.CreateFailed(err),
+		)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		DuplicateDataResult{Body: duplicateData},
+	)
+}
+
+func (inn *InnovationV1) duplicatesGetHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("duplicatesGetHandler").
+			SetSummary("Get confirmed duplicates of innovation").
+			AddInPathParameter("id", "Innovation id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &DuplicateDataResult{Body: &ArrayOfInnovationDuplicateData{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&inn.log, ec)
+
+	innovationID, err := strconv.ParseInt(ec.Param("id"), 10, 64)
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, id %s", ec.Param("id"))
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	duplicatesData, err := inn.GetDuplicatesByInnovationID(innovationID)
+	if err != nil {
+		hndlLog.Err(err).Msgf("SELECT DUPLICATES FAILED, id %d", innovationID)
+
+		return ec.JSON(
+			http.StatusConflict,
+			httpsrv.CreateFailed(err),
+		)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		DuplicateDataResult{Body: duplicatesData},
+	)
+}
+
 func (inn *InnovationV1) innovationPostImagesHandler(ec echo.Context) (err error) {
 	// Main code of handler
 	hndlLog := logger.HandlerLogger(&inn.log, ec)
 
-	actID := ec.Param("actid")
+	innovationID, err := strconv.Atoi(ec.Param("innid"))
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, id %s", ec.Param("innid"))
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
 
 	multipartForm, err := ec.MultipartForm()
 	if err != nil {
@@ -281,7 +443,7 @@ func (inn *InnovationV1) innovationPostImagesHandler(ec echo.Context) (err error
 		)
 	}
 
-	err = inn.CreateImages(actID, multipartForm)
+	err = inn.CreateImages(innovationID, multipartForm)
 	if err != nil {
 		hndlLog.Err(err).Msgf("failed to upload images")
 		return ec.JSON(
@@ -318,6 +480,37 @@ func (inn *InnovationV1) innovationGetImageHandler(ec echo.Context) (err error)
 	return ec.Stream(http.StatusOK, mime.TypeByExtension(filepath.Ext(imageID)), gridFile)
 }
 
+func (inn *InnovationV1) innovationGetFileHandler(ec echo.Context) (err error) {
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&inn.log, ec)
+
+	innID := ec.Param("innid")
+	fileName := ec.Param("id")
+
+	gridFile, size, err := inn.GetInnovationFile(innID, fileName)
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		hndlLog.Err(err).Msgf("NOT FOUND, innovation %s file %s", innID, fileName)
+
+		return ec.JSON(
+			http.StatusNotFound,
+			httpsrv.NotFound(err),
+		)
+	}
+
+	if err != nil {
+		hndlLog.Err(err).Msgf("failed to download file")
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	ec.Response().Header().Set("Content-Length", strconv.Itoa(int(size)))
+	ec.Response().Header().Set("Content-Disposition", "inline; filename=\""+fileName+"\"")
+
+	return ec.Stream(http.StatusOK, mime.TypeByExtension(filepath.Ext(fileName)), gridFile)
+}
+
 func (inn *InnovationV1) innovationGetByUserIDHandler(ec echo.Context) (err error) {
 	if echoSwagger.IsBuildingSwagger(ec) {
 		echoSwagger.AddToSwagger(ec).
@@ -352,6 +545,13 @@ func (inn *InnovationV1) innovationGetByUserIDHandler(ec echo.Context) (err erro
 		)
 	}
 
+	innovations := make([]*models.Innovation, 0, len(*directionsData))
+	for i := range *directionsData {
+		innovations = append(innovations, &(*directionsData)[i])
+	}
+
+	inn.markLiked(ec, innovations)
+
 	return ec.JSON(
 		http.StatusOK,
 		InnovationDataArrayResult{Body: directionsData},
@@ -425,8 +625,562 @@ func (inn *InnovationV1) innovationGetAllDetailedHandler(ec echo.Context) (err e
 		innovationDetailData = append(innovationDetailData, item)
 	}
 
+	innovations := make([]*models.Innovation, 0, len(innovationDetailData))
+	for i := range innovationDetailData {
+		innovations = append(innovations, &innovationDetailData[i].Innovation)
+	}
+
+	inn.markLiked(ec, innovations)
+
 	return ec.JSON(
 		http.StatusOK,
 		InnovationDataArrayResult{Body: &innovationDetailData},
 	)
 }
+
+// markLiked sets like state of innovations for current user, innovations
+// stay unmarked on failure
+func (inn *InnovationV1) markLiked(ec echo.Context, innovations []*models.Innovation) {
+	user, err := inn.userV1.CurrentUser(ec)
+	if err != nil {
+		return
+	}
+
+	err = inn.likeV1.MarkInnovations(user.ID, innovations)
+	if err != nil {
+		inn.log.Warn().Err(err).Msgf("mark liked innovations failed, user %d", user.ID)
+	}
+}
+
+func (inn *InnovationV1) themePromotePostHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("themePromotePostHandler").
+			SetSummary("Create innovation draft from theme discussion").
+			AddInPathParameter("id", "Theme id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &ThemePromotionDataResult{Body: &models.ThemePromotion{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&inn.log, ec)
+
+	themeID, err := strconv.Atoi(ec.Param("id"))
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, id %s", ec.Param("id"))
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	user, err := inn.userV1.CurrentUser(ec)
+	if err != nil {
+		hndlLog.Err(err).Msg("GET CURRENT USER FAILED")
+
+		return ec.JSON(
+			http.StatusUnauthorized,
+			httpsrv.Unauthorized(err),
+		)
+	}
+
+	promotion, err := inn.PromoteTheme(themeID, user)
+	if err != nil {
+		hndlLog.Err(err).Msgf("PROMOTE THEME FAILED, theme %d user %d", themeID, user.ID)
+
+		switch {
+		case errors.Is(err, centrifugov1.ErrThemeNotFound):
+			return ec.JSON(http.StatusNotFound, httpsrv.NotFound(err))
+		case errors.Is(err, centrifugov1.ErrNotThemeAuthor):
+			return ec.JSON(http.StatusForbidden, httpsrv.Forbidden(err))
+		case errors.Is(err, ErrThemePromoted):
+			return ec.JSON(http.StatusConflict, httpsrv.CreateFailed(err))
+		}
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	err = inn.searcher.IndexInnovation(promotion.Innovation)
+	if err != nil {
+		hndlLog.Warn().Err(err).Msgf("INDEX INNOVATION FAILED, id %d", promotion.Innovation.ID)
+	}
+
+	err = inn.searchV1.IndexInnovationSuggestions(promotion.Innovation)
+	if err != nil {
+		hndlLog.Warn().Err(err).Msgf("INDEX INNOVATION SUGGESTIONS FAILED, id %d", promotion.Innovation.ID)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		ThemePromotionDataResult{Body: promotion},
+	)
+}
+
+func (inn *InnovationV1) innovationsExportGetHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("text/csv", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet").
+			SetDescription("innovationsExportGetHandler").
+			SetSummary("Export registry of innovations matching search query").
+			AddInQueryParameter("q", "query, all innovations are exported if empty", reflect.String, false).
+			AddInQueryParameter("format", "Registry format: csv or xlsx", reflect.String, false).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", nil)
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&inn.log, ec)
+
+	format := ec.QueryParam("format")
+	if format == "" {
+		format = models.RegistryExportXLSX
+	}
+
+	contentType, err := RegistryContentType(format)
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, format %s", format)
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	q := ec.QueryParam("q")
+
+	header := ec.Response().Header()
+	header.Set(echo.HeaderContentType, contentType)
+	header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": "innovations_" + time.Now().Format("20060102") + "." + format,
+	}))
+	ec.Response().WriteHeader(http.StatusOK)
+
+	// Response is already started, failure can only be logged
+	err = inn.ExportRegistry(q, format, ec.Response())
+	if err != nil {
+		hndlLog.Err(err).Msgf("EXPORT INNOVATIONS FAILED, query %s format %s", q, format)
+	}
+
+	return nil
+}
+
+func (inn *InnovationV1) signaturePostHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("signaturePostHandler").
+			SetSummary("Sign application of innovation by profile key of author").
+			AddInPathParameter("id", "Innovation id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &SignatureDataResult{Body: &models.InnovationSignature{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&inn.log, ec)
+
+	innovationID, err := strconv.Atoi(ec.Param("id"))
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, id %s", ec.Param("id"))
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	user, err := inn.userV1.CurrentUser(ec)
+	if err != nil {
+		hndlLog.Err(err).Msg("GET CURRENT USER FAILED")
+
+		return ec.JSON(
+			http.StatusUnauthorized,
+			httpsrv.Unauthorized(err),
+		)
+	}
+
+	signature, err := inn.SignApplication(innovationID, user)
+	if err != nil {
+		hndlLog.Err(err).Msgf("SIGN APPLICATION FAILED, innovation %d user %d", innovationID, user.ID)
+
+		switch {
+		case errors.Is(err, ErrInnovationNotFound):
+			return ec.JSON(http.StatusNotFound, httpsrv.NotFound(err))
+		case errors.Is(err, ErrNotInnovationAuthor):
+			return ec.JSON(http.StatusForbidden, httpsrv.Forbidden(err))
+		}
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		SignatureDataResult{Body: signature},
+	)
+}
+
+func (inn *InnovationV1) signaturesGetHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("signaturesGetHandler").
+			SetSummary("Get signatures of innovation application").
+			AddInPathParameter("id", "Innovation id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &SignatureDataResult{Body: &ArrayOfSignatureData{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&inn.log, ec)
+
+	innovationID, err := strconv.Atoi(ec.Param("id"))
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, id %s", ec.Param("id"))
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	user, err := inn.userV1.CurrentUser(ec)
+	if err != nil {
+		hndlLog.Err(err).Msg("GET CURRENT USER FAILED")
+
+		return ec.JSON(
+			http.StatusUnauthorized,
+			httpsrv.Unauthorized(err),
+		)
+	}
+
+	signatures, err := inn.GetSignatures(innovationID, user)
+	if err != nil {
+		hndlLog.Err(err).Msgf("GET SIGNATURES FAILED, innovation %d user %d", innovationID, user.ID)
+
+		switch {
+		case errors.Is(err, ErrInnovationNotFound):
+			return ec.JSON(http.StatusNotFound, httpsrv.NotFound(err))
+		case errors.Is(err, ErrNotDocumentReader):
+			return ec.JSON(http.StatusForbidden, httpsrv.Forbidden(err))
+		}
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		SignatureDataResult{Body: ArrayOfSignatureData(signatures)},
+	)
+}
+
+func (inn *InnovationV1) documentGetHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/pdf").
+			SetDescription("documentGetHandler").
+			SetSummary("Get application or certificate of innovation as PDF").
+			AddInPathParameter("id", "Innovation id", reflect.Int64).
+			AddInPathParameter("kind", "Document kind: application or certificate", reflect.String).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", nil)
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&inn.log, ec)
+
+	innovationID, err := strconv.Atoi(ec.Param("id"))
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, id %s", ec.Param("id"))
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	user, err := inn.userV1.CurrentUser(ec)
+	if err != nil {
+		hndlLog.Err(err).Msg("GET CURRENT USER FAILED")
+
+		return ec.JSON(
+			http.StatusUnauthorized,
+			httpsrv.Unauthorized(err),
+		)
+	}
+
+	kind := ec.Param("kind")
+
+	detail, err := inn.CheckDocument(innovationID, kind, user)
+	if err != nil {
+		hndlLog.Err(err).Msgf("GET DOCUMENT FAILED, innovation %d kind %s user %d", innovationID, kind, user.ID)
+
+		switch {
+		case errors.Is(err, ErrBadDocumentKind):
+			return ec.JSON(http.StatusBadRequest, httpsrv.BadRequest(err))
+		case errors.Is(err, ErrInnovationNotFound):
+			return ec.JSON(http.StatusNotFound, httpsrv.NotFound(err))
+		case errors.Is(err, ErrNotDocumentReader):
+			return ec.JSON(http.StatusForbidden, httpsrv.Forbidden(err))
+		case errors.Is(err, ErrNotRecognized):
+			return ec.JSON(http.StatusPreconditionFailed, httpsrv.PreconditionFailed(err))
+		}
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	// Document is rendered before response, so failure is still answered
+	// with error
+	var document bytes.Buffer
+
+	err = inn.RenderDocument(detail, kind, &document)
+	if err != nil {
+		hndlLog.Err(err).Msgf("RENDER DOCUMENT FAILED, innovation %d kind %s", innovationID, kind)
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	ec.Response().Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": kind + "_" + strconv.Itoa(innovationID) + ".pdf",
+	}))
+
+	return ec.Blob(http.StatusOK, "application/pdf", document.Bytes())
+}
+
+func (inn *InnovationV1) documentVerifyGetHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json", "text/html").
+			SetDescription("documentVerifyGetHandler").
+			SetSummary("Verify issued document by code of its QR code, page is returned for text/html").
+			AddInPathParameter("code", "Document code", reflect.String).
+			AddResponse(http.StatusOK, "OK", &DocumentVerificationDataResult{Body: &models.DocumentVerification{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&inn.log, ec)
+
+	code := ec.Param("code")
+
+	verification, err := inn.VerifyDocument(code)
+	if err != nil {
+		hndlLog.Err(err).Msgf("VERIFY DOCUMENT FAILED, code %s", code)
+
+		if errors.Is(err, ErrDocumentNotFound) {
+			return ec.JSON(http.StatusNotFound, httpsrv.NotFound(err))
+		}
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	// QR code is opened in browser, so it gets a page instead of JSON
+	if strings.Contains(ec.Request().Header.Get(echo.HeaderAccept), echo.MIMETextHTML) {
+		var page bytes.Buffer
+
+		err = verificationPage.Execute(&page, verification)
+		if err != nil {
+			hndlLog.Err(err).Msgf("RENDER VERIFICATION PAGE FAILED, code %s", code)
+
+			return ec.JSON(
+				http.StatusInternalServerError,
+				httpsrv.InternalServerError(err),
+			)
+		}
+
+		return ec.HTMLBlob(http.StatusOK, page.Bytes())
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		DocumentVerificationDataResult{Body: verification},
+	)
+}
+
+// coAuthorErrorAnswer converts error of co-authors change to answer
+func coAuthorErrorAnswer(err error) (int, httpsrv.ErrorAnsw) {
+	switch {
+	case errors.Is(err, ErrCoAuthorIsAuthor):
+		return http.StatusBadRequest, httpsrv.BadRequest(err)
+	case errors.Is(err, ErrNotCoAuthorsManager):
+		return http.StatusForbidden, httpsrv.Forbidden(err)
+	case errors.Is(err, ErrInnovationNotFound), errors.Is(err, ErrCoAuthorNotFound):
+		return http.StatusNotFound, httpsrv.NotFound(err)
+	}
+
+	return http.StatusInternalServerError, httpsrv.InternalServerError(err)
+}
+
+func (inn *InnovationV1) coAuthorsGetHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("coAuthorsGetHandler").
+			SetSummary("Get co-authors of innovation").
+			AddInPathParameter("id", "Innovation id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &CoAuthorsDataResult{Body: &ArrayOfCoAuthorsData{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&inn.log, ec)
+
+	innovationID, err := strconv.Atoi(ec.Param("id"))
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, id %s", ec.Param("id"))
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	coAuthors, err := inn.GetCoAuthors(innovationID)
+	if err != nil {
+		hndlLog.Err(err).Msgf("GET CO-AUTHORS FAILED, innovation %d", innovationID)
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		CoAuthorsDataResult{Body: ArrayOfCoAuthorsData(coAuthors)},
+	)
+}
+
+// coAuthorRequest returns innovation, co-author and current user of request
+func (inn *InnovationV1) coAuthorRequest(ec echo.Context) (innovationID, authorID int, user *models.User, err error) {
+	innovationID, err = strconv.Atoi(ec.Param("id"))
+	if err != nil {
+		return 0, 0, nil, ec.JSON(http.StatusBadRequest, httpsrv.BadRequest(err))
+	}
+
+	authorID, err = strconv.Atoi(ec.Param("userid"))
+	if err != nil {
+		return 0, 0, nil, ec.JSON(http.StatusBadRequest, httpsrv.BadRequest(err))
+	}
+
+	user, err = inn.userV1.CurrentUser(ec)
+	if err != nil {
+		return 0, 0, nil, ec.JSON(http.StatusUnauthorized, httpsrv.Unauthorized(err))
+	}
+
+	return innovationID, authorID, user, nil
+}
+
+func (inn *InnovationV1) coAuthorPutHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("coAuthorPutHandler").
+			SetSummary("Add co-author to innovation by its author or moderator").
+			AddInPathParameter("id", "Innovation id", reflect.Int64).
+			AddInPathParameter("userid", "Co-author user id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &CoAuthorsDataResult{Body: &ArrayOfCoAuthorsData{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&inn.log, ec)
+
+	innovationID, authorID, user, answer := inn.coAuthorRequest(ec)
+	if user == nil {
+		hndlLog.Error().Msgf("BAD REQUEST, innovation %s co-author %s", ec.Param("id"), ec.Param("userid"))
+		return answer
+	}
+
+	_, _, err = inn.AddCoAuthor(innovationID, authorID, user)
+	if err != nil {
+		hndlLog.Err(err).Msgf("ADD CO-AUTHOR FAILED, innovation %d co-author %d", innovationID, authorID)
+
+		return ec.JSON(coAuthorErrorAnswer(err))
+	}
+
+	coAuthors, err := inn.GetCoAuthors(innovationID)
+	if err != nil {
+		hndlLog.Err(err).Msgf("GET CO-AUTHORS FAILED, innovation %d", innovationID)
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		CoAuthorsDataResult{Body: ArrayOfCoAuthorsData(coAuthors)},
+	)
+}
+
+func (inn *InnovationV1) coAuthorDeleteHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("coAuthorDeleteHandler").
+			SetSummary("Remove co-author of innovation by its author or moderator").
+			AddInPathParameter("id", "Innovation id", reflect.Int64).
+			AddInPathParameter("userid", "Co-author user id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &CoAuthorsDataResult{Body: &ArrayOfCoAuthorsData{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&inn.log, ec)
+
+	innovationID, authorID, user, answer := inn.coAuthorRequest(ec)
+	if user == nil {
+		hndlLog.Error().Msgf("BAD REQUEST, innovation %s co-author %s", ec.Param("id"), ec.Param("userid"))
+		return answer
+	}
+
+	_, _, err = inn.RemoveCoAuthor(innovationID, authorID, user)
+	if err != nil {
+		hndlLog.Err(err).Msgf("REMOVE CO-AUTHOR FAILED, innovation %d co-author %d", innovationID, authorID)
+
+		return ec.JSON(coAuthorErrorAnswer(err))
+	}
+
+	coAuthors, err := inn.GetCoAuthors(innovationID)
+	if err != nil {
+		hndlLog.Err(err).Msgf("GET CO-AUTHORS FAILED, innovation %d", innovationID)
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		CoAuthorsDataResult{Body: ArrayOfCoAuthorsData(coAuthors)},
+	)
+}
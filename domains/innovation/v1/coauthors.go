@@ -0,0 +1,178 @@
+package innovationv1
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/db"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+	"github.com/sqsinformatique/rosseti-innovation-back/types"
+)
+
+var (
+	ErrNotCoAuthorsManager = errors.New("only author of innovation and moderators can change co-authors")
+	ErrCoAuthorIsAuthor    = errors.New("author of innovation can't be its co-author")
+	ErrCoAuthorNotFound    = errors.New("co-author not found")
+)
+
+// GetCoAuthors returns current co-authors of innovation with profiles in
+// order of addition
+func (inn *InnovationV1) GetCoAuthors(innovationID int) ([]*models.InnovationCoAuthorsDetail, error) {
+	conn := *inn.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	coAuthors := []*models.InnovationCoAuthorsDetail{}
+
+	err := conn.Select(&coAuthors, `
+		select id, author_id, created_at, updated_at, deleted_at
+		from production.innovation_co_authors
+		where id = $1 and deleted_at is null
+		order by created_at, author_id`, innovationID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, coAuthor := range coAuthors {
+		coAuthor.Author, err = inn.profilev1.GetProfileByID(int64(coAuthor.AuthorID))
+		if errors.Is(err, sql.ErrNoRows) {
+			coAuthor.Author = &models.Profile{ID: coAuthor.AuthorID}
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	return coAuthors, nil
+}
+
+// addCoAuthors links users to innovation as co-authors, removed co-author
+// is restored. It returns users who were not co-authors before.
+func addCoAuthors(tx sqlx.Execer, innovationID int, authorIDs ...int) ([]int, error) {
+	added := []int{}
+
+	for _, authorID := range authorIDs {
+		restored, err := tx.Exec(`
+			update production.innovation_co_authors set deleted_at = null, updated_at = now()
+			where id = $1 and author_id = $2 and deleted_at is not null`,
+			innovationID, authorID)
+		if err != nil {
+			return nil, err
+		}
+
+		inserted, err := tx.Exec(`
+			insert into production.innovation_co_authors (id, author_id)
+			values ($1, $2)
+			on conflict (id, author_id) do nothing`,
+			innovationID, authorID)
+		if err != nil {
+			return nil, err
+		}
+
+		restoredRows, err := restored.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+
+		insertedRows, err := inserted.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+
+		if restoredRows+insertedRows > 0 {
+			added = append(added, authorID)
+		}
+	}
+
+	return added, nil
+}
+
+// checkCoAuthorsManager returns innovation if user may change its
+// co-authors
+func (inn *InnovationV1) checkCoAuthorsManager(innovationID int, user *models.User) (*models.Innovation, error) {
+	innovation, err := inn.GetInnovationByID(int64(innovationID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInnovationNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if innovation.AuthorID != user.ID && user.Role < types.Moderator {
+		return nil, ErrNotCoAuthorsManager
+	}
+
+	return innovation, nil
+}
+
+// AddCoAuthor links user to innovation as co-author, adding of existing
+// co-author is not an error
+func (inn *InnovationV1) AddCoAuthor(innovationID, authorID int, user *models.User) (*models.Innovation, bool, error) {
+	innovation, err := inn.checkCoAuthorsManager(innovationID, user)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if innovation.AuthorID == authorID {
+		return nil, false, ErrCoAuthorIsAuthor
+	}
+
+	_, err = inn.profilev1.GetProfileByID(int64(authorID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, ErrCoAuthorNotFound
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	conn := *inn.db
+	if conn == nil {
+		return nil, false, db.ErrDBConnNotEstablished
+	}
+
+	added, err := addCoAuthors(conn, innovationID, authorID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(added) > 0 {
+		inn.notifyCoAuthorChanged(innovation, authorID, user.ID, true)
+	}
+
+	return innovation, len(added) > 0, nil
+}
+
+// RemoveCoAuthor unlinks co-author from innovation, false is returned if
+// user was not a co-author
+func (inn *InnovationV1) RemoveCoAuthor(innovationID, authorID int, user *models.User) (*models.Innovation, bool, error) {
+	innovation, err := inn.checkCoAuthorsManager(innovationID, user)
+	if err != nil {
+		return nil, false, err
+	}
+
+	conn := *inn.db
+	if conn == nil {
+		return nil, false, db.ErrDBConnNotEstablished
+	}
+
+	result, err := conn.Exec(`
+		update production.innovation_co_authors set deleted_at = now(), updated_at = now()
+		where id = $1 and author_id = $2 and deleted_at is null`, innovationID, authorID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return nil, false, err
+	}
+
+	if removed > 0 {
+		inn.notifyCoAuthorChanged(innovation, authorID, user.ID, false)
+	}
+
+	return innovation, removed > 0, nil
+}
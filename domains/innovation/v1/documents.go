@@ -0,0 +1,469 @@
+package innovationv1
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/crypto"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/db"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/documents"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+	"github.com/sqsinformatique/rosseti-innovation-back/types"
+)
+
+var (
+	ErrInnovationNotFound  = errors.New("innovation not found")
+	ErrNotInnovationAuthor = errors.New("only authors of innovation can sign it")
+	ErrNotDocumentReader   = errors.New("only authors and moderators can get documents of innovation")
+	ErrNotRecognized       = errors.New("certificate is issued for recognized innovation only")
+	ErrBadDocumentKind     = errors.New("document must be application or certificate")
+	ErrDocumentNotFound    = errors.New("document not found")
+)
+
+// documentTemplates are layouts of document kinds
+var documentTemplates = map[string]*documents.Template{
+	models.DocumentApplication: documents.Application,
+	models.DocumentCertificate: documents.Certificate,
+}
+
+// verificationPage shows verification of document opened by QR code
+var verificationPage = template.Must(template.New("verification").Funcs(template.FuncMap{
+	"date": func(t time.Time) string { return t.Format("02.01.2006 15:04") },
+}).Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head><meta charset="utf-8"><title>Проверка документа</title></head>
+<body>
+<h1>{{if eq .Kind "certificate"}}Удостоверение{{else}}Заявление{{end}} на рационализаторское предложение № {{.InnovationID}}</h1>
+<p>Документ выдан {{date .IssuedAt}}.</p>
+<p>Предложение: «{{.Title}}», статус {{.State}}.</p>
+<p>Авторы: {{range $i, $name := .Authors}}{{if $i}}, {{end}}{{$name}}{{end}}</p>
+<h2>Электронные подписи</h2>
+{{range .Signatures}}<p>{{.Name}}, {{date .SignedAt}}: {{if .Valid}}подпись действительна{{else}}подпись не соответствует текущей редакции предложения{{end}}</p>
+{{else}}<p>Заявление не подписано электронной подписью.</p>
+{{end}}</body>
+</html>
+`))
+
+// signedApplication is data of application signed by authors, signature
+// becomes invalid when any of these fields is changed
+type signedApplication struct {
+	ID           int    `json:"id"`
+	AuthorID     int    `json:"author_id"`
+	Title        string `json:"title"`
+	Problem      string `json:"problem"`
+	Descriptions string `json:"descriptions"`
+	Effect       string `json:"effect"`
+}
+
+func applicationData(innovation *models.Innovation) *signedApplication {
+	return &signedApplication{
+		ID:           innovation.ID,
+		AuthorID:     innovation.AuthorID,
+		Title:        innovation.Title,
+		Problem:      innovation.Problem,
+		Descriptions: innovation.Description,
+		Effect:       innovation.Effect,
+	}
+}
+
+// personName is a full name in order of official documents
+func personName(profile *models.Profile) string {
+	return strings.Join(strings.Fields(profile.LastName+" "+profile.FirstName+" "+profile.MiddleName), " ")
+}
+
+// getInnovationDetail returns innovation with profiles of author and
+// co-authors
+func (inn *InnovationV1) getInnovationDetail(id int) (*models.InnovationDetail, error) {
+	innovation, err := inn.GetInnovationByID(int64(id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInnovationNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &models.InnovationDetail{Innovation: *innovation}
+
+	detail.Author, err = inn.profilev1.GetProfileByID(int64(innovation.AuthorID))
+	if errors.Is(err, sql.ErrNoRows) {
+		detail.Author = &models.Profile{ID: innovation.AuthorID}
+	} else if err != nil {
+		return nil, err
+	}
+
+	coAuthors, err := inn.GetCoAuthors(id)
+	if err != nil {
+		return nil, err
+	}
+
+	detail.CoAuthor = &coAuthors
+
+	return detail, nil
+}
+
+// detailAuthors returns profiles of author and co-authors
+func detailAuthors(detail *models.InnovationDetail) []*models.Profile {
+	authors := []*models.Profile{detail.Author}
+
+	if detail.CoAuthor != nil {
+		for _, coAuthor := range *detail.CoAuthor {
+			authors = append(authors, coAuthor.Author)
+		}
+	}
+
+	return authors
+}
+
+func isAuthor(detail *models.InnovationDetail, userID int) bool {
+	for _, author := range detailAuthors(detail) {
+		if author.ID == userID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SignApplication signs application of innovation with profile key of
+// author, repeated signature replaces the previous one
+func (inn *InnovationV1) SignApplication(innovationID int, user *models.User) (*models.InnovationSignature, error) {
+	detail, err := inn.getInnovationDetail(innovationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isAuthor(detail, user.ID) {
+		return nil, ErrNotInnovationAuthor
+	}
+
+	application := applicationData(&detail.Innovation)
+
+	signature, err := inn.profilev1.SignDataByID(int64(user.ID), application)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(application)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := *inn.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	result := &models.InnovationSignature{}
+
+	err = conn.Get(result, `
+		insert into production.innovation_signatures (innovation_id, user_id, signature, data_hash)
+		values ($1, $2, $3, $4)
+		on conflict (innovation_id, user_id) do update
+		set signature = excluded.signature, data_hash = excluded.data_hash, created_at = now()
+		returning *`,
+		innovationID, user.ID, signature, crypto.HashString(string(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	result.Valid = true
+
+	return result, nil
+}
+
+// getSignatures returns signatures of application by current authors and
+// checks them against current data of innovation
+func (inn *InnovationV1) getSignatures(detail *models.InnovationDetail) ([]*models.InnovationSignature, error) {
+	conn := *inn.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	signatures := []*models.InnovationSignature{}
+
+	err := conn.Select(&signatures, `
+		select * from production.innovation_signatures
+		where innovation_id = $1
+		order by created_at`, detail.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	authors := make(map[int]*models.Profile)
+	for _, author := range detailAuthors(detail) {
+		authors[author.ID] = author
+	}
+
+	application := applicationData(&detail.Innovation)
+	result := make([]*models.InnovationSignature, 0, len(signatures))
+
+	for _, signature := range signatures {
+		author, ok := authors[signature.UserID]
+		if !ok {
+			continue
+		}
+
+		key, err := crypto.UnmarshalPublic(author.PublicKey)
+		if err == nil {
+			signature.Valid = crypto.DataVerify(application, signature.Signature, key) == nil
+		}
+
+		result = append(result, signature)
+	}
+
+	return result, nil
+}
+
+// GetSignatures returns signatures of application, they are available to
+// authors and moderators
+func (inn *InnovationV1) GetSignatures(innovationID int, user *models.User) ([]*models.InnovationSignature, error) {
+	detail, err := inn.getInnovationDetail(innovationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Role < types.Moderator && !isAuthor(detail, user.ID) {
+		return nil, ErrNotDocumentReader
+	}
+
+	return inn.getSignatures(detail)
+}
+
+// registerDocument returns issued document of kind, document is issued
+// once and its code doesn't change
+func (inn *InnovationV1) registerDocument(innovationID int, kind string) (*models.InnovationDocument, error) {
+	conn := *inn.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	document := &models.InnovationDocument{}
+
+	err := conn.Get(document, `
+		insert into production.innovation_documents (innovation_id, kind, code)
+		values ($1, $2, $3)
+		on conflict (innovation_id, kind) do update set kind = excluded.kind
+		returning *`,
+		innovationID, kind, uuid.New().String())
+	if err != nil {
+		return nil, err
+	}
+
+	return document, nil
+}
+
+// recognizedAt returns time of the first recognition of innovation, time
+// of the last update is used for innovations recognized before state
+// history
+func (inn *InnovationV1) recognizedAt(innovation *models.Innovation) (time.Time, error) {
+	conn := *inn.db
+	if conn == nil {
+		return time.Time{}, db.ErrDBConnNotEstablished
+	}
+
+	var recognizedAt sql.NullTime
+
+	err := conn.Get(&recognizedAt, `
+		select min(created_at) from production.innovation_states
+		where innovation_id = $1 and state = $2`,
+		innovation.ID, types.Recognized.String())
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if recognizedAt.Valid {
+		return recognizedAt.Time, nil
+	}
+
+	return innovation.UpdatedAt.Time, nil
+}
+
+// directionTitle returns title of innovation direction
+func (inn *InnovationV1) directionTitle(innovation *models.Innovation) (string, error) {
+	if innovation.Direction == nil {
+		return "", nil
+	}
+
+	conn := *inn.db
+	if conn == nil {
+		return "", db.ErrDBConnNotEstablished
+	}
+
+	var title string
+
+	err := conn.Get(&title, "select title from production.direction where id = $1", *innovation.Direction)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+
+	return title, err
+}
+
+// documentData fills document template by innovation, its authors and
+// signatures
+func (inn *InnovationV1) documentData(detail *models.InnovationDetail, document *models.InnovationDocument) (*documents.Data, error) {
+	direction, err := inn.directionTitle(&detail.Innovation)
+	if err != nil {
+		return nil, err
+	}
+
+	signatures, err := inn.getSignatures(detail)
+	if err != nil {
+		return nil, err
+	}
+
+	signed := make(map[int]*models.InnovationSignature, len(signatures))
+	for _, signature := range signatures {
+		signed[signature.UserID] = signature
+	}
+
+	data := &documents.Data{
+		Number:      detail.ID,
+		Title:       detail.Title,
+		Direction:   direction,
+		Problem:     detail.Problem,
+		Description: detail.Description,
+		Effect:      detail.Effect,
+		Company:     detail.Author.Company,
+		SubmittedAt: detail.CreatedAt.Time,
+		IssuedAt:    document.CreatedAt,
+		VerifyURL:   strings.TrimRight(inn.cfg.Documents.VerifyURL, "/") + "/" + document.Code,
+	}
+
+	for _, author := range detailAuthors(detail) {
+		person := &documents.Person{
+			Name:     personName(author),
+			Position: author.Position,
+			Company:  author.Company,
+		}
+
+		if signature, ok := signed[author.ID]; ok {
+			person.Signed = signature.Valid
+
+			data.Signatures = append(data.Signatures, &documents.Signature{
+				Name:           person.Name,
+				SignedAt:       signature.CreatedAt,
+				Value:          signature.Signature,
+				KeyFingerprint: crypto.KeyFingerprint(author.PublicKey),
+				Valid:          signature.Valid,
+			})
+		}
+
+		data.Authors = append(data.Authors, person)
+	}
+
+	if detail.State.IsRecognized() {
+		data.RecognizedAt, err = inn.recognizedAt(&detail.Innovation)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+// CheckDocument checks that user can get document of kind, documents are
+// available to authors and moderators, certificate is issued after
+// recognition only
+func (inn *InnovationV1) CheckDocument(innovationID int, kind string, user *models.User) (*models.InnovationDetail, error) {
+	if _, ok := documentTemplates[kind]; !ok {
+		return nil, ErrBadDocumentKind
+	}
+
+	detail, err := inn.getInnovationDetail(innovationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Role < types.Moderator && !isAuthor(detail, user.ID) {
+		return nil, ErrNotDocumentReader
+	}
+
+	if kind == models.DocumentCertificate && !detail.State.IsRecognized() {
+		return nil, ErrNotRecognized
+	}
+
+	return detail, nil
+}
+
+// RenderDocument writes PDF of innovation document checked by
+// CheckDocument to w
+func (inn *InnovationV1) RenderDocument(detail *models.InnovationDetail, kind string, w io.Writer) error {
+	document, err := inn.registerDocument(detail.ID, kind)
+	if err != nil {
+		return err
+	}
+
+	data, err := inn.documentData(detail, document)
+	if err != nil {
+		return err
+	}
+
+	return inn.renderer.Render(w, documentTemplates[kind], data)
+}
+
+// VerifyDocument returns public information about issued document by code
+// of verification URL
+func (inn *InnovationV1) VerifyDocument(code string) (*models.DocumentVerification, error) {
+	conn := *inn.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	document := &models.InnovationDocument{}
+
+	err := conn.Get(document, "select * from production.innovation_documents where code = $1", code)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDocumentNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	detail, err := inn.getInnovationDetail(document.InnovationID)
+	if errors.Is(err, ErrInnovationNotFound) {
+		return nil, ErrDocumentNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	signatures, err := inn.getSignatures(detail)
+	if err != nil {
+		return nil, err
+	}
+
+	verification := &models.DocumentVerification{
+		Kind:         document.Kind,
+		InnovationID: detail.ID,
+		Title:        detail.Title,
+		State:        detail.State.String(),
+		IssuedAt:     document.CreatedAt,
+		Signatures:   []*models.SignatureVerification{},
+	}
+
+	names := make(map[int]string)
+	for _, author := range detailAuthors(detail) {
+		names[author.ID] = personName(author)
+		verification.Authors = append(verification.Authors, names[author.ID])
+	}
+
+	for _, signature := range signatures {
+		verification.Signatures = append(verification.Signatures, &models.SignatureVerification{
+			Name:     names[signature.UserID],
+			SignedAt: signature.CreatedAt,
+			Valid:    signature.Valid,
+		})
+	}
+
+	return verification, nil
+}
@@ -0,0 +1,8 @@
+package innovationv1
+
+// Index of elastic backend is exported to search tests, so they can
+// recreate it empty
+const (
+	InnovationIndex   = innovationIndex
+	InnovationMapping = innovationMapping
+)
@@ -1,18 +1,25 @@
 package innovationv1
 
 import (
+	"database/sql"
 	"errors"
+	"fmt"
 
-	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo/v4"
 	"github.com/rs/zerolog"
+	centrifugov1 "github.com/sqsinformatique/rosseti-innovation-back/domains/centrifugo/v1"
+	likev1 "github.com/sqsinformatique/rosseti-innovation-back/domains/like/v1"
+	notificationv1 "github.com/sqsinformatique/rosseti-innovation-back/domains/notification/v1"
 	profilev1 "github.com/sqsinformatique/rosseti-innovation-back/domains/profile/v1"
+	searchv1 "github.com/sqsinformatique/rosseti-innovation-back/domains/search/v1"
 	userv1 "github.com/sqsinformatique/rosseti-innovation-back/domains/user/v1"
 	"github.com/sqsinformatique/rosseti-innovation-back/internal/cfg"
 	"github.com/sqsinformatique/rosseti-innovation-back/internal/context"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/documents"
 	"github.com/sqsinformatique/rosseti-innovation-back/internal/httpsrv"
 	"github.com/sqsinformatique/rosseti-innovation-back/internal/orm"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
 	"github.com/sqsinformatique/rosseti-innovation-back/types"
 	"go.mongodb.org/mongo-driver/mongo"
 )
@@ -20,24 +27,33 @@ import (
 type empty struct{}
 
 type InnovationV1 struct {
-	log       zerolog.Logger
-	cfg       *cfg.AppCfg
-	db        **sqlx.DB
-	mongodb   **mongo.Client
-	elasticDB **elasticsearch.Client
-	orm       *orm.ORM
-	profilev1 *profilev1.ProfileV1
-	publicV1  *echo.Group
-	userV1    *userv1.UserV1
+	log          zerolog.Logger
+	cfg          *cfg.AppCfg
+	db           **sqlx.DB
+	mongodb      **mongo.Client
+	searcher     Searcher
+	orm          *orm.ORM
+	profilev1    *profilev1.ProfileV1
+	publicV1     *echo.Group
+	userV1       *userv1.UserV1
+	searchV1     *searchv1.SearchV1
+	likeV1       *likev1.LikeV1
+	notifier     *notificationv1.NotificationV1
+	centrifugoV1 *centrifugov1.CentrifugoV1
+	renderer     *documents.Renderer
 }
 
 func NewInnovationV1(ctx *context.Context,
 	profilev1 *profilev1.ProfileV1,
 	orm *orm.ORM,
 	userV1 *userv1.UserV1,
+	searchV1 *searchv1.SearchV1,
+	likeV1 *likev1.LikeV1,
+	notificationV1 *notificationv1.NotificationV1,
+	centrifugoV1 *centrifugov1.CentrifugoV1,
 ) (*InnovationV1, error) {
-	if ctx == nil || profilev1 == nil || orm == nil {
-		return nil, errors.New("empty context or profilev1 client or orm client")
+	if ctx == nil || profilev1 == nil || orm == nil || searchV1 == nil || likeV1 == nil || notificationV1 == nil || centrifugoV1 == nil {
+		return nil, errors.New("empty context or profilev1 client or orm client or searchV1 client or likeV1 client or notificationV1 client or centrifugoV1 client")
 	}
 
 	inn := &InnovationV1{}
@@ -47,16 +63,46 @@ func NewInnovationV1(ctx *context.Context,
 	inn.cfg = ctx.Config
 	inn.db = ctx.GetDatabase()
 	inn.mongodb = ctx.GetMongoDB()
-	inn.elasticDB = ctx.GetElasticDB()
 	inn.userV1 = userV1
+	inn.searchV1 = searchV1
+	inn.likeV1 = likeV1
+	inn.notifier = notificationV1
+	inn.centrifugoV1 = centrifugoV1
 	inn.orm = orm
 
+	searcher, err := newSearcher(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	inn.searcher = searcher
+
+	renderer, err := documents.NewRenderer(inn.cfg.Documents.FontDir)
+	if err != nil {
+		return nil, err
+	}
+
+	inn.renderer = renderer
+
 	inn.publicV1.POST("/innovations", inn.userV1.Introspect(inn.innovationPostHandler, types.User))
 	inn.publicV1.PUT("/innovations/:id", inn.userV1.Introspect(inn.innovationPutHandler, types.User))
+	inn.publicV1.GET("/innovations/export", inn.userV1.Introspect(inn.innovationsExportGetHandler, types.Business))
 	inn.publicV1.POST("/innovations/search", inn.userV1.Introspect(inn.searchPostHandler, types.User))
 	inn.publicV1.POST("/innovations/searchtitle", inn.userV1.Introspect(inn.searchTitlePostHandler, types.User))
+	inn.publicV1.POST("/innovations/similar", inn.userV1.Introspect(inn.similarPostHandler, types.User))
+	inn.publicV1.POST("/themes/:id/promote", inn.userV1.Introspect(inn.themePromotePostHandler, types.User))
+	inn.publicV1.POST("/innovations/:id/signatures", inn.userV1.Introspect(inn.signaturePostHandler, types.User))
+	inn.publicV1.GET("/innovations/:id/signatures", inn.userV1.Introspect(inn.signaturesGetHandler, types.User))
+	inn.publicV1.GET("/innovations/:id/coauthors", inn.userV1.Introspect(inn.coAuthorsGetHandler, types.User))
+	inn.publicV1.PUT("/innovations/:id/coauthors/:userid", inn.userV1.Introspect(inn.coAuthorPutHandler, types.User))
+	inn.publicV1.DELETE("/innovations/:id/coauthors/:userid", inn.userV1.Introspect(inn.coAuthorDeleteHandler, types.User))
+	inn.publicV1.GET("/innovations/:id/documents/:kind", inn.userV1.Introspect(inn.documentGetHandler, types.User))
+	inn.publicV1.GET("/documents/:code", inn.documentVerifyGetHandler)
+	inn.publicV1.POST("/innovations/:id/duplicates", inn.userV1.Introspect(inn.duplicatePostHandler, types.Moderator))
+	inn.publicV1.GET("/innovations/:id/duplicates", inn.userV1.Introspect(inn.duplicatesGetHandler, types.User))
 	inn.publicV1.POST("/innovations/:innid/images", inn.userV1.Introspect(inn.innovationPostImagesHandler, types.User))
 	inn.publicV1.GET("/innovations/:innid/images/:id", inn.userV1.Introspect(inn.innovationGetImageHandler, types.User))
+	inn.publicV1.GET("/innovations/:innid/files/:id", inn.userV1.Introspect(inn.innovationGetFileHandler, types.User))
 	inn.publicV1.GET("/innovations/:userid", inn.userV1.Introspect(inn.innovationGetByUserIDHandler, types.User))
 	inn.publicV1.GET("/innovationsdetailed", inn.userV1.Introspect(inn.innovationGetAllDetailedHandler, types.User))
 
@@ -71,3 +117,56 @@ func NewInnovationV1(ctx *context.Context,
 
 	return inn, nil
 }
+
+// notifyStateChanged tells author about new state of innovation and the
+// assigned expert about innovation sent to expertise. Change made by user
+// himself is not notified to him.
+func (inn *InnovationV1) notifyStateChanged(innovation *models.Innovation, actorID int) {
+	data := map[string]interface{}{
+		"innovation_id": innovation.ID,
+		"state":         innovation.State.String(),
+	}
+
+	if innovation.AuthorID != actorID {
+		inn.notifier.Notify(innovation.AuthorID, models.NotificationInnovationStatus,
+			fmt.Sprintf("Статус предложения «%s» изменён: %s", innovation.Title, innovation.State.String()), data)
+	}
+
+	if innovation.State != types.Expertise {
+		return
+	}
+
+	expert, err := inn.GetExpertByInnovationID(int64(innovation.ID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+
+	if err != nil {
+		inn.log.Warn().Err(err).Msgf("get expert of innovation %d failed", innovation.ID)
+		return
+	}
+
+	if expert.ExpertID != actorID {
+		inn.notifier.Notify(expert.ExpertID, models.NotificationExpertReview,
+			fmt.Sprintf("Предложение «%s» передано вам на экспертизу", innovation.Title), data)
+	}
+}
+
+// notifyCoAuthorChanged tells user about being added to or removed from
+// co-authors of innovation. Change made by user himself is not notified to
+// him.
+func (inn *InnovationV1) notifyCoAuthorChanged(innovation *models.Innovation, authorID, actorID int, added bool) {
+	if authorID == actorID {
+		return
+	}
+
+	title := fmt.Sprintf("Вы добавлены в соавторы предложения «%s»", innovation.Title)
+	if !added {
+		title = fmt.Sprintf("Вы исключены из соавторов предложения «%s»", innovation.Title)
+	}
+
+	inn.notifier.Notify(authorID, models.NotificationCoAuthor, title, map[string]interface{}{
+		"innovation_id": innovation.ID,
+		"added":         added,
+	})
+}
@@ -0,0 +1,224 @@
+package innovationv1
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+
+	centrifugov1 "github.com/sqsinformatique/rosseti-innovation-back/domains/centrifugo/v1"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/db"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/textextract"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+	"github.com/sqsinformatique/rosseti-innovation-back/types"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+)
+
+// promotionCoAuthors limits participants suggested as co-authors
+const promotionCoAuthors = 5
+
+// transcriptFileName is a name of discussion transcript among innovation
+// files, it is downloaded as /innovations/:innid/files/transcript.html
+const transcriptFileName = "transcript.html"
+
+var ErrThemePromoted = errors.New("theme is already promoted to innovation")
+
+// createPromoted creates draft of theme with suggested co-authors and
+// links theme to it, theme can be promoted once
+func (inn *InnovationV1) createPromoted(theme *models.Theme, meta types.NullMeta, coAuthors []*models.ThemeParticipant) (*models.Innovation, error) {
+	conn := *inn.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	tx, err := conn.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() // nolint
+
+	var direction *int
+	if theme.Direction != 0 {
+		direction = &theme.Direction
+	}
+
+	innovation := &models.Innovation{}
+
+	err = tx.Get(innovation, `
+		insert into production.innovation (author_id, title, tags, state, meta, direction, theme_id)
+		values ($1, $2, $3, $4, $5, $6, $7)
+		returning *`,
+		theme.AuthorID, theme.Title, theme.Tags, types.Draft, meta, direction, theme.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	authorIDs := make([]int, 0, len(coAuthors))
+	for _, coAuthor := range coAuthors {
+		authorIDs = append(authorIDs, coAuthor.UserID)
+	}
+
+	_, err = addCoAuthors(tx, innovation.ID, authorIDs...)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := tx.Exec(`
+		update production.theme set innovation_id = $2, updated_at = now()
+		where id = $1 and innovation_id is null`, theme.ID, innovation.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if updated, err := result.RowsAffected(); err != nil || updated == 0 {
+		return nil, ErrThemePromoted
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return innovation, nil
+}
+
+// attachTranscript stores HTML transcript of theme chat as innovation file,
+// CSV transcript is indexed as file text
+func (inn *InnovationV1) attachTranscript(innovation *models.Innovation, themeID int) error {
+	chat, err := inn.centrifugoV1.GetChat(themeID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var transcript bytes.Buffer
+
+	err = inn.centrifugoV1.ExportChat(chat, false, models.ChatExportHTML, &transcript)
+	if err != nil {
+		return err
+	}
+
+	mongoconn := *inn.mongodb
+	bucket, err := gridfs.NewBucket(
+		mongoconn.Database(inn.cfg.Mongo.InnovationsDB),
+	)
+	if err != nil {
+		return err
+	}
+
+	fileID, err := bucket.UploadFromStream(strconv.Itoa(innovation.ID)+"_"+transcriptFileName, &transcript)
+	if err != nil {
+		return err
+	}
+
+	var content bytes.Buffer
+
+	err = inn.centrifugoV1.ExportChat(chat, false, models.ChatExportCSV, &content)
+	if err != nil {
+		return err
+	}
+
+	text := content.String()
+	if len(text) > textextract.MaxTextLength {
+		text = strings.ToValidUTF8(text[:textextract.MaxTextLength], "")
+	}
+
+	innovationFile := &models.InnovationFiles{
+		ID:       innovation.ID,
+		FileID:   fileID.Hex(),
+		FileName: transcriptFileName,
+		Content:  text,
+	}
+
+	err = inn.SaveInnovationFile(innovationFile)
+	if err != nil {
+		return err
+	}
+
+	return inn.searcher.IndexAttachment(innovationFile)
+}
+
+// setInnovationMeta sets one key of innovation meta
+func (inn *InnovationV1) setInnovationMeta(innovation *models.Innovation, key string, value interface{}) error {
+	conn := *inn.db
+	if conn == nil {
+		return db.ErrDBConnNotEstablished
+	}
+
+	innovation.Meta.Map[key] = value
+
+	return conn.Get(innovation, `
+		update production.innovation set meta = $2, updated_at = now()
+		where id = $1
+		returning *`, innovation.ID, innovation.Meta)
+}
+
+// PromoteTheme creates draft innovation of theme author from theme title,
+// tags and direction. The most active participants of discussion become
+// co-authors and chat transcript is attached to the draft.
+// Theme author and moderators can promote theme.
+func (inn *InnovationV1) PromoteTheme(themeID int, user *models.User) (*models.ThemePromotion, error) {
+	theme, err := inn.centrifugoV1.GetTheme(themeID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = centrifugov1.CanManageTheme(user, theme)
+	if err != nil {
+		return nil, err
+	}
+
+	if theme.InnovationID != nil {
+		return nil, ErrThemePromoted
+	}
+
+	coAuthors, err := inn.centrifugoV1.ActiveParticipants(themeID, promotionCoAuthors, theme.AuthorID)
+	if err != nil {
+		inn.log.Warn().Err(err).Msgf("count participants failed, theme %d", themeID)
+
+		coAuthors = []*models.ThemeParticipant{}
+	}
+
+	meta := types.NullMeta{
+		Valid: true,
+		Map: map[string]interface{}{
+			"promoted_by": user.ID,
+		},
+	}
+
+	innovation, err := inn.createPromoted(theme, meta, coAuthors)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, coAuthor := range coAuthors {
+		inn.notifyCoAuthorChanged(innovation, coAuthor.UserID, user.ID, true)
+	}
+
+	theme.InnovationID = &innovation.ID
+
+	promotion := &models.ThemePromotion{
+		Innovation:         innovation,
+		Theme:              theme,
+		SuggestedCoAuthors: coAuthors,
+	}
+
+	// Draft is created even if transcript is not attached
+	err = inn.attachTranscript(innovation, themeID)
+	if err != nil {
+		inn.log.Warn().Err(err).Msgf("attach transcript failed, theme %d innovation %d", themeID, innovation.ID)
+		return promotion, nil
+	}
+
+	promotion.Transcript = "/api/v1/innovations/" + strconv.Itoa(innovation.ID) + "/files/" + transcriptFileName
+
+	err = inn.setInnovationMeta(innovation, "transcript", promotion.Transcript)
+	if err != nil {
+		inn.log.Warn().Err(err).Msgf("link transcript failed, innovation %d", innovation.ID)
+	}
+
+	return promotion, nil
+}
@@ -0,0 +1,303 @@
+package innovationv1
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/db"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/xlsx"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+)
+
+// registryFlushEvery is a count of rows written between flushes of
+// response, so client gets registry while it is read from database
+const registryFlushEvery = 500
+
+var ErrBadRegistryFormat = errors.New("export format must be csv or xlsx")
+
+// registryColumns are headers of registry export
+var registryColumns = []string{
+	"id", "title", "state", "direction", "author_id", "author", "author_position", "company",
+	"co_authors", "created_at", "updated_at", "recognized_at", "effect", "likes",
+}
+
+// registrySelect returns innovations with names of author, co-authors and
+// direction. All innovations are returned for null $1, otherwise
+// innovations of $1 in the same order.
+const registrySelect = `
+	select i.id, coalesce(i.title, '') as title, coalesce(i.state, '') as state,
+		coalesce(d.title, '') as direction, i.author_id,
+		trim(concat_ws(' ', nullif(a.user_last_name, ''), nullif(a.user_first_name, ''), nullif(a.user_middle_name, ''))) as author,
+		coalesce(a.user_position, '') as author_position,
+		coalesce(a.user_company, '') as company,
+		coalesce((
+			select string_agg(trim(concat_ws(' ', nullif(p.user_last_name, ''), nullif(p.user_first_name, ''), nullif(p.user_middle_name, ''))), ', ' order by c.created_at)
+			from production.innovation_co_authors c
+			join production.profiles p on p.id = c.author_id
+			where c.id = i.id and c.deleted_at is null
+		), '') as co_authors,
+		i.created_at, i.updated_at,
+		(
+			select min(s.created_at) from production.innovation_states s
+			where s.innovation_id = i.id and s.state = 'RECOGNIZED'
+		) as recognized_at,
+		coalesce(i.effect, '') as effect, i.like_counter as likes
+	from production.innovation i
+	left join production.profiles a on a.id = i.author_id
+	left join production.direction d on d.id = i.direction
+	where i.deleted_at is null and ($1::bigint[] is null or i.id = any($1))
+	order by case when $1::bigint[] is null then 0 else array_position($1, i.id::bigint) end, i.id`
+
+// RegistryContentType returns content type of export format, error is
+// returned for unknown format
+func RegistryContentType(format string) (string, error) {
+	switch format {
+	case models.RegistryExportCSV:
+		return "text/csv; charset=utf-8", nil
+	case models.RegistryExportXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", nil
+	}
+
+	return "", ErrBadRegistryFormat
+}
+
+// registryWriter writes registry in one of export formats
+type registryWriter interface {
+	row(cells []interface{}) error
+	flush() error
+	end() error
+}
+
+func newRegistryWriter(format string, w io.Writer) (registryWriter, error) {
+	var (
+		writer registryWriter
+		err    error
+	)
+
+	switch format {
+	case models.RegistryExportCSV:
+		writer, err = newCSVRegistry(w)
+	case models.RegistryExportXLSX:
+		writer, err = newXLSXRegistry(w)
+	default:
+		return nil, ErrBadRegistryFormat
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]interface{}, 0, len(registryColumns))
+	for _, column := range registryColumns {
+		header = append(header, column)
+	}
+
+	return writer, writer.row(header)
+}
+
+// csvRegistry writes registry as CSV, BOM makes spreadsheet editors read
+// cyrillic text as UTF-8
+type csvRegistry struct {
+	csv *csv.Writer
+}
+
+func newCSVRegistry(w io.Writer) (*csvRegistry, error) {
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return nil, err
+	}
+
+	return &csvRegistry{csv: csv.NewWriter(w)}, nil
+}
+
+func (e *csvRegistry) row(cells []interface{}) error {
+	record := make([]string, 0, len(cells))
+
+	for _, cell := range cells {
+		switch value := cell.(type) {
+		case nil:
+			record = append(record, "")
+		case string:
+			record = append(record, value)
+		case int:
+			record = append(record, strconv.Itoa(value))
+		case time.Time:
+			record = append(record, value.Format(time.RFC3339))
+		}
+	}
+
+	return e.csv.Write(record)
+}
+
+func (e *csvRegistry) flush() error {
+	e.csv.Flush()
+	return e.csv.Error()
+}
+
+func (e *csvRegistry) end() error {
+	return e.flush()
+}
+
+// xlsxRegistry writes registry as a spreadsheet
+type xlsxRegistry struct {
+	xlsx *xlsx.Writer
+}
+
+func newXLSXRegistry(w io.Writer) (*xlsxRegistry, error) {
+	writer, err := xlsx.NewWriter(w, "Реестр")
+	if err != nil {
+		return nil, err
+	}
+
+	return &xlsxRegistry{xlsx: writer}, nil
+}
+
+func (e *xlsxRegistry) row(cells []interface{}) error {
+	return e.xlsx.WriteRow(cells...)
+}
+
+func (e *xlsxRegistry) flush() error {
+	return e.xlsx.Flush()
+}
+
+func (e *xlsxRegistry) end() error {
+	return e.xlsx.Close()
+}
+
+func registryCells(row *models.RegistryRow) []interface{} {
+	var recognizedAt interface{}
+	if row.RecognizedAt.Valid {
+		recognizedAt = row.RecognizedAt.Time
+	}
+
+	return []interface{}{
+		row.ID, row.Title, row.State, row.Direction, row.AuthorID, row.Author, row.AuthorPosition,
+		row.Company, row.CoAuthors, row.CreatedAt, row.UpdatedAt, recognizedAt, row.Effect, row.Likes,
+	}
+}
+
+// writeRegistryRows writes innovations of ids or all innovations for nil
+// ids, rows are read by cursor
+func (inn *InnovationV1) writeRegistryRows(ids []int64, writer registryWriter, flush func() error) error {
+	conn := *inn.db
+	if conn == nil {
+		return db.ErrDBConnNotEstablished
+	}
+
+	rows, err := conn.Queryx(registrySelect, pq.Int64Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for count := 1; rows.Next(); count++ {
+		row := &models.RegistryRow{}
+
+		err = rows.StructScan(row)
+		if err != nil {
+			return err
+		}
+
+		err = writer.row(registryCells(row))
+		if err != nil {
+			return err
+		}
+
+		if count%registryFlushEvery == 0 {
+			if err = flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return rows.Err()
+}
+
+// searchAfter formats sort values of hit as search after parameter
+func searchAfter(hit *Hit) (string, error) {
+	values := make([]string, 0, len(hit.Sort))
+
+	for _, value := range hit.Sort {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+
+		values = append(values, string(data))
+	}
+
+	return strings.Join(values, ","), nil
+}
+
+// ExportRegistry streams innovations matching search query to w in format.
+// Empty query exports all innovations by one cursor, otherwise matching
+// innovations are exported page by page in order of relevance.
+func (inn *InnovationV1) ExportRegistry(query, format string, w io.Writer) error {
+	writer, err := newRegistryWriter(format, w)
+	if err != nil {
+		return err
+	}
+
+	flusher, _ := w.(http.Flusher)
+	flush := func() error {
+		err := writer.flush()
+		if err == nil && flusher != nil {
+			flusher.Flush()
+		}
+
+		return err
+	}
+
+	if strings.TrimSpace(query) == "" {
+		err = inn.writeRegistryRows(nil, writer, flush)
+		if err != nil {
+			return err
+		}
+
+		return writer.end()
+	}
+
+	after := ""
+
+	for {
+		page, err := inn.searcher.Search(query, after)
+		if err != nil {
+			return err
+		}
+
+		if len(page.Hits) == 0 {
+			break
+		}
+
+		ids := make([]int64, 0, len(page.Hits))
+		for _, hit := range page.Hits {
+			ids = append(ids, int64(hit.ID))
+		}
+
+		err = inn.writeRegistryRows(ids, writer, flush)
+		if err != nil {
+			return err
+		}
+
+		if err = flush(); err != nil {
+			return err
+		}
+
+		if len(page.Hits) < searchPageSize {
+			break
+		}
+
+		after, err = searchAfter(page.Hits[len(page.Hits)-1])
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.end()
+}
@@ -5,26 +5,36 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"mime/multipart"
 	"strconv"
-	"strings"
 	"time"
 
 	jsonpatch "github.com/evanphx/json-patch"
 	"github.com/sqsinformatique/rosseti-innovation-back/internal/db"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/textextract"
 	"github.com/sqsinformatique/rosseti-innovation-back/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo/gridfs"
 )
 
-const (
-	innovationIndex = "innovation"
-)
+// directionFromMeta sets direction passed in meta by old clients, direction
+// field wins over meta
+func directionFromMeta(innovation *models.Innovation) {
+	if innovation.Direction != nil {
+		return
+	}
+
+	if direction, ok := innovation.Meta.Map["direction"].(float64); ok {
+		value := int(direction)
+		innovation.Direction = &value
+	}
+}
 
 func (inn *InnovationV1) CreateInnovation(request *models.Innovation) (*models.Innovation, error) {
 
 	request.CreateTimestamp()
+	directionFromMeta(request)
 
 	result, err := inn.orm.InsertInto("innovation", request)
 	if err != nil {
@@ -34,263 +44,67 @@ func (inn *InnovationV1) CreateInnovation(request *models.Innovation) (*models.I
 	return result.(*models.Innovation), nil
 }
 
-// SearchResults wraps the Elasticsearch search response.
-//
-type SearchResults struct {
-	Total int    `json:"total"`
-	Hits  []*Hit `json:"hits"`
-}
-
-// Hit wraps the document returned in search response.
-//
-type Hit struct {
-	models.Innovation
-	// URL        string        `json:"url"`
-	Sort       []interface{} `json:"sort"`
-	Highlights *struct {
-		Title       []string `json:"title"`
-		Description []string `json:"descriptions"`
-		Alt         []string `json:"alt"`
-		Transcript  []string `json:"transcript"`
-	} `json:"highlights,omitempty"`
+// InnovationWithSimilar wraps created innovation and its closest proposals.
+type InnovationWithSimilar struct {
+	*models.Innovation
+	Similar []*SimilarHit `json:"similar"`
 }
 
-// Search returns results matching a query, paginated by after.
-//
-func (inn *InnovationV1) Search(query string, after ...string) (*SearchResults, error) {
-	var results SearchResults
-	es := *inn.elasticDB
-	res, err := es.Search(
-		es.Search.WithIndex("innovation"),
-		es.Search.WithBody(inn.buildQuery(query, after...)),
-	)
-	if err != nil {
-		return &results, err
-	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		var e map[string]interface{}
-		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
-			return &results, err
-		}
-		return &results, fmt.Errorf("[%s] %s: %s", res.Status(), e["error"].(map[string]interface{})["type"], e["error"].(map[string]interface{})["reason"])
-	}
-
-	type envelopeResponse struct {
-		Took int
-		Hits struct {
-			Total struct {
-				Value int
-			}
-			Hits []struct {
-				ID         string          `json:"_id"`
-				Source     json.RawMessage `json:"_source"`
-				Highlights json.RawMessage `json:"highlight"`
-				Sort       []interface{}   `json:"sort"`
-			}
-		}
-	}
+var ErrSelfDuplicate = errors.New("innovation can't be duplicate of itself")
 
-	var r envelopeResponse
-	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
-		return &results, err
+func (inn *InnovationV1) MarkDuplicate(id int64, request *models.InnovationDuplicate) (*models.InnovationDuplicate, error) {
+	if int64(request.DuplicateID) == id {
+		return nil, ErrSelfDuplicate
 	}
 
-	results.Total = r.Hits.Total.Value
-
-	if len(r.Hits.Hits) < 1 {
-		results.Hits = []*Hit{}
-		return &results, nil
+	if _, err := inn.GetInnovationByID(id); err != nil {
+		return nil, err
 	}
 
-	for _, hit := range r.Hits.Hits {
-		var h Hit
-		id, err := strconv.Atoi(hit.ID)
-		if err != nil {
-			inn.log.Warn().Msgf("failed convert ID: %s", hit.ID)
-		}
-
-		h.ID = id
-		h.Sort = hit.Sort
-		// h.URL = strings.Join([]string{baseURL, hit.ID, ""}, "/")
-
-		if err := json.Unmarshal(hit.Source, &h); err != nil {
-			return &results, err
-		}
-
-		if len(hit.Highlights) > 0 {
-			if err := json.Unmarshal(hit.Highlights, &h.Highlights); err != nil {
-				return &results, err
-			}
-		}
-
-		results.Hits = append(results.Hits, &h)
+	if _, err := inn.GetInnovationByID(int64(request.DuplicateID)); err != nil {
+		return nil, err
 	}
 
-	return &results, nil
-}
+	request.InnovationID = int(id)
+	request.CreateTimestamp()
 
-// Search returns results matching a query, paginated by after.
-//
-func (inn *InnovationV1) SearchTitle(query string, after ...string) (*SearchResults, error) {
-	var results SearchResults
-	es := *inn.elasticDB
-	res, err := es.Search(
-		es.Search.WithIndex("innovation"),
-		es.Search.WithBody(inn.buildQueryTitle(query, after...)),
-	)
+	result, err := inn.orm.InsertInto("innovation_duplicates", request)
 	if err != nil {
-		return &results, err
+		return nil, err
 	}
-	defer res.Body.Close()
 
-	if res.IsError() {
-		var e map[string]interface{}
-		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
-			return &results, err
-		}
-		return &results, fmt.Errorf("[%s] %s: %s", res.Status(), e["error"].(map[string]interface{})["type"], e["error"].(map[string]interface{})["reason"])
-	}
+	return result.(*models.InnovationDuplicate), nil
+}
 
-	type envelopeResponse struct {
-		Took int
-		Hits struct {
-			Total struct {
-				Value int
-			}
-			Hits []struct {
-				ID         string          `json:"_id"`
-				Source     json.RawMessage `json:"_source"`
-				Highlights json.RawMessage `json:"highlight"`
-				Sort       []interface{}   `json:"sort"`
-			}
-		}
+func (inn *InnovationV1) GetDuplicatesByInnovationID(id int64) (data *ArrayOfInnovationDuplicateData, err error) {
+	conn := *inn.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
 	}
 
-	var r envelopeResponse
-	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
-		return &results, err
+	rows, err := conn.Queryx(
+		conn.Rebind("select * from production.innovation_duplicates where innovation_id=$1 or duplicate_id=$1 order by created_at"), id)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	results.Total = r.Hits.Total.Value
+	data = &ArrayOfInnovationDuplicateData{}
 
-	if len(r.Hits.Hits) < 1 {
-		results.Hits = []*Hit{}
-		return &results, nil
-	}
+	for rows.Next() {
+		var item models.InnovationDuplicate
 
-	for _, hit := range r.Hits.Hits {
-		var h Hit
-		id, err := strconv.Atoi(hit.ID)
+		err = rows.StructScan(&item)
 		if err != nil {
-			inn.log.Warn().Msgf("failed convert ID: %s", hit.ID)
-		}
-
-		h.ID = id
-		h.Sort = hit.Sort
-		// h.URL = strings.Join([]string{baseURL, hit.ID, ""}, "/")
-
-		if err := json.Unmarshal(hit.Source, &h); err != nil {
-			return &results, err
-		}
-
-		if len(hit.Highlights) > 0 {
-			if err := json.Unmarshal(hit.Highlights, &h.Highlights); err != nil {
-				return &results, err
-			}
+			return nil, err
 		}
 
-		results.Hits = append(results.Hits, &h)
-	}
-
-	return &results, nil
-}
-
-func (inn *InnovationV1) buildQuery(query string, after ...string) io.Reader {
-	var b strings.Builder
-
-	b.WriteString("{\n")
-
-	if query == "" {
-		b.WriteString(searchAll)
-	} else {
-		b.WriteString(fmt.Sprintf(searchMatch, query))
-	}
-
-	if len(after) > 0 && after[0] != "" && after[0] != "null" {
-		b.WriteString(",\n")
-		b.WriteString(fmt.Sprintf(`	"search_after": %s`, after))
-	}
-
-	b.WriteString("\n}")
-
-	// fmt.Printf("%s\n", b.String())
-	return strings.NewReader(b.String())
-}
-
-func (inn *InnovationV1) buildQueryTitle(query string, after ...string) io.Reader {
-	var b strings.Builder
-
-	b.WriteString("{\n")
-
-	if query == "" {
-		b.WriteString(searchAll)
-	} else {
-		b.WriteString(fmt.Sprintf(searchMatchTitle, query))
-	}
-
-	if len(after) > 0 && after[0] != "" && after[0] != "null" {
-		b.WriteString(",\n")
-		b.WriteString(fmt.Sprintf(`	"search_after": %s`, after))
+		*data = append(*data, item)
 	}
 
-	b.WriteString("\n}")
-
-	// fmt.Printf("%s\n", b.String())
-	return strings.NewReader(b.String())
+	return data, nil
 }
 
-const searchAll = `
-	"query" : { "match_all" : {} },
-	"size" : 25,
-	"sort" : { "published" : "desc", "_doc" : "asc" }`
-
-const searchMatch = `
-	"query" : {
-		"multi_match" : {
-			"query" : %q,
-			"fields" : ["title^100", "descriptions^50", "alt^10", "transcript"],
-			"operator" : "and"
-		}
-	},
-	"highlight" : {
-		"fields" : {
-			"title" : { "number_of_fragments" : 0 },
-			"descriptions" : { "number_of_fragments" : 0 },
-			"alt" : { "number_of_fragments" : 0 },
-			"transcript" : { "number_of_fragments" : 5, "fragment_size" : 25 }
-		}
-	},
-	"size" : 25,
-	"sort" : [ { "_score" : "desc" }, { "_doc" : "asc" } ]`
-
-const searchMatchTitle = `
-	"query" : {
-		"multi_match" : {
-			"query" : %q,
-			"fields" : ["title^100"],
-			"operator" : "and"
-		}
-	},
-	"highlight" : {
-		"fields" : {
-			"title" : { "number_of_fragments" : 0 }
-		}
-	},
-	"size" : 25,
-	"sort" : [ { "_score" : "desc" }, { "_doc" : "asc" } ]`
-
 func writeToGridFile(fileName string, file multipart.File, gridFile *gridfs.UploadStream) (int, error) {
 	reader := bufio.NewReader(file)
 	defer func() { file.Close() }()
@@ -317,7 +131,7 @@ func writeToGridFile(fileName string, file multipart.File, gridFile *gridfs.Uplo
 	return fileSize, nil
 }
 
-func (inn *InnovationV1) CreateImages(actID string, multipartForm *multipart.Form) error {
+func (inn *InnovationV1) CreateImages(innovationID int, multipartForm *multipart.Form) error {
 	for _, fileHeaders := range multipartForm.File {
 		for _, fileHeader := range fileHeaders {
 			file, _ := fileHeader.Open()
@@ -330,7 +144,7 @@ func (inn *InnovationV1) CreateImages(actID string, multipartForm *multipart.For
 			}
 
 			gridFile, err := bucket.OpenUploadStream(
-				actID + "_" + fileHeader.Filename, // this is the name of the file which will be saved in the database
+				strconv.Itoa(innovationID) + "_" + fileHeader.Filename, // this is the name of the file which will be saved in the database
 			)
 			if err != nil {
 				return err
@@ -342,23 +156,96 @@ func (inn *InnovationV1) CreateImages(actID string, multipartForm *multipart.For
 			}
 
 			inn.log.Debug().Msgf("Write file to DB was successful. File size: %d \n", fileSize)
+
+			fileID := ""
+			if objectID, ok := gridFile.FileID.(primitive.ObjectID); ok {
+				fileID = objectID.Hex()
+			}
+
+			// Extraction or indexing failure must not reject already stored file
+			err = inn.indexFile(innovationID, fileID, fileHeader)
+			if err != nil {
+				inn.log.Error().Err(err).Msgf("failed index file %s of innovation %d", fileHeader.Filename, innovationID)
+			}
 		}
 	}
 
 	return nil
 }
 
+// indexFile extracts text of supported document and stores it in database
+// and search backend
+func (inn *InnovationV1) indexFile(innovationID int, fileID string, fileHeader *multipart.FileHeader) error {
+	if !textextract.Supported(fileHeader.Filename) {
+		return nil
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	content, err := textextract.Text(fileHeader.Filename, file, fileHeader.Size)
+	if err != nil {
+		return err
+	}
+
+	innovationFile := &models.InnovationFiles{
+		ID:       innovationID,
+		FileID:   fileID,
+		FileName: fileHeader.Filename,
+		Content:  content,
+	}
+
+	err = inn.SaveInnovationFile(innovationFile)
+	if err != nil {
+		return err
+	}
+
+	return inn.searcher.IndexAttachment(innovationFile)
+}
+
+// SaveInnovationFile stores file of innovation, file with the same name is replaced
+func (inn *InnovationV1) SaveInnovationFile(request *models.InnovationFiles) error {
+	conn := *inn.db
+	if conn == nil {
+		return db.ErrDBConnNotEstablished
+	}
+
+	_, err := conn.Exec(conn.Rebind("DELETE FROM production.innovation_files WHERE id=$1 and file_name=$2"), request.ID, request.FileName)
+	if err != nil {
+		return err
+	}
+
+	request.CreateTimestamp()
+
+	_, err = inn.orm.InsertInto("innovation_files", request)
+
+	return err
+}
+
 func (inn *InnovationV1) GetImage(actID, imageID string) (*bytes.Buffer, int64, error) {
+	return inn.downloadFile(inn.cfg.Mongo.ImageDB, actID+"_"+imageID)
+}
+
+// GetInnovationFile returns file of innovation generated by service, like
+// transcript of discussion the innovation is promoted from
+func (inn *InnovationV1) GetInnovationFile(innovationID, fileName string) (*bytes.Buffer, int64, error) {
+	return inn.downloadFile(inn.cfg.Mongo.InnovationsDB, innovationID+"_"+fileName)
+}
+
+func (inn *InnovationV1) downloadFile(database, name string) (*bytes.Buffer, int64, error) {
 	mongoconn := *inn.mongodb
 	bucket, err := gridfs.NewBucket(
-		mongoconn.Database(inn.cfg.Mongo.ImageDB),
+		mongoconn.Database(database),
 	)
 	if err != nil {
 		return nil, 0, err
 	}
 
 	var buf bytes.Buffer
-	dStream, err := bucket.DownloadToStreamByName(actID+"_"+imageID, &buf)
+	dStream, err := bucket.DownloadToStreamByName(name, &buf)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -423,6 +310,8 @@ func (inn *InnovationV1) UpdateInnovationByID(id int64, patch *[]byte) (writeDat
 		return
 	}
 
+	directionFromMeta(writeData)
+
 	if inn.db == nil {
 		return nil, db.ErrDBConnNotEstablished
 	}
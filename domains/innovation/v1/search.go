@@ -0,0 +1,86 @@
+package innovationv1
+
+import (
+	"errors"
+
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/context"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+)
+
+const (
+	SearchBackendElastic  = "elastic"
+	SearchBackendPostgres = "postgres"
+)
+
+const (
+	searchPageSize = 25
+	similarLimit   = 5
+)
+
+var ErrUnknownSearchBackend = errors.New("unknown search backend")
+
+// Searcher is a full-text search backend of innovations
+type Searcher interface {
+	// Search returns innovations matching query in any text field or
+	// attached document, paginated by sort values of the last hit
+	Search(query string, after ...string) (*SearchResults, error)
+	// SearchTitle returns innovations matching query in title
+	SearchTitle(query string, after ...string) (*SearchResults, error)
+	// SearchSimilar returns the closest proposals for request by title,
+	// problem and descriptions. Innovation with request ID is excluded.
+	SearchSimilar(request *models.Innovation, limit int) ([]*SimilarHit, error)
+	// IndexInnovation makes created or updated innovation searchable
+	IndexInnovation(data *models.Innovation) error
+	// IndexAttachment makes text of attached document searchable, file
+	// is already saved by InnovationV1.SaveInnovationFile
+	IndexAttachment(file *models.InnovationFiles) error
+}
+
+// newSearcher returns search backend selected in configuration
+func newSearcher(ctx *context.Context) (Searcher, error) {
+	switch ctx.Config.Search.Backend {
+	case SearchBackendElastic:
+		ctx.RegisterElasticIndex(innovationIndex, innovationMapping)
+		return NewElasticSearcher(ctx.GetPackageLogger(empty{}), ctx.GetElasticDB()), nil
+	case SearchBackendPostgres:
+		return NewPostgresSearcher(ctx.GetPackageLogger(empty{}), ctx.GetDatabase()), nil
+	default:
+		return nil, ErrUnknownSearchBackend
+	}
+}
+
+// SearchResults wraps the search backend response.
+type SearchResults struct {
+	Total int    `json:"total"`
+	Hits  []*Hit `json:"hits"`
+}
+
+// Hit wraps the document returned in search response.
+type Hit struct {
+	models.Innovation
+	// URL        string        `json:"url"`
+	Sort        []interface{}    `json:"sort"`
+	Highlights  *HitHighlights   `json:"highlights,omitempty"`
+	Attachments []*AttachmentHit `json:"attachments,omitempty"`
+}
+
+// HitHighlights contains fragments of matched fields
+type HitHighlights struct {
+	Title       []string `json:"title"`
+	Description []string `json:"descriptions"`
+	Alt         []string `json:"alt"`
+	Transcript  []string `json:"transcript"`
+}
+
+// AttachmentHit is an attached document matched the query
+type AttachmentHit struct {
+	FileID     string   `json:"file_id"`
+	FileName   string   `json:"file_name"`
+	Highlights []string `json:"highlights,omitempty"`
+}
+
+// SimilarHit wraps the innovation found by similarity query.
+type SimilarHit struct {
+	models.Innovation
+	Score float64 `json:"score"`
+}
@@ -0,0 +1,477 @@
+package innovationv1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/rs/zerolog"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+)
+
+const innovationIndex = "innovation"
+
+// Text fields are analyzed as russian like postgres backend does, other
+// fields are mapped dynamically. Attachments are nested to find out which
+// of them matched the query. Existing index of conflicting mapping is
+// migrated to a new one on startup.
+const innovationMapping = `{
+	"mappings" : {
+		"properties" : {
+			"title" : { "type" : "text", "analyzer" : "russian" },
+			"tags" : { "type" : "text", "analyzer" : "russian" },
+			"problem" : { "type" : "text", "analyzer" : "russian" },
+			"descriptions" : { "type" : "text", "analyzer" : "russian" },
+			"effect" : { "type" : "text", "analyzer" : "russian" },
+			"state" : { "type" : "text", "fields" : { "keyword" : { "type" : "keyword", "ignore_above" : 256 } } },
+			"attachments" : {
+				"type" : "nested",
+				"properties" : {
+					"file_id" : { "type" : "keyword" },
+					"file_name" : { "type" : "keyword" },
+					"content" : { "type" : "text", "analyzer" : "russian" }
+				}
+			}
+		}
+	}
+}`
+
+// elasticSearcher keeps innovations in elasticsearch index
+type elasticSearcher struct {
+	log       zerolog.Logger
+	elasticDB **elasticsearch.Client
+}
+
+// NewElasticSearcher returns elasticsearch search backend
+func NewElasticSearcher(log zerolog.Logger, elasticDB **elasticsearch.Client) Searcher {
+	return &elasticSearcher{log: log, elasticDB: elasticDB}
+}
+
+// attachmentDoc is an attached document in innovation search document
+type attachmentDoc struct {
+	FileID   string `json:"file_id"`
+	FileName string `json:"file_name"`
+	Content  string `json:"content,omitempty"`
+}
+
+// Search returns results matching a query, paginated by after.
+func (e *elasticSearcher) Search(query string, after ...string) (*SearchResults, error) {
+	return e.search(e.buildQuery(query, after...))
+}
+
+// SearchTitle returns results matching a query in title, paginated by after.
+func (e *elasticSearcher) SearchTitle(query string, after ...string) (*SearchResults, error) {
+	return e.search(e.buildQueryTitle(query, after...))
+}
+
+func (e *elasticSearcher) search(body io.Reader) (*SearchResults, error) {
+	var results SearchResults
+	es := *e.elasticDB
+	res, err := es.Search(
+		es.Search.WithIndex(innovationIndex),
+		es.Search.WithBody(body),
+		es.Search.WithSourceExcludes("attachments.content"),
+	)
+	if err != nil {
+		return &results, err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		var e map[string]interface{}
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
+			return &results, err
+		}
+		return &results, fmt.Errorf("[%s] %s: %s", res.Status(), e["error"].(map[string]interface{})["type"], e["error"].(map[string]interface{})["reason"])
+	}
+
+	type envelopeResponse struct {
+		Took int
+		Hits struct {
+			Total struct {
+				Value int
+			}
+			Hits []struct {
+				ID         string          `json:"_id"`
+				Source     json.RawMessage `json:"_source"`
+				Highlights json.RawMessage `json:"highlight"`
+				Sort       []interface{}   `json:"sort"`
+				InnerHits  struct {
+					Attachments struct {
+						Hits struct {
+							Hits []struct {
+								Nested struct {
+									Offset int `json:"offset"`
+								} `json:"_nested"`
+								Highlights map[string][]string `json:"highlight"`
+							}
+						}
+					} `json:"attachments"`
+				} `json:"inner_hits"`
+			}
+		}
+	}
+
+	var r envelopeResponse
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return &results, err
+	}
+
+	results.Total = r.Hits.Total.Value
+
+	if len(r.Hits.Hits) < 1 {
+		results.Hits = []*Hit{}
+		return &results, nil
+	}
+
+	for _, hit := range r.Hits.Hits {
+		var h Hit
+		id, err := strconv.Atoi(hit.ID)
+		if err != nil {
+			e.log.Warn().Msgf("failed convert ID: %s", hit.ID)
+		}
+
+		h.Sort = hit.Sort
+		// h.URL = strings.Join([]string{baseURL, hit.ID, ""}, "/")
+
+		if err := json.Unmarshal(hit.Source, &h); err != nil {
+			return &results, err
+		}
+
+		h.ID = id
+
+		if len(hit.Highlights) > 0 {
+			if err := json.Unmarshal(hit.Highlights, &h.Highlights); err != nil {
+				return &results, err
+			}
+		}
+
+		// Source contains all attachments, only matched ones are returned
+		attachments := h.Attachments
+		h.Attachments = nil
+
+		for _, innerHit := range hit.InnerHits.Attachments.Hits.Hits {
+			if innerHit.Nested.Offset >= len(attachments) {
+				continue
+			}
+
+			attachment := attachments[innerHit.Nested.Offset]
+			attachment.Highlights = innerHit.Highlights["attachments.content"]
+			h.Attachments = append(h.Attachments, attachment)
+		}
+
+		results.Hits = append(results.Hits, &h)
+	}
+
+	return &results, nil
+}
+
+// IndexInnovation puts innovation to search index. Document is updated
+// partially, so attachments indexed earlier are kept.
+func (e *elasticSearcher) IndexInnovation(data *models.Innovation) error {
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"doc":           data,
+		"doc_as_upsert": true,
+	})
+	if err != nil {
+		return err
+	}
+
+	req := esapi.UpdateRequest{
+		Index:      innovationIndex,
+		DocumentID: strconv.Itoa(data.ID),
+		Body:       bytes.NewReader(jsonData),
+		Refresh:    "true",
+	}
+
+	// Perform the request with the client.
+	res, err := req.Do(context.Background(), *e.elasticDB)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		e.log.Error().Msgf("[%s] Error indexing document ID=%d", res.Status(), data.ID)
+		return nil
+	}
+
+	// Deserialize the response into a map.
+	var r map[string]interface{}
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return err
+	}
+
+	// Print the response status and indexed document version.
+	e.log.Debug().Msgf("[%s] %s; version=%d", res.Status(), r["result"], int(r["_version"].(float64)))
+
+	return nil
+}
+
+const attachmentScript = `
+	if (ctx._source.attachments == null) {
+		ctx._source.attachments = [];
+	}
+	ctx._source.attachments.removeIf(a -> a.file_name == params.attachment.file_name);
+	ctx._source.attachments.add(params.attachment);`
+
+// IndexAttachment adds attachment with extracted text to innovation search document
+func (e *elasticSearcher) IndexAttachment(file *models.InnovationFiles) error {
+	innovationID := file.ID
+	attachment := &attachmentDoc{
+		FileID:   file.FileID,
+		FileName: file.FileName,
+		Content:  file.Content,
+	}
+
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"script": map[string]interface{}{
+			"source": attachmentScript,
+			"lang":   "painless",
+			"params": map[string]interface{}{
+				"attachment": attachment,
+			},
+		},
+		"upsert": map[string]interface{}{
+			"attachments": []*attachmentDoc{attachment},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	req := esapi.UpdateRequest{
+		Index:      innovationIndex,
+		DocumentID: strconv.Itoa(innovationID),
+		Body:       bytes.NewReader(jsonData),
+		Refresh:    "true",
+	}
+
+	res, err := req.Do(context.Background(), *e.elasticDB)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("[%s] error indexing attachment %s of innovation ID=%d", res.Status(), attachment.FileName, innovationID)
+	}
+
+	return nil
+}
+
+// SearchSimilar uses more_like_this query over title, problem and descriptions
+func (e *elasticSearcher) SearchSimilar(request *models.Innovation, limit int) ([]*SimilarHit, error) {
+	results := []*SimilarHit{}
+
+	if limit <= 0 {
+		limit = similarLimit
+	}
+
+	query := map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must": map[string]interface{}{
+					"more_like_this": map[string]interface{}{
+						"fields": []string{"title", "problem", "descriptions"},
+						"like": []interface{}{
+							map[string]interface{}{
+								"_index": innovationIndex,
+								"doc": map[string]string{
+									"title":        request.Title,
+									"problem":      request.Problem,
+									"descriptions": request.Description,
+								},
+							},
+						},
+						"min_term_freq":        1,
+						"min_doc_freq":         1,
+						"max_query_terms":      25,
+						"minimum_should_match": "30%",
+					},
+				},
+				"must_not": map[string]interface{}{
+					"ids": map[string]interface{}{
+						"values": []string{strconv.Itoa(request.ID)},
+					},
+				},
+			},
+		},
+		"size": limit,
+	}
+
+	body, err := json.Marshal(query)
+	if err != nil {
+		return results, err
+	}
+
+	es := *e.elasticDB
+	res, err := es.Search(
+		es.Search.WithIndex(innovationIndex),
+		es.Search.WithBody(bytes.NewReader(body)),
+	)
+	if err != nil {
+		return results, err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		var e map[string]interface{}
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
+			return results, err
+		}
+		return results, fmt.Errorf("[%s] %s: %s", res.Status(), e["error"].(map[string]interface{})["type"], e["error"].(map[string]interface{})["reason"])
+	}
+
+	type envelopeResponse struct {
+		Hits struct {
+			Hits []struct {
+				ID     string          `json:"_id"`
+				Score  float64         `json:"_score"`
+				Source json.RawMessage `json:"_source"`
+			}
+		}
+	}
+
+	var r envelopeResponse
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return results, err
+	}
+
+	for _, hit := range r.Hits.Hits {
+		var h SimilarHit
+
+		if err := json.Unmarshal(hit.Source, &h); err != nil {
+			return results, err
+		}
+
+		id, err := strconv.Atoi(hit.ID)
+		if err != nil {
+			e.log.Warn().Msgf("failed convert ID: %s", hit.ID)
+		}
+
+		h.ID = id
+		h.Score = hit.Score
+
+		results = append(results, &h)
+	}
+
+	return results, nil
+}
+
+func (e *elasticSearcher) buildQuery(query string, after ...string) io.Reader {
+	var b strings.Builder
+
+	b.WriteString("{\n")
+
+	if query == "" {
+		b.WriteString(searchAll)
+	} else {
+		b.WriteString(fmt.Sprintf(searchMatch, query))
+	}
+
+	if len(after) > 0 && after[0] != "" && after[0] != "null" {
+		b.WriteString(",\n")
+		b.WriteString(fmt.Sprintf(`	"search_after": %s`, after))
+	}
+
+	b.WriteString("\n}")
+
+	// fmt.Printf("%s\n", b.String())
+	return strings.NewReader(b.String())
+}
+
+func (e *elasticSearcher) buildQueryTitle(query string, after ...string) io.Reader {
+	var b strings.Builder
+
+	b.WriteString("{\n")
+
+	if query == "" {
+		b.WriteString(searchAll)
+	} else {
+		b.WriteString(fmt.Sprintf(searchMatchTitle, query))
+	}
+
+	if len(after) > 0 && after[0] != "" && after[0] != "null" {
+		b.WriteString(",\n")
+		b.WriteString(fmt.Sprintf(`	"search_after": %s`, after))
+	}
+
+	b.WriteString("\n}")
+
+	// fmt.Printf("%s\n", b.String())
+	return strings.NewReader(b.String())
+}
+
+const searchAll = `
+	"query" : { "match_all" : {} },
+	"size" : 25,
+	"sort" : [ { "created_at" : { "order" : "desc", "unmapped_type" : "date" } }, { "_doc" : "asc" } ]`
+
+const searchMatch = `
+	"query" : {
+		"bool" : {
+			"should" : [
+				{
+					"multi_match" : {
+						"query" : %[1]q,
+						"fields" : ["title^100", "tags^50", "descriptions^50", "problem^10", "effect^10"],
+						"operator" : "and"
+					}
+				},
+				{
+					"nested" : {
+						"path" : "attachments",
+						"ignore_unmapped" : true,
+						"score_mode" : "max",
+						"query" : {
+							"match" : {
+								"attachments.content" : { "query" : %[1]q, "operator" : "and" }
+							}
+						},
+						"inner_hits" : {
+							"_source" : false,
+							"highlight" : {
+								"fields" : {
+									"attachments.content" : { "number_of_fragments" : 3, "fragment_size" : 150 }
+								}
+							}
+						}
+					}
+				}
+			],
+			"minimum_should_match" : 1
+		}
+	},
+	"highlight" : {
+		"fields" : {
+			"title" : { "number_of_fragments" : 0 },
+			"descriptions" : { "number_of_fragments" : 0 },
+			"alt" : { "number_of_fragments" : 0 },
+			"transcript" : { "number_of_fragments" : 5, "fragment_size" : 25 }
+		}
+	},
+	"size" : 25,
+	"sort" : [ { "_score" : "desc" }, { "_doc" : "asc" } ]`
+
+const searchMatchTitle = `
+	"query" : {
+		"multi_match" : {
+			"query" : %q,
+			"fields" : ["title^100"],
+			"operator" : "and"
+		}
+	},
+	"highlight" : {
+		"fields" : {
+			"title" : { "number_of_fragments" : 0 }
+		}
+	},
+	"size" : 25,
+	"sort" : [ { "_score" : "desc" }, { "_doc" : "asc" } ]`
@@ -0,0 +1,337 @@
+package innovationv1
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/db"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+)
+
+var ErrBadSearchAfter = errors.New("bad search after value")
+
+// Highlighted fragments are marked the same way elasticsearch does
+const (
+	headlineOptions           = "HighlightAll=true, StartSel=<em>, StopSel=</em>"
+	attachmentHeadlineOptions = "MaxFragments=3, MaxWords=25, MinWords=10, FragmentDelimiter=\" ... \", StartSel=<em>, StopSel=</em>"
+	fragmentDelimiter         = " ... "
+	highlightStart            = "<em>"
+)
+
+// Documents are built by production.innovation_document and
+// to_tsvector('russian', ...) expressions covered by GIN indices,
+// see migration 7_innovation_search.sql
+const pgSearchAll = `
+	select s.* from (
+		select i.*, 0::real as rank, count(*) over () as total, '' as title_highlight, '' as descriptions_highlight
+		from production.innovation i
+		where i.deleted_at is null
+	) s
+	where $1::integer is null or s.id < $1
+	order by s.id desc
+	limit $2`
+
+const pgSearchMatch = `
+	select s.*,
+		ts_headline('russian', coalesce(s.title, ''), q.query, '` + headlineOptions + `') as title_highlight,
+		ts_headline('russian', coalesce(s.descriptions, ''), q.query, '` + headlineOptions + `') as descriptions_highlight
+	from (
+		select i.*, ts_rank(production.innovation_document(i.title, i.tags, i.problem, i.descriptions, i.effect), q.query) + coalesce(a.rank, 0) as rank,
+			count(*) over () as total
+		from production.innovation i
+		cross join websearch_to_tsquery('russian', $1) q(query)
+		left join lateral (
+			select max(ts_rank(to_tsvector('russian', coalesce(f.content, '')), q.query)) as rank
+			from production.innovation_files f
+			where f.id = i.id and f.deleted_at is null and to_tsvector('russian', coalesce(f.content, '')) @@ q.query
+		) a on true
+		where i.deleted_at is null and
+			(production.innovation_document(i.title, i.tags, i.problem, i.descriptions, i.effect) @@ q.query or a.rank is not null)
+	) s, websearch_to_tsquery('russian', $1) q(query)
+	where $2::real is null or s.rank < $2::real or (s.rank = $2::real and s.id > $3)
+	order by s.rank desc, s.id
+	limit $4`
+
+const pgSearchTitle = `
+	select s.*,
+		ts_headline('russian', coalesce(s.title, ''), q.query, '` + headlineOptions + `') as title_highlight,
+		'' as descriptions_highlight
+	from (
+		select i.*, ts_rank(to_tsvector('russian', coalesce(i.title, '')), q.query) as rank, count(*) over () as total
+		from production.innovation i, websearch_to_tsquery('russian', $1) q(query)
+		where i.deleted_at is null and to_tsvector('russian', coalesce(i.title, '')) @@ q.query
+	) s, websearch_to_tsquery('russian', $1) q(query)
+	where $2::real is null or s.rank < $2::real or (s.rank = $2::real and s.id > $3)
+	order by s.rank desc, s.id
+	limit $4`
+
+const pgSearchAttachments = `
+	select f.id, f.file_id, f.file_name, ts_headline('russian', coalesce(f.content, ''), q.query, '` + attachmentHeadlineOptions + `') as highlight
+	from production.innovation_files f, websearch_to_tsquery('russian', ?) q(query)
+	where f.id in (?) and f.deleted_at is null and to_tsvector('russian', coalesce(f.content, '')) @@ q.query
+	order by f.id, f.file_name`
+
+// Words of request are joined by OR, so proposals sharing part of the
+// vocabulary are found and ordered by rank
+const pgSearchSimilar = `
+	select i.*, ts_rank(production.innovation_document(i.title, i.tags, i.problem, i.descriptions, i.effect), q.query) as rank
+	from production.innovation i, (select replace(plainto_tsquery('russian', $1)::text, '&', '|')::tsquery as query) q
+	where i.deleted_at is null and i.id <> $2 and
+		production.innovation_document(i.title, i.tags, i.problem, i.descriptions, i.effect) @@ q.query
+	order by rank desc, i.id
+	limit $3`
+
+// postgresSearcher searches innovations stored in postgres by tsvector
+// documents, so it needs no separate index
+type postgresSearcher struct {
+	log zerolog.Logger
+	db  **sqlx.DB
+}
+
+// NewPostgresSearcher returns postgres full-text search backend
+func NewPostgresSearcher(log zerolog.Logger, db **sqlx.DB) Searcher {
+	return &postgresSearcher{log: log, db: db}
+}
+
+// pgHit is a row of search query
+type pgHit struct {
+	models.Innovation
+	Rank                  float32 `db:"rank"`
+	Total                 int     `db:"total"`
+	TitleHighlight        string  `db:"title_highlight"`
+	DescriptionsHighlight string  `db:"descriptions_highlight"`
+}
+
+// parseAfter splits sort values of the last hit, both "[1.5,10]" and
+// "1.5,10" are accepted
+func parseAfter(count int, after ...string) ([]string, error) {
+	if len(after) == 0 || after[0] == "" || after[0] == "null" {
+		return nil, nil
+	}
+
+	values := strings.Split(strings.Trim(after[0], "[]"), ",")
+	if len(values) != count {
+		return nil, ErrBadSearchAfter
+	}
+
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
+		if _, err := strconv.ParseFloat(values[i], 64); err != nil {
+			return nil, ErrBadSearchAfter
+		}
+	}
+
+	return values, nil
+}
+
+// Search returns innovations matching query in any text field or attached
+// document. Empty query returns the newest innovations.
+func (p *postgresSearcher) Search(query string, after ...string) (*SearchResults, error) {
+	if strings.TrimSpace(query) == "" {
+		return p.searchAll(after...)
+	}
+
+	return p.searchMatch(pgSearchMatch, query, true, after...)
+}
+
+// SearchTitle returns innovations matching query in title
+func (p *postgresSearcher) SearchTitle(query string, after ...string) (*SearchResults, error) {
+	if strings.TrimSpace(query) == "" {
+		return p.searchAll(after...)
+	}
+
+	return p.searchMatch(pgSearchTitle, query, false, after...)
+}
+
+func (p *postgresSearcher) searchAll(after ...string) (*SearchResults, error) {
+	conn := *p.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	values, err := parseAfter(1, after...)
+	if err != nil {
+		return nil, err
+	}
+
+	var afterID interface{}
+	if values != nil {
+		afterID = values[0]
+	}
+
+	rows := []*pgHit{}
+	err = conn.Select(&rows, pgSearchAll, afterID, searchPageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	results := &SearchResults{Hits: []*Hit{}}
+
+	for _, row := range rows {
+		results.Total = row.Total
+		results.Hits = append(results.Hits, &Hit{
+			Innovation: row.Innovation,
+			Sort:       []interface{}{row.ID},
+		})
+	}
+
+	return results, nil
+}
+
+func (p *postgresSearcher) searchMatch(sqlQuery, query string, withAttachments bool, after ...string) (*SearchResults, error) {
+	conn := *p.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	values, err := parseAfter(2, after...)
+	if err != nil {
+		return nil, err
+	}
+
+	var afterRank, afterID interface{}
+	if values != nil {
+		afterRank, afterID = values[0], values[1]
+	}
+
+	rows := []*pgHit{}
+	err = conn.Select(&rows, sqlQuery, query, afterRank, afterID, searchPageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	results := &SearchResults{Hits: []*Hit{}}
+	hits := make(map[int]*Hit, len(rows))
+	ids := make([]int, 0, len(rows))
+
+	for _, row := range rows {
+		hit := &Hit{
+			Innovation: row.Innovation,
+			Sort:       []interface{}{row.Rank, row.ID},
+		}
+
+		if strings.Contains(row.TitleHighlight, highlightStart) || strings.Contains(row.DescriptionsHighlight, highlightStart) {
+			hit.Highlights = &HitHighlights{}
+
+			if strings.Contains(row.TitleHighlight, highlightStart) {
+				hit.Highlights.Title = []string{row.TitleHighlight}
+			}
+
+			if strings.Contains(row.DescriptionsHighlight, highlightStart) {
+				hit.Highlights.Description = []string{row.DescriptionsHighlight}
+			}
+		}
+
+		results.Total = row.Total
+		results.Hits = append(results.Hits, hit)
+		hits[row.ID] = hit
+		ids = append(ids, row.ID)
+	}
+
+	if !withAttachments || len(ids) == 0 {
+		return results, nil
+	}
+
+	err = p.matchAttachments(conn, query, ids, hits)
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// matchAttachments adds attached documents matched the query to hits
+func (p *postgresSearcher) matchAttachments(conn *sqlx.DB, query string, ids []int, hits map[int]*Hit) error {
+	sqlQuery, args, err := sqlx.In(pgSearchAttachments, query, ids)
+	if err != nil {
+		return err
+	}
+
+	rows, err := conn.Queryx(conn.Rebind(sqlQuery), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item struct {
+			ID        int    `db:"id"`
+			FileID    string `db:"file_id"`
+			FileName  string `db:"file_name"`
+			Highlight string `db:"highlight"`
+		}
+
+		err = rows.StructScan(&item)
+		if err != nil {
+			return err
+		}
+
+		hit, ok := hits[item.ID]
+		if !ok {
+			continue
+		}
+
+		attachment := &AttachmentHit{FileID: item.FileID, FileName: item.FileName}
+		for _, fragment := range strings.Split(item.Highlight, fragmentDelimiter) {
+			if strings.Contains(fragment, highlightStart) {
+				attachment.Highlights = append(attachment.Highlights, fragment)
+			}
+		}
+
+		hit.Attachments = append(hit.Attachments, attachment)
+	}
+
+	return rows.Err()
+}
+
+// SearchSimilar ranks proposals by words shared with title, problem and
+// descriptions of request
+func (p *postgresSearcher) SearchSimilar(request *models.Innovation, limit int) ([]*SimilarHit, error) {
+	results := []*SimilarHit{}
+
+	conn := *p.db
+	if conn == nil {
+		return results, db.ErrDBConnNotEstablished
+	}
+
+	if limit <= 0 {
+		limit = similarLimit
+	}
+
+	text := strings.Join([]string{request.Title, request.Problem, request.Description}, " ")
+	if strings.TrimSpace(text) == "" {
+		return results, nil
+	}
+
+	rows, err := conn.Queryx(pgSearchSimilar, text, request.ID, limit)
+	if err != nil {
+		return results, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item pgHit
+
+		err = rows.StructScan(&item)
+		if err != nil {
+			return results, err
+		}
+
+		results = append(results, &SimilarHit{Innovation: item.Innovation, Score: float64(item.Rank)})
+	}
+
+	return results, rows.Err()
+}
+
+// IndexInnovation does nothing, innovation is searched in production.innovation
+func (p *postgresSearcher) IndexInnovation(data *models.Innovation) error {
+	return nil
+}
+
+// IndexAttachment does nothing, attachment is searched in production.innovation_files
+func (p *postgresSearcher) IndexAttachment(file *models.InnovationFiles) error {
+	return nil
+}
@@ -0,0 +1,132 @@
+package innovationv1_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"github.com/rs/zerolog"
+	innovationv1 "github.com/sqsinformatique/rosseti-innovation-back/domains/innovation/v1"
+	"github.com/sqsinformatique/rosseti-innovation-back/domains/innovation/v1/searchtest"
+	appcontext "github.com/sqsinformatique/rosseti-innovation-back/internal/context"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/orm"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+)
+
+// Search backends are checked against real services only. Postgres
+// database must be migrated and have no innovations, elastic index is
+// recreated by test.
+const (
+	postgresDSNEnv = "SEARCHTEST_POSTGRES_DSN"
+	elasticDSNEnv  = "SEARCHTEST_ELASTIC_DSN"
+)
+
+// ormStore saves fixtures to postgres the way InnovationV1 does
+type ormStore struct {
+	orm *orm.ORM
+}
+
+func (s *ormStore) CreateInnovation(request *models.Innovation) (*models.Innovation, error) {
+	request.CreateTimestamp()
+
+	result, err := s.orm.InsertInto("innovation", request)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(*models.Innovation), nil
+}
+
+func (s *ormStore) SaveInnovationFile(request *models.InnovationFiles) error {
+	request.CreateTimestamp()
+
+	_, err := s.orm.InsertInto("innovation_files", request)
+
+	return err
+}
+
+// memoryStore gives fixtures ids only, elastic backend doesn't read
+// postgres
+type memoryStore struct {
+	lastID int
+}
+
+func (s *memoryStore) CreateInnovation(request *models.Innovation) (*models.Innovation, error) {
+	s.lastID++
+	request.ID = s.lastID
+	request.CreateTimestamp()
+
+	return request, nil
+}
+
+func (s *memoryStore) SaveInnovationFile(request *models.InnovationFiles) error {
+	request.CreateTimestamp()
+
+	return nil
+}
+
+func TestPostgresSearcher(t *testing.T) {
+	dsn := os.Getenv(postgresDSNEnv)
+	if dsn == "" {
+		t.Skip(postgresDSNEnv + " is not set")
+	}
+
+	conn, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatalf("connect postgres: %v", err)
+	}
+	defer conn.Close()
+
+	ctx := appcontext.NewContext()
+	ctx.RegisterDatabase(&conn)
+
+	o, err := orm.NewORM("production", ctx)
+	if err != nil {
+		t.Fatalf("create orm: %v", err)
+	}
+
+	err = searchtest.Run(innovationv1.NewPostgresSearcher(zerolog.Nop(), &conn), &ormStore{orm: o})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestElasticSearcher(t *testing.T) {
+	dsn := os.Getenv(elasticDSNEnv)
+	if dsn == "" {
+		t.Skip(elasticDSNEnv + " is not set")
+	}
+
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{dsn}})
+	if err != nil {
+		t.Fatalf("create elastic client: %v", err)
+	}
+
+	res, err := client.Indices.Delete([]string{innovationv1.InnovationIndex},
+		client.Indices.Delete.WithIgnoreUnavailable(true),
+		client.Indices.Delete.WithContext(context.Background()))
+	if err != nil {
+		t.Fatalf("delete index: %v", err)
+	}
+	res.Body.Close()
+
+	res, err = client.Indices.Create(innovationv1.InnovationIndex,
+		client.Indices.Create.WithBody(strings.NewReader(innovationv1.InnovationMapping)))
+	if err != nil {
+		t.Fatalf("create index: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		t.Fatalf("create index: %s", res.String())
+	}
+
+	err = searchtest.Run(innovationv1.NewElasticSearcher(zerolog.Nop(), &client), &memoryStore{})
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,307 @@
+// Package searchtest contains behavioural suite every innovation search
+// backend must satisfy. Suite puts fixtures to the store and backend, so
+// it must be run against empty database and search index.
+package searchtest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	innovationv1 "github.com/sqsinformatique/rosseti-innovation-back/domains/innovation/v1"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+	"github.com/sqsinformatique/rosseti-innovation-back/types"
+)
+
+// Store persists fixtures the way InnovationV1 does before indexing,
+// InnovationV1 itself satisfies it
+type Store interface {
+	CreateInnovation(request *models.Innovation) (*models.Innovation, error)
+	SaveInnovationFile(request *models.InnovationFiles) error
+}
+
+type fixtures struct {
+	thermal *models.Innovation
+	meter   *models.Innovation
+	relay   *models.Innovation
+	file    *models.InnovationFiles
+}
+
+func load(s innovationv1.Searcher, store Store) (*fixtures, error) {
+	f := &fixtures{}
+
+	innovations := []struct {
+		dst  **models.Innovation
+		data *models.Innovation
+	}{
+		{&f.thermal, &models.Innovation{
+			Title:       "Диагностика трансформаторов тепловизором",
+			Problem:     "Перегрев обмоток выявляется слишком поздно",
+			Description: "Ежемесячный тепловизионный контроль трансформаторных подстанций",
+		}},
+		{&f.meter, &models.Innovation{
+			Title:       "Умный учет электроэнергии",
+			Problem:     "Потери при ручном снятии показаний",
+			Description: "Установка интеллектуальных счетчиков у потребителей",
+		}},
+		{&f.relay, &models.Innovation{
+			Title:       "Обучение персонала",
+			Problem:     "Ошибки при оперативных переключениях",
+			Description: "Тренажер для отработки переключений",
+		}},
+	}
+
+	for _, item := range innovations {
+		item.data.AuthorID = 1
+		item.data.State = types.Draft
+
+		created, err := store.CreateInnovation(item.data)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = s.IndexInnovation(created); err != nil {
+			return nil, err
+		}
+
+		*item.dst = created
+	}
+
+	f.file = &models.InnovationFiles{
+		ID:       f.relay.ID,
+		FileID:   "searchtest",
+		FileName: "регламент.txt",
+		Content:  "Регламент проверки устройств релейной защиты и автоматики",
+	}
+
+	if err := store.SaveInnovationFile(f.file); err != nil {
+		return nil, err
+	}
+
+	if err := s.IndexAttachment(f.file); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func findHit(results *innovationv1.SearchResults, id int) *innovationv1.Hit {
+	for _, hit := range results.Hits {
+		if hit.ID == id {
+			return hit
+		}
+	}
+
+	return nil
+}
+
+func checkMorphology(s innovationv1.Searcher, f *fixtures) error {
+	results, err := s.Search("трансформатор")
+	if err != nil {
+		return err
+	}
+
+	hit := findHit(results, f.thermal.ID)
+	if hit == nil {
+		return errors.New("word form differing from indexed one is not found")
+	}
+
+	if hit.Title != f.thermal.Title {
+		return fmt.Errorf("hit title %q, expected %q", hit.Title, f.thermal.Title)
+	}
+
+	if findHit(results, f.meter.ID) != nil {
+		return errors.New("innovation without query words is found")
+	}
+
+	return nil
+}
+
+func checkFields(s innovationv1.Searcher, f *fixtures) error {
+	results, err := s.Search("счетчик")
+	if err != nil {
+		return err
+	}
+
+	if findHit(results, f.meter.ID) == nil {
+		return errors.New("innovation is not found by descriptions")
+	}
+
+	results, err = s.Search("перегрев")
+	if err != nil {
+		return err
+	}
+
+	if findHit(results, f.thermal.ID) == nil {
+		return errors.New("innovation is not found by problem")
+	}
+
+	return nil
+}
+
+func checkTitle(s innovationv1.Searcher, f *fixtures) error {
+	results, err := s.SearchTitle("учет")
+	if err != nil {
+		return err
+	}
+
+	if findHit(results, f.meter.ID) == nil {
+		return errors.New("innovation is not found by title")
+	}
+
+	results, err = s.SearchTitle("счетчик")
+	if err != nil {
+		return err
+	}
+
+	if findHit(results, f.meter.ID) != nil {
+		return errors.New("title search matches descriptions")
+	}
+
+	return nil
+}
+
+func checkAttachments(s innovationv1.Searcher, f *fixtures) error {
+	results, err := s.Search("релейная защита")
+	if err != nil {
+		return err
+	}
+
+	hit := findHit(results, f.relay.ID)
+	if hit == nil {
+		return errors.New("innovation is not found by attachment")
+	}
+
+	if len(hit.Attachments) != 1 || hit.Attachments[0].FileName != f.file.FileName {
+		return fmt.Errorf("matched attachments %+v, expected %s", hit.Attachments, f.file.FileName)
+	}
+
+	highlighted := false
+	for _, highlight := range hit.Attachments[0].Highlights {
+		highlighted = highlighted || strings.Contains(highlight, "<em>")
+	}
+
+	if !highlighted {
+		return errors.New("attachment match is not highlighted")
+	}
+
+	return nil
+}
+
+func checkEmpty(s innovationv1.Searcher, f *fixtures) error {
+	results, err := s.Search("")
+	if err != nil {
+		return err
+	}
+
+	if results.Total != 3 || len(results.Hits) != 3 {
+		return fmt.Errorf("empty query returned %d of %d hits, expected all 3", len(results.Hits), results.Total)
+	}
+
+	results, err = s.Search("гидроэлектростанция")
+	if err != nil {
+		return err
+	}
+
+	if results.Total != 0 || len(results.Hits) != 0 {
+		return fmt.Errorf("unknown word returned %d hits", len(results.Hits))
+	}
+
+	return nil
+}
+
+func checkPagination(s innovationv1.Searcher, f *fixtures) error {
+	first, err := s.Search("")
+	if err != nil {
+		return err
+	}
+
+	if len(first.Hits) == 0 {
+		return errors.New("no hits to paginate")
+	}
+
+	sort := make([]string, 0, len(first.Hits[0].Sort))
+	for _, value := range first.Hits[0].Sort {
+		sort = append(sort, fmt.Sprint(value))
+	}
+
+	next, err := s.Search("", strings.Join(sort, ","))
+	if err != nil {
+		return err
+	}
+
+	if next.Total != first.Total {
+		return fmt.Errorf("total changed from %d to %d on the next page", first.Total, next.Total)
+	}
+
+	if len(next.Hits) != len(first.Hits)-1 || findHit(next, first.Hits[0].ID) != nil {
+		return errors.New("next page doesn't continue after the given hit")
+	}
+
+	return nil
+}
+
+func checkSimilar(s innovationv1.Searcher, f *fixtures) error {
+	request := &models.Innovation{
+		Title:       "Тепловизионная диагностика подстанций",
+		Description: "Контроль перегрева трансформаторов",
+	}
+
+	similar, err := s.SearchSimilar(request, 5)
+	if err != nil {
+		return err
+	}
+
+	if len(similar) == 0 || similar[0].ID != f.thermal.ID {
+		return errors.New("the closest proposal is not the first similar one")
+	}
+
+	similar, err = s.SearchSimilar(f.thermal, 5)
+	if err != nil {
+		return err
+	}
+
+	for _, hit := range similar {
+		if hit.ID == f.thermal.ID {
+			return errors.New("innovation is similar to itself")
+		}
+	}
+
+	return nil
+}
+
+// Run loads fixtures and checks search backend behaviour, all failed
+// checks are returned in one error
+func Run(s innovationv1.Searcher, store Store) error {
+	f, err := load(s, store)
+	if err != nil {
+		return fmt.Errorf("load fixtures: %w", err)
+	}
+
+	checks := []struct {
+		name  string
+		check func(innovationv1.Searcher, *fixtures) error
+	}{
+		{"morphology", checkMorphology},
+		{"fields", checkFields},
+		{"title", checkTitle},
+		{"attachments", checkAttachments},
+		{"empty", checkEmpty},
+		{"pagination", checkPagination},
+		{"similar", checkSimilar},
+	}
+
+	failed := []string{}
+
+	for _, item := range checks {
+		if err := item.check(s, f); err != nil {
+			failed = append(failed, item.name+": "+err.Error())
+		}
+	}
+
+	if len(failed) > 0 {
+		return errors.New(strings.Join(failed, "; "))
+	}
+
+	return nil
+}
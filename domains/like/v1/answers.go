@@ -0,0 +1,5 @@
+package likev1
+
+import "github.com/sqsinformatique/rosseti-innovation-back/internal/httpsrv"
+
+type LikeDataResult httpsrv.ResultAnsw
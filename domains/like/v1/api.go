@@ -0,0 +1,109 @@
+package likev1
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	echoSwagger "github.com/sqsinformatique/rosseti-innovation-back/internal/echo-swagger"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/httpsrv"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/logger"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+)
+
+func addLikeSwagger(ec echo.Context, summary string) {
+	echoSwagger.AddToSwagger(ec).
+		SetProduces("application/json").
+		SetDescription(summary).
+		SetSummary(summary).
+		AddInPathParameter("id", "Target id", reflect.Int64).
+		AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+		AddResponse(http.StatusOK, "OK", &LikeDataResult{Body: &models.LikeState{}})
+}
+
+func (l *LikeV1) likeHandler(ec echo.Context, targetType string, liked bool) (err error) {
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&l.log, ec)
+
+	targetID, err := strconv.Atoi(ec.Param("id"))
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, id %s", ec.Param("id"))
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	user, err := l.userV1.CurrentUser(ec)
+	if err != nil {
+		hndlLog.Err(err).Msg("GET CURRENT USER FAILED")
+
+		return ec.JSON(
+			http.StatusUnauthorized,
+			httpsrv.Unauthorized(err),
+		)
+	}
+
+	state, err := l.SetLike(user.ID, targetType, targetID, liked)
+	if errors.Is(err, ErrTargetNotFound) {
+		hndlLog.Err(err).Msgf("%s %d NOT FOUND", targetType, targetID)
+
+		return ec.JSON(
+			http.StatusNotFound,
+			httpsrv.NotFound(err),
+		)
+	}
+
+	if err != nil {
+		hndlLog.Err(err).Msgf("SET LIKE FAILED, %s %d", targetType, targetID)
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		LikeDataResult{Body: state},
+	)
+}
+
+func (l *LikeV1) themeLikePutHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		addLikeSwagger(ec, "Like theme")
+		return nil
+	}
+
+	return l.likeHandler(ec, models.LikeTargetTheme, true)
+}
+
+func (l *LikeV1) themeLikeDeleteHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		addLikeSwagger(ec, "Unlike theme")
+		return nil
+	}
+
+	return l.likeHandler(ec, models.LikeTargetTheme, false)
+}
+
+func (l *LikeV1) innovationLikePutHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		addLikeSwagger(ec, "Like innovation")
+		return nil
+	}
+
+	return l.likeHandler(ec, models.LikeTargetInnovation, true)
+}
+
+func (l *LikeV1) innovationLikeDeleteHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		addLikeSwagger(ec, "Unlike innovation")
+		return nil
+	}
+
+	return l.likeHandler(ec, models.LikeTargetInnovation, false)
+}
@@ -0,0 +1,88 @@
+package likev1
+
+import (
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+	userv1 "github.com/sqsinformatique/rosseti-innovation-back/domains/user/v1"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/context"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/httpsrv"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+	"github.com/sqsinformatique/rosseti-innovation-back/types"
+)
+
+type empty struct{}
+
+type LikeV1 struct {
+	log      zerolog.Logger
+	db       **sqlx.DB
+	publicV1 *echo.Group
+	userV1   *userv1.UserV1
+}
+
+func NewLikeV1(ctx *context.Context, userV1 *userv1.UserV1) (*LikeV1, error) {
+	if ctx == nil || userV1 == nil {
+		return nil, errors.New("empty context or userV1 client")
+	}
+
+	l := &LikeV1{}
+	l.log = ctx.GetPackageLogger(empty{})
+	l.publicV1 = ctx.GetHTTPGroup(httpsrv.PublicSrv, httpsrv.V1)
+	l.db = ctx.GetDatabase()
+	l.userV1 = userV1
+
+	l.publicV1.PUT("/themes/:id/like", l.userV1.Introspect(l.themeLikePutHandler, types.User))
+	l.publicV1.DELETE("/themes/:id/like", l.userV1.Introspect(l.themeLikeDeleteHandler, types.User))
+	l.publicV1.PUT("/innovations/:id/like", l.userV1.Introspect(l.innovationLikePutHandler, types.User))
+	l.publicV1.DELETE("/innovations/:id/like", l.userV1.Introspect(l.innovationLikeDeleteHandler, types.User))
+
+	return l, nil
+}
+
+// MarkThemes refreshes like counters of themes and sets whether user likes them
+func (l *LikeV1) MarkThemes(userID int, themes []*models.Theme) error {
+	ids := make([]int, 0, len(themes))
+	for _, theme := range themes {
+		ids = append(ids, theme.ID)
+	}
+
+	states, err := l.GetLikeStates(userID, models.LikeTargetTheme, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, theme := range themes {
+		if state, ok := states[theme.ID]; ok {
+			theme.LikeCounter = state.LikeCounter
+			theme.LikedByMe = state.Liked
+		}
+	}
+
+	return nil
+}
+
+// MarkInnovations refreshes like counters of innovations and sets whether
+// user likes them. Counters of innovations found in search index may be
+// outdated, so they are taken from database too.
+func (l *LikeV1) MarkInnovations(userID int, innovations []*models.Innovation) error {
+	ids := make([]int, 0, len(innovations))
+	for _, innovation := range innovations {
+		ids = append(ids, innovation.ID)
+	}
+
+	states, err := l.GetLikeStates(userID, models.LikeTargetInnovation, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, innovation := range innovations {
+		if state, ok := states[innovation.ID]; ok {
+			innovation.LikeCounter = state.LikeCounter
+			innovation.LikedByMe = state.Liked
+		}
+	}
+
+	return nil
+}
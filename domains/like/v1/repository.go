@@ -0,0 +1,126 @@
+package likev1
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/db"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+)
+
+var (
+	ErrUnknownTarget  = errors.New("unknown like target")
+	ErrTargetNotFound = errors.New("like target not found")
+)
+
+// targetTables are tables of like targets
+var targetTables = map[string]string{
+	models.LikeTargetTheme:      "production.theme",
+	models.LikeTargetInnovation: "production.innovation",
+}
+
+// SetLike likes or unlikes target by user. Target row is locked while like
+// is changed, so counter always equals to count of target likes.
+func (l *LikeV1) SetLike(userID int, targetType string, targetID int, liked bool) (*models.LikeState, error) {
+	table, ok := targetTables[targetType]
+	if !ok {
+		return nil, ErrUnknownTarget
+	}
+
+	conn := *l.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	tx, err := conn.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	state := &models.LikeState{TargetType: targetType, TargetID: targetID, Liked: liked}
+
+	err = tx.Get(&state.LikeCounter, "select like_counter from "+table+" where id=$1 and deleted_at is null for update", targetID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTargetNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	query, delta := `
+		insert into production.likes (user_id, target_type, target_id) values ($1, $2, $3)
+		on conflict do nothing`, 1
+	if !liked {
+		query, delta = "delete from production.likes where user_id=$1 and target_type=$2 and target_id=$3", -1
+	}
+
+	result, err := tx.Exec(query, userID, targetType, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	changed, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if changed > 0 {
+		err = tx.Get(&state.LikeCounter, "update "+table+" set like_counter = like_counter + $2 where id=$1 returning like_counter", targetID, delta)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return state, tx.Commit()
+}
+
+// GetLikeStates returns like counters of targets and whether user likes
+// them, deleted targets are not returned
+func (l *LikeV1) GetLikeStates(userID int, targetType string, ids []int) (map[int]*models.LikeState, error) {
+	states := make(map[int]*models.LikeState, len(ids))
+
+	table, ok := targetTables[targetType]
+	if !ok {
+		return nil, ErrUnknownTarget
+	}
+
+	if len(ids) == 0 {
+		return states, nil
+	}
+
+	conn := *l.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	query, args, err := sqlx.In(`
+		select t.id, t.like_counter,
+			exists(select 1 from production.likes l where l.user_id = ? and l.target_type = ? and l.target_id = t.id) as liked
+		from `+table+` t
+		where t.id in (?) and t.deleted_at is null`, userID, targetType, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.Queryx(conn.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		state := &models.LikeState{TargetType: targetType}
+
+		err = rows.StructScan(state)
+		if err != nil {
+			return nil, err
+		}
+
+		states[state.TargetID] = state
+	}
+
+	return states, rows.Err()
+}
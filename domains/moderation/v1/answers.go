@@ -0,0 +1,22 @@
+package moderationv1
+
+import (
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/httpsrv"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+)
+
+type SanctionDataResult httpsrv.ResultAnsw
+
+type SanctionsDataResult httpsrv.ResultAnsw
+
+type ArrayOfSanctionData []*models.Sanction
+
+type ThemeLockDataResult httpsrv.ResultAnsw
+
+type StopWordDataResult httpsrv.ResultAnsw
+
+type StopWordsDataResult httpsrv.ResultAnsw
+
+type ArrayOfStopWordData []*models.StopWord
+
+type ModerationLogDataResult httpsrv.ResultAnsw
@@ -0,0 +1,511 @@
+package moderationv1
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	echoSwagger "github.com/sqsinformatique/rosseti-innovation-back/internal/echo-swagger"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/httpsrv"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/logger"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+)
+
+func moderationErrorAnswer(err error) (int, httpsrv.ErrorAnsw) {
+	switch {
+	case errors.Is(err, ErrUnknownSanction), errors.Is(err, ErrBadSanctionDuration),
+		errors.Is(err, ErrUnknownStopWordAction), errors.Is(err, ErrBadStopWord):
+		return http.StatusBadRequest, httpsrv.BadRequest(err)
+	case errors.Is(err, ErrThemeNotFound), errors.Is(err, ErrThemeNotLocked),
+		errors.Is(err, ErrSanctionNotFound), errors.Is(err, ErrStopWordNotFound):
+		return http.StatusNotFound, httpsrv.NotFound(err)
+	case errors.Is(err, ErrStopWordExists):
+		return http.StatusConflict, httpsrv.CreateFailed(err)
+	}
+
+	return http.StatusInternalServerError, httpsrv.InternalServerError(err)
+}
+
+// idRequest returns id of request path and current user
+func (m *ModerationV1) idRequest(ec echo.Context) (id int, user *models.User, err error) {
+	id, err = strconv.Atoi(ec.Param("id"))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	user, err = m.userV1.CurrentUser(ec)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return id, user, nil
+}
+
+func (m *ModerationV1) sanctionsGetHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("sanctionsGetHandler").
+			SetSummary("Get active sanctions of theme chat").
+			AddInPathParameter("id", "Theme id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &SanctionsDataResult{Body: &ArrayOfSanctionData{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&m.log, ec)
+
+	themeID, err := strconv.Atoi(ec.Param("id"))
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, id %s", ec.Param("id"))
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	sanctions, err := m.GetSanctions(themeID)
+	if err != nil {
+		hndlLog.Err(err).Msgf("GET SANCTIONS FAILED, theme %d", themeID)
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		SanctionsDataResult{Body: sanctions},
+	)
+}
+
+func (m *ModerationV1) sanctionPostHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("sanctionPostHandler").
+			SetSummary("Mute or ban user in theme chat").
+			AddInBodyParameter("sanction", "Sanction, duration is in minutes", &models.SanctionRequest{}, true).
+			AddInPathParameter("id", "Theme id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &SanctionDataResult{Body: &models.Sanction{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&m.log, ec)
+
+	themeID, user, err := m.idRequest(ec)
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, id %s", ec.Param("id"))
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	var request models.SanctionRequest
+
+	err = ec.Bind(&request)
+	if err != nil {
+		hndlLog.Err(err).Msg("BAD REQUEST")
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	sanction, err := m.CreateSanction(themeID, user.ID, &request)
+	if err != nil {
+		hndlLog.Err(err).Msgf("CREATE SANCTION FAILED, theme %d user %d", themeID, request.UserID)
+
+		return ec.JSON(moderationErrorAnswer(err))
+	}
+
+	m.Log(user.ID, models.ModerationSanction, themeID, sanction.UserID, 0, map[string]interface{}{
+		"sanction_id": sanction.ID,
+		"kind":        sanction.Kind,
+		"until":       sanction.Until,
+		"reason":      sanction.Reason,
+	})
+
+	if sanction.Kind == models.SanctionBan {
+		m.dropBanned(themeID, sanction.UserID)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		SanctionDataResult{Body: sanction},
+	)
+}
+
+func (m *ModerationV1) sanctionDeleteHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("sanctionDeleteHandler").
+			SetSummary("Revoke sanction in theme chat").
+			AddInPathParameter("id", "Theme id", reflect.Int64).
+			AddInPathParameter("sanction", "Sanction id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &SanctionDataResult{Body: &models.Sanction{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&m.log, ec)
+
+	themeID, user, err := m.idRequest(ec)
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, id %s", ec.Param("id"))
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	id, err := strconv.Atoi(ec.Param("sanction"))
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, sanction %s", ec.Param("sanction"))
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	sanction, err := m.RevokeSanction(themeID, id)
+	if err != nil {
+		hndlLog.Err(err).Msgf("REVOKE SANCTION FAILED, theme %d sanction %d", themeID, id)
+
+		return ec.JSON(moderationErrorAnswer(err))
+	}
+
+	m.Log(user.ID, models.ModerationRevokeSanction, themeID, sanction.UserID, 0, map[string]interface{}{
+		"sanction_id": sanction.ID,
+		"kind":        sanction.Kind,
+	})
+
+	return ec.JSON(
+		http.StatusOK,
+		SanctionDataResult{Body: sanction},
+	)
+}
+
+func (m *ModerationV1) themeLockGetHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("themeLockGetHandler").
+			SetSummary("Get lock of theme chat").
+			AddInPathParameter("id", "Theme id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &ThemeLockDataResult{Body: &models.ThemeLock{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&m.log, ec)
+
+	themeID, err := strconv.Atoi(ec.Param("id"))
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, id %s", ec.Param("id"))
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	lock, err := m.GetThemeLock(themeID)
+	if err != nil {
+		hndlLog.Err(err).Msgf("GET THEME LOCK FAILED, theme %d", themeID)
+
+		return ec.JSON(moderationErrorAnswer(err))
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		ThemeLockDataResult{Body: lock},
+	)
+}
+
+func (m *ModerationV1) themeLockPutHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("themeLockPutHandler").
+			SetSummary("Make theme chat read-only").
+			AddInBodyParameter("lock", "Lock reason", &models.ThemeLockRequest{}, false).
+			AddInPathParameter("id", "Theme id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &ThemeLockDataResult{Body: &models.ThemeLock{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&m.log, ec)
+
+	themeID, user, err := m.idRequest(ec)
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, id %s", ec.Param("id"))
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	var request models.ThemeLockRequest
+
+	err = ec.Bind(&request)
+	if err != nil {
+		hndlLog.Err(err).Msg("BAD REQUEST")
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	lock, err := m.LockTheme(themeID, user.ID, request.Reason)
+	if err != nil {
+		hndlLog.Err(err).Msgf("LOCK THEME FAILED, theme %d", themeID)
+
+		return ec.JSON(moderationErrorAnswer(err))
+	}
+
+	m.Log(user.ID, models.ModerationLockTheme, themeID, 0, 0, map[string]interface{}{"reason": lock.Reason})
+
+	return ec.JSON(
+		http.StatusOK,
+		ThemeLockDataResult{Body: lock},
+	)
+}
+
+func (m *ModerationV1) themeLockDeleteHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("themeLockDeleteHandler").
+			SetSummary("Unlock theme chat").
+			AddInPathParameter("id", "Theme id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &ThemeLockDataResult{Body: &models.ThemeLock{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&m.log, ec)
+
+	themeID, user, err := m.idRequest(ec)
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, id %s", ec.Param("id"))
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	lock, err := m.UnlockTheme(themeID)
+	if err != nil {
+		hndlLog.Err(err).Msgf("UNLOCK THEME FAILED, theme %d", themeID)
+
+		return ec.JSON(moderationErrorAnswer(err))
+	}
+
+	m.Log(user.ID, models.ModerationUnlockTheme, themeID, 0, 0, nil)
+
+	return ec.JSON(
+		http.StatusOK,
+		ThemeLockDataResult{Body: lock},
+	)
+}
+
+func (m *ModerationV1) stopWordsGetHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("stopWordsGetHandler").
+			SetSummary("Get stop words of chat filter").
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &StopWordsDataResult{Body: &ArrayOfStopWordData{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&m.log, ec)
+
+	words, err := m.GetStopWords()
+	if err != nil {
+		hndlLog.Err(err).Msg("GET STOP WORDS FAILED")
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		StopWordsDataResult{Body: words},
+	)
+}
+
+func (m *ModerationV1) stopWordPostHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("stopWordPostHandler").
+			SetSummary("Add stop word to chat filter").
+			AddInBodyParameter("stopword", "Word and action, reject or mask", &models.StopWord{}, true).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &StopWordDataResult{Body: &models.StopWord{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&m.log, ec)
+
+	user, err := m.userV1.CurrentUser(ec)
+	if err != nil {
+		hndlLog.Err(err).Msg("GET CURRENT USER FAILED")
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	var request models.StopWord
+
+	err = ec.Bind(&request)
+	if err != nil {
+		hndlLog.Err(err).Msg("BAD REQUEST")
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	word, err := m.AddStopWord(request.Word, request.Action, user.ID)
+	if err != nil {
+		hndlLog.Err(err).Msgf("ADD STOP WORD FAILED, word %s", request.Word)
+
+		return ec.JSON(moderationErrorAnswer(err))
+	}
+
+	m.Log(user.ID, models.ModerationAddStopWord, 0, 0, 0, map[string]interface{}{
+		"word":   word.Word,
+		"action": word.Action,
+	})
+
+	return ec.JSON(
+		http.StatusOK,
+		StopWordDataResult{Body: word},
+	)
+}
+
+func (m *ModerationV1) stopWordDeleteHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("stopWordDeleteHandler").
+			SetSummary("Remove stop word from chat filter").
+			AddInPathParameter("id", "Stop word id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &StopWordDataResult{Body: &models.StopWord{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&m.log, ec)
+
+	id, user, err := m.idRequest(ec)
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, id %s", ec.Param("id"))
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	word, err := m.RemoveStopWord(id)
+	if err != nil {
+		hndlLog.Err(err).Msgf("REMOVE STOP WORD FAILED, id %d", id)
+
+		return ec.JSON(moderationErrorAnswer(err))
+	}
+
+	m.Log(user.ID, models.ModerationRemoveStopWord, 0, 0, 0, map[string]interface{}{
+		"word":   word.Word,
+		"action": word.Action,
+	})
+
+	return ec.JSON(
+		http.StatusOK,
+		StopWordDataResult{Body: word},
+	)
+}
+
+func (m *ModerationV1) logGetHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("logGetHandler").
+			SetSummary("Get moderation log").
+			AddInQueryParameter("chat", "Return actions in this chat only", reflect.Int64, false).
+			AddInQueryParameter("user", "Return actions on this user only", reflect.Int64, false).
+			AddInQueryParameter("before", "Return entries older than this entry id", reflect.Int64, false).
+			AddInQueryParameter("limit", "Max count of entries", reflect.Int64, false).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &ModerationLogDataResult{Body: &models.ModerationLog{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&m.log, ec)
+
+	params := map[string]int{"chat": 0, "user": 0, "before": 0, "limit": logLimit}
+	for name := range params {
+		if ec.QueryParam(name) == "" {
+			continue
+		}
+
+		params[name], err = strconv.Atoi(ec.QueryParam(name))
+		if err != nil {
+			hndlLog.Err(err).Msgf("BAD REQUEST, %s %s", name, ec.QueryParam(name))
+
+			return ec.JSON(
+				http.StatusBadRequest,
+				httpsrv.BadRequest(err),
+			)
+		}
+	}
+
+	log, err := m.GetLog(params["chat"], params["user"], params["before"], params["limit"])
+	if err != nil {
+		hndlLog.Err(err).Msg("GET MODERATION LOG FAILED")
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		ModerationLogDataResult{Body: log},
+	)
+}
@@ -0,0 +1,137 @@
+package moderationv1
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+	userv1 "github.com/sqsinformatique/rosseti-innovation-back/domains/user/v1"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/centrifugo"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/context"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/httpsrv"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+	"github.com/sqsinformatique/rosseti-innovation-back/types"
+)
+
+// stopWordsTTL limits how long other instances use outdated stop words
+const stopWordsTTL = time.Minute
+
+type empty struct{}
+
+type ModerationV1 struct {
+	log        zerolog.Logger
+	db         **sqlx.DB
+	publicV1   *echo.Group
+	userV1     *userv1.UserV1
+	centrifugo centrifugo.Client
+
+	stopWordsMu     sync.RWMutex
+	stopWords       map[string]string
+	stopWordsLoaded time.Time
+}
+
+func NewModerationV1(ctx *context.Context, userV1 *userv1.UserV1, centrifugoClient centrifugo.Client) (*ModerationV1, error) {
+	if ctx == nil || userV1 == nil || centrifugoClient == nil {
+		return nil, errors.New("empty context or userV1 client or centrifugo client")
+	}
+
+	m := &ModerationV1{}
+	m.log = ctx.GetPackageLogger(empty{})
+	m.publicV1 = ctx.GetHTTPGroup(httpsrv.PublicSrv, httpsrv.V1)
+	m.db = ctx.GetDatabase()
+	m.userV1 = userV1
+	m.centrifugo = centrifugoClient
+
+	m.publicV1.GET("/themes/:id/sanctions", m.userV1.Introspect(m.sanctionsGetHandler, types.Moderator))
+	m.publicV1.POST("/themes/:id/sanctions", m.userV1.Introspect(m.sanctionPostHandler, types.Moderator))
+	m.publicV1.DELETE("/themes/:id/sanctions/:sanction", m.userV1.Introspect(m.sanctionDeleteHandler, types.Moderator))
+	m.publicV1.GET("/themes/:id/lock", m.userV1.Introspect(m.themeLockGetHandler, types.User))
+	m.publicV1.PUT("/themes/:id/lock", m.userV1.Introspect(m.themeLockPutHandler, types.Moderator))
+	m.publicV1.DELETE("/themes/:id/lock", m.userV1.Introspect(m.themeLockDeleteHandler, types.Moderator))
+	m.publicV1.GET("/moderation/stopwords", m.userV1.Introspect(m.stopWordsGetHandler, types.Moderator))
+	m.publicV1.POST("/moderation/stopwords", m.userV1.Introspect(m.stopWordPostHandler, types.Moderator))
+	m.publicV1.DELETE("/moderation/stopwords/:id", m.userV1.Introspect(m.stopWordDeleteHandler, types.Moderator))
+	m.publicV1.GET("/moderation/log", m.userV1.Introspect(m.logGetHandler, types.Moderator))
+
+	return m, nil
+}
+
+// CheckPost returns error if user is not allowed to post to theme chat
+// because theme is locked or user is muted or banned. Moderators are not
+// restricted.
+func (m *ModerationV1) CheckPost(themeID int, user *models.User) error {
+	if user.Role >= types.Moderator {
+		return nil
+	}
+
+	lock, err := m.GetThemeLock(themeID)
+	if err != nil && !errors.Is(err, ErrThemeNotLocked) {
+		return err
+	}
+
+	if lock != nil {
+		return ErrThemeLocked
+	}
+
+	sanction, err := m.activeSanction(themeID, user.ID)
+	if err != nil {
+		return err
+	}
+
+	if sanction == nil {
+		return nil
+	}
+
+	restriction := ErrUserMuted
+	if sanction.Kind == models.SanctionBan {
+		restriction = ErrUserBanned
+	}
+
+	return fmt.Errorf("%w until %s", restriction, sanction.Until.Format(time.RFC3339))
+}
+
+// IsBanned tells whether user is banned in theme chat
+func (m *ModerationV1) IsBanned(themeID, userID int) (bool, error) {
+	sanction, err := m.activeSanction(themeID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return sanction != nil && sanction.Kind == models.SanctionBan, nil
+}
+
+// Log records moderation action, failure is logged only so it never
+// cancels the action itself
+func (m *ModerationV1) Log(moderatorID int, action string, chatID, userID, messageID int, details map[string]interface{}) {
+	entry := &models.ModerationLogEntry{
+		ModeratorID: moderatorID,
+		Action:      action,
+		ChatID:      chatID,
+		UserID:      userID,
+		MessageID:   messageID,
+		Details:     types.NullMeta{Map: details, Valid: details != nil},
+	}
+
+	err := m.AddLogEntry(entry)
+	if err != nil {
+		m.log.Warn().Err(err).Msgf("add moderation log entry failed, moderator %d action %s", moderatorID, action)
+	}
+}
+
+// dropBanned unsubscribes banned user from theme chat, channel name of
+// theme chats created before namespaces is a bare theme id
+func (m *ModerationV1) dropBanned(themeID, userID int) {
+	user := strconv.Itoa(userID)
+
+	for _, channel := range []string{"theme:" + strconv.Itoa(themeID), strconv.Itoa(themeID)} {
+		err := m.centrifugo.Unsubscribe(channel, user)
+		if err != nil {
+			m.log.Warn().Err(err).Msgf("unsubscribe banned user %d from %s failed", userID, channel)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package moderationv1
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+)
+
+// ErrStopWord is returned for message containing rejecting stop word
+var ErrStopWord = errors.New("message contains forbidden word")
+
+// loadStopWords returns cached stop words, the cache is reloaded after
+// stopWordsTTL or change of stop words
+func (m *ModerationV1) loadStopWords() (map[string]string, error) {
+	m.stopWordsMu.RLock()
+	words, loaded := m.stopWords, m.stopWordsLoaded
+	m.stopWordsMu.RUnlock()
+
+	if words != nil && time.Since(loaded) < stopWordsTTL {
+		return words, nil
+	}
+
+	list, err := m.GetStopWords()
+	if err != nil {
+		return nil, err
+	}
+
+	words = make(map[string]string, len(list))
+	for _, word := range list {
+		words[word.Word] = word.Action
+	}
+
+	m.stopWordsMu.Lock()
+	m.stopWords, m.stopWordsLoaded = words, time.Now()
+	m.stopWordsMu.Unlock()
+
+	return words, nil
+}
+
+func (m *ModerationV1) resetStopWords() {
+	m.stopWordsMu.Lock()
+	m.stopWords = nil
+	m.stopWordsMu.Unlock()
+}
+
+// FilterText checks text word by word against stop words. ErrStopWord is
+// returned if text has rejecting word, masking words are replaced with
+// asterisks in returned text.
+func (m *ModerationV1) FilterText(text string) (string, error) {
+	words, err := m.loadStopWords()
+	if err != nil {
+		return "", err
+	}
+
+	if len(words) == 0 {
+		return text, nil
+	}
+
+	var filtered strings.Builder
+
+	rest := text
+	for rest != "" {
+		start := strings.IndexFunc(rest, func(r rune) bool { return !isNotWordRune(r) })
+		if start < 0 {
+			filtered.WriteString(rest)
+			break
+		}
+
+		end := strings.IndexFunc(rest[start:], isNotWordRune)
+		if end < 0 {
+			end = len(rest)
+		} else {
+			end += start
+		}
+
+		word := rest[start:end]
+		filtered.WriteString(rest[:start])
+
+		switch words[strings.ToLower(word)] {
+		case models.StopWordReject:
+			return "", ErrStopWord
+		case models.StopWordMask:
+			filtered.WriteString(strings.Repeat("*", utf8.RuneCountInString(word)))
+		default:
+			filtered.WriteString(word)
+		}
+
+		rest = rest[end:]
+	}
+
+	return filtered.String(), nil
+}
@@ -0,0 +1,350 @@
+package moderationv1
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/db"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+)
+
+const (
+	logLimit    = 50
+	logMaxLimit = 200
+
+	// maxSanctionDuration is a year in minutes
+	maxSanctionDuration = 365 * 24 * 60
+)
+
+var (
+	ErrThemeNotFound         = errors.New("theme not found")
+	ErrThemeLocked           = errors.New("theme is locked")
+	ErrThemeNotLocked        = errors.New("theme is not locked")
+	ErrUserMuted             = errors.New("user is muted in theme")
+	ErrUserBanned            = errors.New("user is banned in theme")
+	ErrUnknownSanction       = errors.New("unknown sanction kind")
+	ErrBadSanctionDuration   = errors.New("sanction duration must be from 1 minute to 1 year")
+	ErrSanctionNotFound      = errors.New("sanction not found")
+	ErrUnknownStopWordAction = errors.New("unknown stop word action")
+	ErrBadStopWord           = errors.New("stop word must be a single word")
+	ErrStopWordExists        = errors.New("stop word already exists")
+	ErrStopWordNotFound      = errors.New("stop word not found")
+)
+
+func (m *ModerationV1) themeExists(id int) (exists bool, err error) {
+	conn := *m.db
+	if conn == nil {
+		return false, db.ErrDBConnNotEstablished
+	}
+
+	err = conn.Get(&exists, "select exists(select 1 from production.theme where id=$1 and deleted_at is null)", id)
+
+	return exists, err
+}
+
+// CreateSanction mutes or bans user in theme for request.Duration minutes
+func (m *ModerationV1) CreateSanction(themeID, moderatorID int, request *models.SanctionRequest) (*models.Sanction, error) {
+	if request.Kind != models.SanctionMute && request.Kind != models.SanctionBan {
+		return nil, ErrUnknownSanction
+	}
+
+	if request.Duration <= 0 || request.Duration > maxSanctionDuration {
+		return nil, ErrBadSanctionDuration
+	}
+
+	exists, err := m.themeExists(themeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, ErrThemeNotFound
+	}
+
+	conn := *m.db
+
+	sanction := &models.Sanction{}
+
+	err = conn.Get(sanction, `
+		insert into production.chat_sanctions (theme_id, user_id, kind, reason, until, created_by)
+		values ($1, $2, $3, $4, $5, $6)
+		returning *`,
+		themeID, request.UserID, request.Kind, request.Reason,
+		time.Now().Add(time.Duration(request.Duration)*time.Minute), moderatorID)
+	if err != nil {
+		return nil, err
+	}
+
+	return sanction, nil
+}
+
+// GetSanctions returns active sanctions of theme
+func (m *ModerationV1) GetSanctions(themeID int) ([]*models.Sanction, error) {
+	conn := *m.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	sanctions := []*models.Sanction{}
+
+	err := conn.Select(&sanctions, `
+		select * from production.chat_sanctions
+		where theme_id = $1 and revoked_at is null and until > now()
+		order by id desc`, themeID)
+	if err != nil {
+		return nil, err
+	}
+
+	return sanctions, nil
+}
+
+// activeSanction returns the strongest active sanction of user in theme or
+// nil if user is not restricted
+func (m *ModerationV1) activeSanction(themeID, userID int) (*models.Sanction, error) {
+	conn := *m.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	sanction := &models.Sanction{}
+
+	err := conn.Get(sanction, `
+		select * from production.chat_sanctions
+		where theme_id = $1 and user_id = $2 and revoked_at is null and until > now()
+		order by kind = $3 desc, until desc
+		limit 1`, themeID, userID, models.SanctionBan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return sanction, nil
+}
+
+// RevokeSanction lifts active sanction of theme
+func (m *ModerationV1) RevokeSanction(themeID, id int) (*models.Sanction, error) {
+	conn := *m.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	sanction := &models.Sanction{}
+
+	err := conn.Get(sanction, `
+		update production.chat_sanctions set revoked_at = now()
+		where id = $1 and theme_id = $2 and revoked_at is null and until > now()
+		returning *`, id, themeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSanctionNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return sanction, nil
+}
+
+func (m *ModerationV1) GetThemeLock(themeID int) (*models.ThemeLock, error) {
+	conn := *m.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	lock := &models.ThemeLock{}
+
+	err := conn.Get(lock, "select * from production.theme_locks where theme_id = $1", themeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrThemeNotLocked
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return lock, nil
+}
+
+// LockTheme makes theme chat read-only, lock of locked theme updates its
+// reason
+func (m *ModerationV1) LockTheme(themeID, moderatorID int, reason string) (*models.ThemeLock, error) {
+	exists, err := m.themeExists(themeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, ErrThemeNotFound
+	}
+
+	conn := *m.db
+
+	lock := &models.ThemeLock{}
+
+	err = conn.Get(lock, `
+		insert into production.theme_locks (theme_id, reason, locked_by)
+		values ($1, $2, $3)
+		on conflict (theme_id) do update
+			set reason = excluded.reason, locked_by = excluded.locked_by, created_at = now()
+		returning *`, themeID, reason, moderatorID)
+	if err != nil {
+		return nil, err
+	}
+
+	return lock, nil
+}
+
+func (m *ModerationV1) UnlockTheme(themeID int) (*models.ThemeLock, error) {
+	conn := *m.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	lock := &models.ThemeLock{}
+
+	err := conn.Get(lock, "delete from production.theme_locks where theme_id = $1 returning *", themeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrThemeNotLocked
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return lock, nil
+}
+
+func (m *ModerationV1) GetStopWords() ([]*models.StopWord, error) {
+	conn := *m.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	words := []*models.StopWord{}
+
+	err := conn.Select(&words, "select * from production.stop_words order by word")
+	if err != nil {
+		return nil, err
+	}
+
+	return words, nil
+}
+
+// AddStopWord stores lowercased word, stop word is a single word because
+// messages are checked word by word
+func (m *ModerationV1) AddStopWord(word, action string, moderatorID int) (*models.StopWord, error) {
+	if action != models.StopWordReject && action != models.StopWordMask {
+		return nil, ErrUnknownStopWordAction
+	}
+
+	word = strings.ToLower(strings.TrimSpace(word))
+	if word == "" || strings.IndexFunc(word, isNotWordRune) >= 0 {
+		return nil, ErrBadStopWord
+	}
+
+	conn := *m.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	stopWord := &models.StopWord{}
+
+	err := conn.Get(stopWord, `
+		insert into production.stop_words (word, action, created_by)
+		values ($1, $2, $3)
+		on conflict (word) do nothing
+		returning *`, word, action, moderatorID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrStopWordExists
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	m.resetStopWords()
+
+	return stopWord, nil
+}
+
+func (m *ModerationV1) RemoveStopWord(id int) (*models.StopWord, error) {
+	conn := *m.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	stopWord := &models.StopWord{}
+
+	err := conn.Get(stopWord, "delete from production.stop_words where id = $1 returning *", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrStopWordNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	m.resetStopWords()
+
+	return stopWord, nil
+}
+
+func (m *ModerationV1) AddLogEntry(entry *models.ModerationLogEntry) error {
+	conn := *m.db
+	if conn == nil {
+		return db.ErrDBConnNotEstablished
+	}
+
+	return conn.Get(entry, `
+		insert into production.moderation_log (moderator_id, action, chat_id, user_id, message_id, details)
+		values ($1, $2, $3, $4, $5, $6)
+		returning *`,
+		entry.ModeratorID, entry.Action, entry.ChatID, entry.UserID, entry.MessageID, entry.Details)
+}
+
+// GetLog returns page of moderation log older than before, the latest
+// entries if before is zero. Zero chatID or userID doesn't filter entries.
+func (m *ModerationV1) GetLog(chatID, userID, before, limit int) (*models.ModerationLog, error) {
+	conn := *m.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	if limit <= 0 || limit > logMaxLimit {
+		limit = logLimit
+	}
+
+	data := &models.ModerationLog{Entries: []*models.ModerationLogEntry{}}
+
+	// One extra row tells whether the next page exists
+	err := conn.Select(&data.Entries, `
+		select * from production.moderation_log
+		where ($1 = 0 or chat_id = $1) and ($2 = 0 or user_id = $2) and ($3 = 0 or id < $3)
+		order by id desc
+		limit $4`,
+		chatID, userID, before, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data.Entries) > limit {
+		data.Entries = data.Entries[:limit]
+		data.HasNext = true
+	}
+
+	if len(data.Entries) > 0 {
+		data.NextCursor = data.Entries[len(data.Entries)-1].ID
+	}
+
+	return data, nil
+}
+
+func isNotWordRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
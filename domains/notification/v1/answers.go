@@ -0,0 +1,21 @@
+package notificationv1
+
+import (
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/httpsrv"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+)
+
+type NotificationDataResult httpsrv.ResultAnsw
+
+type NotificationsDataResult httpsrv.ResultAnsw
+
+type PreferencesDataResult httpsrv.ResultAnsw
+
+type ArrayOfPreferenceData []*models.NotificationPreference
+
+// MarkedRead is a count of notifications marked read
+type MarkedRead struct {
+	Count int64 `json:"count"`
+}
+
+type MarkedReadDataResult httpsrv.ResultAnsw
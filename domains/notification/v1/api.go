@@ -0,0 +1,289 @@
+package notificationv1
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	echoSwagger "github.com/sqsinformatique/rosseti-innovation-back/internal/echo-swagger"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/httpsrv"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/logger"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+)
+
+func (n *NotificationV1) notificationsGetHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("notificationsGetHandler").
+			SetSummary("Get notifications of current user").
+			AddInQueryParameter("unread", "Return unread notifications only", reflect.Bool, false).
+			AddInQueryParameter("before", "Return notifications older than this notification id", reflect.Int64, false).
+			AddInQueryParameter("limit", "Max count of notifications", reflect.Int64, false).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &NotificationsDataResult{Body: &models.Notifications{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&n.log, ec)
+
+	user, err := n.userV1.CurrentUser(ec)
+	if err != nil {
+		hndlLog.Err(err).Msg("GET CURRENT USER FAILED")
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	unreadOnly := false
+	if ec.QueryParam("unread") != "" {
+		unreadOnly, err = strconv.ParseBool(ec.QueryParam("unread"))
+		if err != nil {
+			hndlLog.Err(err).Msgf("BAD REQUEST, unread %s", ec.QueryParam("unread"))
+
+			return ec.JSON(
+				http.StatusBadRequest,
+				httpsrv.BadRequest(err),
+			)
+		}
+	}
+
+	params := map[string]int{"before": 0, "limit": notificationsLimit}
+	for name := range params {
+		if ec.QueryParam(name) == "" {
+			continue
+		}
+
+		params[name], err = strconv.Atoi(ec.QueryParam(name))
+		if err != nil {
+			hndlLog.Err(err).Msgf("BAD REQUEST, %s %s", name, ec.QueryParam(name))
+
+			return ec.JSON(
+				http.StatusBadRequest,
+				httpsrv.BadRequest(err),
+			)
+		}
+	}
+
+	notifications, err := n.GetNotifications(user.ID, unreadOnly, params["before"], params["limit"])
+	if err != nil {
+		hndlLog.Err(err).Msgf("GET NOTIFICATIONS FAILED, user %d", user.ID)
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		NotificationsDataResult{Body: notifications},
+	)
+}
+
+func (n *NotificationV1) notificationReadPostHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("notificationReadPostHandler").
+			SetSummary("Mark notification read").
+			AddInPathParameter("id", "Notification id", reflect.Int64).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &NotificationDataResult{Body: &models.Notification{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&n.log, ec)
+
+	id, err := strconv.Atoi(ec.Param("id"))
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, id %s", ec.Param("id"))
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	user, err := n.userV1.CurrentUser(ec)
+	if err != nil {
+		hndlLog.Err(err).Msg("GET CURRENT USER FAILED")
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	notification, err := n.MarkRead(user.ID, id)
+	if errors.Is(err, ErrNotificationNotFound) {
+		hndlLog.Err(err).Msgf("NOTIFICATION %d NOT FOUND, user %d", id, user.ID)
+
+		return ec.JSON(
+			http.StatusNotFound,
+			httpsrv.NotFound(err),
+		)
+	}
+
+	if err != nil {
+		hndlLog.Err(err).Msgf("MARK NOTIFICATION READ FAILED, id %d", id)
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		NotificationDataResult{Body: notification},
+	)
+}
+
+func (n *NotificationV1) notificationsReadPostHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("notificationsReadPostHandler").
+			SetSummary("Mark all notifications read").
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &MarkedReadDataResult{Body: &MarkedRead{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&n.log, ec)
+
+	user, err := n.userV1.CurrentUser(ec)
+	if err != nil {
+		hndlLog.Err(err).Msg("GET CURRENT USER FAILED")
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	count, err := n.MarkAllRead(user.ID)
+	if err != nil {
+		hndlLog.Err(err).Msgf("MARK ALL NOTIFICATIONS READ FAILED, user %d", user.ID)
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		MarkedReadDataResult{Body: &MarkedRead{Count: count}},
+	)
+}
+
+func (n *NotificationV1) preferencesGetHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("preferencesGetHandler").
+			SetSummary("Get notification preferences of current user").
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &PreferencesDataResult{Body: &ArrayOfPreferenceData{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&n.log, ec)
+
+	user, err := n.userV1.CurrentUser(ec)
+	if err != nil {
+		hndlLog.Err(err).Msg("GET CURRENT USER FAILED")
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	preferences, err := n.GetPreferences(user.ID)
+	if err != nil {
+		hndlLog.Err(err).Msgf("GET NOTIFICATION PREFERENCES FAILED, user %d", user.ID)
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		PreferencesDataResult{Body: preferences},
+	)
+}
+
+func (n *NotificationV1) preferencesPutHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("preferencesPutHandler").
+			SetSummary("Update notification preferences of current user").
+			AddInBodyParameter("preferences", "Changed preferences", &ArrayOfPreferenceData{}, true).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &PreferencesDataResult{Body: &ArrayOfPreferenceData{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&n.log, ec)
+
+	user, err := n.userV1.CurrentUser(ec)
+	if err != nil {
+		hndlLog.Err(err).Msg("GET CURRENT USER FAILED")
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	var request ArrayOfPreferenceData
+
+	err = ec.Bind(&request)
+	if err != nil {
+		hndlLog.Err(err).Msg("BAD REQUEST")
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	preferences, err := n.UpdatePreferences(user.ID, request)
+	if errors.Is(err, ErrUnknownNotificationType) {
+		hndlLog.Err(err).Msg("BAD REQUEST")
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	if err != nil {
+		hndlLog.Err(err).Msgf("UPDATE NOTIFICATION PREFERENCES FAILED, user %d", user.ID)
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		PreferencesDataResult{Body: preferences},
+	)
+}
@@ -0,0 +1,83 @@
+package notificationv1
+
+import (
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+	userv1 "github.com/sqsinformatique/rosseti-innovation-back/domains/user/v1"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/centrifugo"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/context"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/httpsrv"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+	"github.com/sqsinformatique/rosseti-innovation-back/types"
+)
+
+type empty struct{}
+
+type NotificationV1 struct {
+	log        zerolog.Logger
+	db         **sqlx.DB
+	publicV1   *echo.Group
+	userV1     *userv1.UserV1
+	centrifugo centrifugo.Client
+}
+
+func NewNotificationV1(ctx *context.Context, userV1 *userv1.UserV1, centrifugoClient centrifugo.Client) (*NotificationV1, error) {
+	if ctx == nil || userV1 == nil || centrifugoClient == nil {
+		return nil, errors.New("empty context or userV1 client or centrifugo client")
+	}
+
+	n := &NotificationV1{}
+	n.log = ctx.GetPackageLogger(empty{})
+	n.publicV1 = ctx.GetHTTPGroup(httpsrv.PublicSrv, httpsrv.V1)
+	n.db = ctx.GetDatabase()
+	n.userV1 = userV1
+	n.centrifugo = centrifugoClient
+
+	n.publicV1.GET("/notifications", n.userV1.Introspect(n.notificationsGetHandler, types.User))
+	n.publicV1.POST("/notifications/read", n.userV1.Introspect(n.notificationsReadPostHandler, types.User))
+	n.publicV1.GET("/notifications/preferences", n.userV1.Introspect(n.preferencesGetHandler, types.User))
+	n.publicV1.PUT("/notifications/preferences", n.userV1.Introspect(n.preferencesPutHandler, types.User))
+	n.publicV1.POST("/notifications/:id/read", n.userV1.Introspect(n.notificationReadPostHandler, types.User))
+
+	return n, nil
+}
+
+// Notify puts notification to inbox of user and pushes it to user's
+// personal channel. Nothing is stored if user disabled notification type.
+// Notification is a side effect of other operations, so failures are
+// logged and never returned.
+func (n *NotificationV1) Notify(userID int, notificationType, title string, data map[string]interface{}) {
+	enabled, err := n.IsEnabled(userID, notificationType)
+	if err != nil {
+		n.log.Warn().Err(err).Msgf("get notification preference failed, user %d type %s", userID, notificationType)
+		return
+	}
+
+	if !enabled {
+		return
+	}
+
+	notification := &models.Notification{
+		UserID: userID,
+		Type:   notificationType,
+		Title:  title,
+		Data:   types.NullMeta{Map: data, Valid: data != nil},
+	}
+
+	err = n.CreateNotification(notification)
+	if err != nil {
+		n.log.Warn().Err(err).Msgf("create notification failed, user %d type %s", userID, notificationType)
+		return
+	}
+
+	err = n.centrifugo.Publish(
+		centrifugo.PersonalChannel(userID),
+		&models.NotificationPush{Event: models.NotificationEvent, Notification: notification},
+	)
+	if err != nil {
+		n.log.Warn().Err(err).Msgf("push notification failed, user %d id %d", userID, notification.ID)
+	}
+}
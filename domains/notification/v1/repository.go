@@ -0,0 +1,216 @@
+package notificationv1
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/db"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+)
+
+const (
+	notificationsLimit    = 20
+	notificationsMaxLimit = 100
+)
+
+var (
+	ErrNotificationNotFound    = errors.New("notification not found")
+	ErrUnknownNotificationType = errors.New("unknown notification type")
+)
+
+func validType(notificationType string) bool {
+	for _, known := range models.NotificationTypes {
+		if known == notificationType {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (n *NotificationV1) CreateNotification(notification *models.Notification) error {
+	conn := *n.db
+	if conn == nil {
+		return db.ErrDBConnNotEstablished
+	}
+
+	return conn.Get(notification, `
+		insert into production.notifications (user_id, type, title, data)
+		values ($1, $2, $3, $4)
+		returning *`,
+		notification.UserID, notification.Type, notification.Title, notification.Data)
+}
+
+// GetNotifications returns page of user's notifications older than before,
+// the latest ones if before is zero
+func (n *NotificationV1) GetNotifications(userID int, unreadOnly bool, before, limit int) (*models.Notifications, error) {
+	conn := *n.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	if limit <= 0 || limit > notificationsMaxLimit {
+		limit = notificationsLimit
+	}
+
+	var cursor interface{}
+	if before > 0 {
+		cursor = before
+	}
+
+	data := &models.Notifications{Notifications: []*models.Notification{}}
+
+	// One extra row tells whether the next page exists
+	err := conn.Select(&data.Notifications, `
+		select * from production.notifications
+		where user_id = $1 and (not $2 or read_at is null) and ($3::integer is null or id < $3)
+		order by id desc
+		limit $4`,
+		userID, unreadOnly, cursor, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data.Notifications) > limit {
+		data.Notifications = data.Notifications[:limit]
+		data.HasNext = true
+	}
+
+	if len(data.Notifications) > 0 {
+		data.NextCursor = data.Notifications[len(data.Notifications)-1].ID
+	}
+
+	err = conn.Get(&data.Unread, "select count(*) from production.notifications where user_id = $1 and read_at is null", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// MarkRead marks notification of user read
+func (n *NotificationV1) MarkRead(userID, id int) (*models.Notification, error) {
+	conn := *n.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	notification := &models.Notification{}
+
+	err := conn.Get(notification, `
+		update production.notifications set read_at = coalesce(read_at, now())
+		where id = $1 and user_id = $2
+		returning *`,
+		id, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotificationNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return notification, nil
+}
+
+// MarkAllRead marks all notifications of user read and returns their count
+func (n *NotificationV1) MarkAllRead(userID int) (int64, error) {
+	conn := *n.db
+	if conn == nil {
+		return 0, db.ErrDBConnNotEstablished
+	}
+
+	result, err := conn.Exec("update production.notifications set read_at = now() where user_id = $1 and read_at is null", userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// IsEnabled tells whether user receives notifications of type
+func (n *NotificationV1) IsEnabled(userID int, notificationType string) (bool, error) {
+	conn := *n.db
+	if conn == nil {
+		return false, db.ErrDBConnNotEstablished
+	}
+
+	enabled := true
+
+	err := conn.Get(&enabled, "select enabled from production.notification_preferences where user_id = $1 and type = $2", userID, notificationType)
+	if errors.Is(err, sql.ErrNoRows) {
+		return true, nil
+	}
+
+	return enabled, err
+}
+
+// GetPreferences returns preferences of user for all notification types
+func (n *NotificationV1) GetPreferences(userID int) ([]*models.NotificationPreference, error) {
+	conn := *n.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	stored := []*models.NotificationPreference{}
+
+	err := conn.Select(&stored, "select type, enabled from production.notification_preferences where user_id = $1", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := make(map[string]bool, len(stored))
+	for _, preference := range stored {
+		enabled[preference.Type] = preference.Enabled
+	}
+
+	preferences := make([]*models.NotificationPreference, 0, len(models.NotificationTypes))
+	for _, notificationType := range models.NotificationTypes {
+		preference := &models.NotificationPreference{Type: notificationType, Enabled: true}
+		if value, ok := enabled[notificationType]; ok {
+			preference.Enabled = value
+		}
+
+		preferences = append(preferences, preference)
+	}
+
+	return preferences, nil
+}
+
+// UpdatePreferences stores given preferences of user, other types keep
+// their preferences
+func (n *NotificationV1) UpdatePreferences(userID int, preferences []*models.NotificationPreference) ([]*models.NotificationPreference, error) {
+	for _, preference := range preferences {
+		if !validType(preference.Type) {
+			return nil, ErrUnknownNotificationType
+		}
+	}
+
+	conn := *n.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	tx, err := conn.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, preference := range preferences {
+		_, err = tx.Exec(`
+			insert into production.notification_preferences (user_id, type, enabled) values ($1, $2, $3)
+			on conflict (user_id, type) do update set enabled = excluded.enabled, updated_at = now()`,
+			userID, preference.Type, preference.Enabled)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return n.GetPreferences(userID)
+}
@@ -43,6 +43,11 @@ func (o *ProfileV1) ProfilePostHandler(ec echo.Context) (err error) {
 		)
 	}
 
+	err = o.searchV1.IndexProfile(profileData)
+	if err != nil {
+		hndlLog.Warn().Err(err).Msgf("INDEX PROFILE FAILED, id %d", profileData.ID)
+	}
+
 	return ec.JSON(
 		http.StatusOK,
 		ProfileDataResult{Body: profileData},
@@ -173,6 +178,11 @@ func (o *ProfileV1) ProfilePutHandler(ec echo.Context) (err error) {
 		)
 	}
 
+	err = o.searchV1.IndexProfile(profileData)
+	if err != nil {
+		hndlLog.Warn().Err(err).Msgf("INDEX PROFILE FAILED, id %d", profileID)
+	}
+
 	return ec.JSON(
 		http.StatusOK,
 		ProfileDataResult{Body: profileData},
@@ -214,6 +224,11 @@ func (o *ProfileV1) ProfileDeleteHandler(ec echo.Context) (err error) {
 		)
 	}
 
+	err = o.searchV1.RemoveProfile(int(userID))
+	if err != nil {
+		hndlLog.Warn().Err(err).Msgf("REMOVE PROFILE FROM SEARCH FAILED, id %d", userID)
+	}
+
 	return ec.JSON(
 		http.StatusOK,
 		httpsrv.OkResult(),
@@ -7,6 +7,7 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo/v4"
 	"github.com/rs/zerolog"
+	searchv1 "github.com/sqsinformatique/rosseti-innovation-back/domains/search/v1"
 	userv1 "github.com/sqsinformatique/rosseti-innovation-back/domains/user/v1"
 	"github.com/sqsinformatique/rosseti-innovation-back/internal/context"
 	"github.com/sqsinformatique/rosseti-innovation-back/internal/crypto"
@@ -23,17 +24,19 @@ type ProfileV1 struct {
 	orm      *orm.ORM
 	publicV1 *echo.Group
 	userV1   *userv1.UserV1
+	searchV1 *searchv1.SearchV1
 }
 
-func NewProfileV1(ctx *context.Context, orm *orm.ORM, userV1 *userv1.UserV1) (*ProfileV1, error) {
-	if ctx == nil || orm == nil {
-		return nil, errors.New("empty context or orm client")
+func NewProfileV1(ctx *context.Context, orm *orm.ORM, userV1 *userv1.UserV1, searchV1 *searchv1.SearchV1) (*ProfileV1, error) {
+	if ctx == nil || orm == nil || searchV1 == nil {
+		return nil, errors.New("empty context or orm client or searchV1 client")
 	}
 
 	p := &ProfileV1{}
 	p.log = ctx.GetPackageLogger(empty{})
 	p.publicV1 = ctx.GetHTTPGroup(httpsrv.PublicSrv, httpsrv.V1)
 	p.userV1 = userV1
+	p.searchV1 = searchV1
 	p.db = ctx.GetDatabase()
 	p.orm = orm
 
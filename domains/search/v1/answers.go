@@ -0,0 +1,12 @@
+package searchv1
+
+import (
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/httpsrv"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+)
+
+type SuggestDataResult httpsrv.ResultAnsw
+
+type ArrayOfSuggestionData []*models.Suggestion
+
+type GlobalSearchDataResult httpsrv.ResultAnsw
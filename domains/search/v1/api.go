@@ -0,0 +1,182 @@
+package searchv1
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	echoSwagger "github.com/sqsinformatique/rosseti-innovation-back/internal/echo-swagger"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/httpsrv"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/logger"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+)
+
+func (s *SearchV1) suggestGetHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("suggestGetHandler").
+			SetSummary("Search-as-you-type suggestions").
+			AddInQueryParameter("q", "Typed prefix", reflect.String, true).
+			AddInQueryParameter("types", "Comma separated types: innovation, tag, theme, profile", reflect.String, false).
+			AddInQueryParameter("size", "Max count of suggestions", reflect.Int64, false).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &SuggestDataResult{Body: &ArrayOfSuggestionData{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&s.log, ec)
+
+	q := ec.QueryParam("q")
+
+	size := suggestLimit
+	if ec.QueryParam("size") != "" {
+		size, err = strconv.Atoi(ec.QueryParam("size"))
+		if err != nil {
+			hndlLog.Err(err).Msgf("BAD REQUEST, size %s", ec.QueryParam("size"))
+
+			return ec.JSON(
+				http.StatusBadRequest,
+				httpsrv.BadRequest(err),
+			)
+		}
+	}
+
+	var suggestTypes []string
+	if ec.QueryParam("types") != "" {
+		suggestTypes = strings.Split(ec.QueryParam("types"), ",")
+	}
+
+	suggestions, err := s.Suggest(q, suggestTypes, size)
+	if err != nil {
+		hndlLog.Err(err).Msgf("SUGGEST FAILED: query %s", q)
+
+		if errors.Is(err, ErrSearchUnavailable) {
+			return ec.JSON(
+				http.StatusNotImplemented,
+				httpsrv.NotImplemented(err),
+			)
+		}
+
+		return ec.JSON(
+			http.StatusConflict,
+			httpsrv.CreateFailed(err),
+		)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		SuggestDataResult{Body: suggestions},
+	)
+}
+
+func (s *SearchV1) searchGetHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("searchGetHandler").
+			SetSummary("Search innovations, themes, chat messages and profiles").
+			AddInQueryParameter("q", "Search query", reflect.String, true).
+			AddInQueryParameter("types", "Comma separated types: innovation, theme, message, profile", reflect.String, false).
+			AddInQueryParameter("size", "Max count of hits per type", reflect.Int64, false).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &GlobalSearchDataResult{Body: &models.GlobalSearch{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&s.log, ec)
+
+	q := ec.QueryParam("q")
+
+	size := searchLimit
+	if ec.QueryParam("size") != "" {
+		size, err = strconv.Atoi(ec.QueryParam("size"))
+		if err != nil {
+			hndlLog.Err(err).Msgf("BAD REQUEST, size %s", ec.QueryParam("size"))
+
+			return ec.JSON(
+				http.StatusBadRequest,
+				httpsrv.BadRequest(err),
+			)
+		}
+	}
+
+	var searchTypes []string
+	if ec.QueryParam("types") != "" {
+		searchTypes = strings.Split(ec.QueryParam("types"), ",")
+	}
+
+	user, err := s.userV1.CurrentUser(ec)
+	if err != nil {
+		hndlLog.Err(err).Msg("GET CURRENT USER FAILED")
+
+		return ec.JSON(
+			http.StatusUnauthorized,
+			httpsrv.Unauthorized(err),
+		)
+	}
+
+	results, err := s.GlobalSearch(user, q, searchTypes, size)
+	if err != nil {
+		hndlLog.Err(err).Msgf("SEARCH FAILED: query %s", q)
+
+		if errors.Is(err, ErrUnknownSearchType) {
+			return ec.JSON(
+				http.StatusBadRequest,
+				httpsrv.BadRequest(err),
+			)
+		}
+
+		if errors.Is(err, ErrSearchUnavailable) {
+			return ec.JSON(
+				http.StatusNotImplemented,
+				httpsrv.NotImplemented(err),
+			)
+		}
+
+		return ec.JSON(
+			http.StatusConflict,
+			httpsrv.CreateFailed(err),
+		)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		GlobalSearchDataResult{Body: results},
+	)
+}
+
+func (s *SearchV1) searchReindexPostHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("searchReindexPostHandler").
+			SetSummary("Rebuild search and suggest indices").
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", httpsrv.OkResult())
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&s.log, ec)
+
+	err = s.Reindex()
+	if err != nil {
+		hndlLog.Err(err).Msg("REINDEX FAILED")
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		httpsrv.OkResult(),
+	)
+}
@@ -0,0 +1,61 @@
+package searchv1
+
+import (
+	"errors"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+	userv1 "github.com/sqsinformatique/rosseti-innovation-back/domains/user/v1"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/cfg"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/context"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/httpsrv"
+	"github.com/sqsinformatique/rosseti-innovation-back/types"
+)
+
+type empty struct{}
+
+type SearchV1 struct {
+	log            zerolog.Logger
+	cfg            *cfg.AppCfg
+	db             **sqlx.DB
+	elasticDB      **elasticsearch.Client
+	publicV1       *echo.Group
+	userV1         *userv1.UserV1
+	suggestTimeout time.Duration
+	reindexers     []func() error
+}
+
+func NewSearchV1(ctx *context.Context, userV1 *userv1.UserV1) (*SearchV1, error) {
+	if ctx == nil || userV1 == nil {
+		return nil, errors.New("empty context or userV1 client")
+	}
+
+	s := &SearchV1{}
+	s.log = ctx.GetPackageLogger(empty{})
+	s.publicV1 = ctx.GetHTTPGroup(httpsrv.PublicSrv, httpsrv.V1)
+	s.cfg = ctx.Config
+	s.db = ctx.GetDatabase()
+	s.elasticDB = ctx.GetElasticDB()
+	s.userV1 = userV1
+
+	suggestTimeout, err := time.ParseDuration(ctx.Config.Elastic.SuggestTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	s.suggestTimeout = suggestTimeout
+
+	ctx.RegisterElasticIndex(suggestIndex, suggestMapping)
+	ctx.RegisterElasticIndex(themeIndex, themeMapping)
+	ctx.RegisterElasticIndex(messageIndex, messageMapping)
+	ctx.RegisterElasticIndex(profileIndex, profileMapping)
+
+	s.publicV1.GET("/search", s.userV1.Introspect(s.searchGetHandler, types.User))
+	s.publicV1.POST("/search/reindex", s.userV1.Introspect(s.searchReindexPostHandler, types.Admin))
+	s.publicV1.GET("/suggest", s.userV1.Introspect(s.suggestGetHandler, types.User))
+
+	return s, nil
+}
@@ -0,0 +1,770 @@
+package searchv1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/db"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/elastic"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+	"github.com/sqsinformatique/rosseti-innovation-back/types"
+)
+
+var (
+	ErrUnknownSearchType = errors.New("unknown search type")
+	// ErrSearchUnavailable is returned by global search and suggestions
+	// when elasticsearch is not started by postgres search backend
+	ErrSearchUnavailable = errors.New("global search and suggestions require elastic search backend")
+)
+
+const (
+	suggestIndex = "suggest"
+	suggestLimit = 10
+)
+
+// Edge n-grams are built on indexing only, so a typed prefix matches
+// the whole word and highlighting marks the word the prefix belongs to.
+const suggestMapping = `{
+	"settings" : {
+		"analysis" : {
+			"filter" : {
+				"suggest_edge_ngram" : { "type" : "edge_ngram", "min_gram" : 1, "max_gram" : 20 }
+			},
+			"analyzer" : {
+				"suggest_index" : { "type" : "custom", "tokenizer" : "standard", "filter" : ["lowercase", "suggest_edge_ngram"] },
+				"suggest_search" : { "type" : "custom", "tokenizer" : "standard", "filter" : ["lowercase"] }
+			}
+		}
+	},
+	"mappings" : {
+		"properties" : {
+			"type" : { "type" : "keyword" },
+			"ref_id" : { "type" : "integer" },
+			"text" : { "type" : "text", "analyzer" : "suggest_index", "search_analyzer" : "suggest_search" },
+			"position" : { "type" : "keyword", "index" : false },
+			"company" : { "type" : "keyword", "index" : false }
+		}
+	}
+}`
+
+// suggestDoc is a document of suggest index
+type suggestDoc struct {
+	Type     string `json:"type"`
+	RefID    int    `json:"ref_id"`
+	Text     string `json:"text"`
+	Position string `json:"position,omitempty"`
+	Company  string `json:"company,omitempty"`
+}
+
+func suggestDocID(suggestType string, key string) string {
+	return suggestType + "-" + key
+}
+
+// SplitTags splits comma or semicolon separated tags
+func SplitTags(tags string) []string {
+	result := []string{}
+
+	for _, tag := range strings.FieldsFunc(tags, func(r rune) bool { return r == ',' || r == ';' }) {
+		tag = strings.TrimSpace(tag)
+		if tag != "" {
+			result = append(result, tag)
+		}
+	}
+
+	return result
+}
+
+// putDocument puts document to index, nothing is indexed if elasticsearch
+// is not started
+func (s *SearchV1) putDocument(index string, id string, doc interface{}) error {
+	if *s.elasticDB == nil {
+		return nil
+	}
+
+	jsonData, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	req := esapi.IndexRequest{
+		Index:      index,
+		DocumentID: id,
+		Body:       bytes.NewReader(jsonData),
+	}
+
+	res, err := req.Do(context.Background(), *s.elasticDB)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("[%s] error indexing document %s/%s", res.Status(), index, id)
+	}
+
+	return nil
+}
+
+func (s *SearchV1) deleteDocument(index string, id string) error {
+	if *s.elasticDB == nil {
+		return nil
+	}
+
+	req := esapi.DeleteRequest{
+		Index:      index,
+		DocumentID: id,
+	}
+
+	res, err := req.Do(context.Background(), *s.elasticDB)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() && res.StatusCode != 404 {
+		return fmt.Errorf("[%s] error deleting document %s/%s", res.Status(), index, id)
+	}
+
+	return nil
+}
+
+func (s *SearchV1) putSuggestion(id string, doc *suggestDoc) error {
+	return s.putDocument(suggestIndex, id, doc)
+}
+
+func (s *SearchV1) deleteSuggestion(id string) error {
+	return s.deleteDocument(suggestIndex, id)
+}
+
+func (s *SearchV1) putTagSuggestions(tags string) error {
+	for _, tag := range SplitTags(tags) {
+		err := s.putSuggestion(
+			suggestDocID(models.SuggestTag, strings.ToLower(tag)),
+			&suggestDoc{Type: models.SuggestTag, Text: tag},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// IndexInnovationSuggestions puts innovation title and tags to suggest index
+func (s *SearchV1) IndexInnovationSuggestions(data *models.Innovation) error {
+	id := suggestDocID(models.SuggestInnovation, strconv.Itoa(data.ID))
+
+	if data.DeletedAt.Valid {
+		return s.deleteSuggestion(id)
+	}
+
+	err := s.putSuggestion(id, &suggestDoc{Type: models.SuggestInnovation, RefID: data.ID, Text: data.Title})
+	if err != nil {
+		return err
+	}
+
+	return s.putTagSuggestions(data.Tags)
+}
+
+// IndexThemeSuggestions puts theme title and tags to suggest index
+func (s *SearchV1) IndexThemeSuggestions(data *models.Theme) error {
+	id := suggestDocID(models.SuggestTheme, strconv.Itoa(data.ID))
+
+	if data.Removed() {
+		return s.deleteSuggestion(id)
+	}
+
+	err := s.putSuggestion(id, &suggestDoc{Type: models.SuggestTheme, RefID: data.ID, Text: data.Title})
+	if err != nil {
+		return err
+	}
+
+	return s.putTagSuggestions(data.Tags)
+}
+
+// IndexProfileSuggestions puts employee full name to suggest index
+func (s *SearchV1) IndexProfileSuggestions(data *models.Profile) error {
+	id := suggestDocID(models.SuggestProfile, strconv.Itoa(data.ID))
+
+	if data.DeletedAt.Valid {
+		return s.deleteSuggestion(id)
+	}
+
+	return s.putSuggestion(id, &suggestDoc{
+		Type:     models.SuggestProfile,
+		RefID:    data.ID,
+		Text:     strings.Join(strings.Fields(data.LastName+" "+data.FirstName+" "+data.MiddleName), " "),
+		Position: data.Position,
+		Company:  data.Company,
+	})
+}
+
+func (s *SearchV1) buildSuggestQuery(query string, suggestTypes []string, size int) ([]byte, error) {
+	boolQuery := map[string]interface{}{
+		"must": map[string]interface{}{
+			"match": map[string]interface{}{
+				"text": map[string]interface{}{
+					"query":    query,
+					"operator": "and",
+				},
+			},
+		},
+	}
+
+	if len(suggestTypes) > 0 {
+		boolQuery["filter"] = map[string]interface{}{
+			"terms": map[string]interface{}{
+				"type": suggestTypes,
+			},
+		}
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": boolQuery,
+		},
+		"highlight": map[string]interface{}{
+			"fields": map[string]interface{}{
+				"text": map[string]interface{}{"number_of_fragments": 0},
+			},
+		},
+		"size": size,
+	})
+}
+
+// Suggest returns typed suggestions for prefix query. Request is limited
+// by SuggestTimeout, partial results are returned if elastic hits it.
+func (s *SearchV1) Suggest(query string, suggestTypes []string, size int) ([]*models.Suggestion, error) {
+	if *s.elasticDB == nil {
+		return nil, ErrSearchUnavailable
+	}
+
+	results := []*models.Suggestion{}
+
+	if strings.TrimSpace(query) == "" {
+		return results, nil
+	}
+
+	if size <= 0 {
+		size = suggestLimit
+	}
+
+	body, err := s.buildSuggestQuery(query, suggestTypes, size)
+	if err != nil {
+		return results, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.suggestTimeout)
+	defer cancel()
+
+	es := *s.elasticDB
+	if es == nil {
+		return results, elastic.ErrConnNotEstablished
+	}
+
+	res, err := es.Search(
+		es.Search.WithContext(ctx),
+		es.Search.WithIndex(suggestIndex),
+		es.Search.WithBody(bytes.NewReader(body)),
+		es.Search.WithTimeout(s.suggestTimeout),
+	)
+	if err != nil {
+		return results, err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		var e map[string]interface{}
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
+			return results, err
+		}
+		return results, fmt.Errorf("[%s] %s: %s", res.Status(), e["error"].(map[string]interface{})["type"], e["error"].(map[string]interface{})["reason"])
+	}
+
+	type envelopeResponse struct {
+		TimedOut bool `json:"timed_out"`
+		Hits     struct {
+			Hits []struct {
+				Score      float64             `json:"_score"`
+				Source     suggestDoc          `json:"_source"`
+				Highlights map[string][]string `json:"highlight"`
+			}
+		}
+	}
+
+	var r envelopeResponse
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return results, err
+	}
+
+	if r.TimedOut {
+		s.log.Warn().Msgf("suggest timed out, query %s", query)
+	}
+
+	for _, hit := range r.Hits.Hits {
+		item := &models.Suggestion{
+			Type:     hit.Source.Type,
+			RefID:    hit.Source.RefID,
+			Text:     hit.Source.Text,
+			Position: hit.Source.Position,
+			Company:  hit.Source.Company,
+			Score:    hit.Score,
+		}
+
+		if highlights := hit.Highlights["text"]; len(highlights) > 0 {
+			item.Highlight = highlights[0]
+		}
+
+		results = append(results, item)
+	}
+
+	return results, nil
+}
+
+// Reindex puts all innovations, themes and profiles to search indices
+// and runs reindexers registered by other domains
+func (s *SearchV1) Reindex() error {
+	conn := *s.db
+	if conn == nil {
+		return db.ErrDBConnNotEstablished
+	}
+
+	innovations := []models.Innovation{}
+	err := conn.Select(&innovations, "select * from production.innovation where deleted_at is null")
+	if err != nil {
+		return err
+	}
+
+	for i := range innovations {
+		if err = s.IndexInnovationSuggestions(&innovations[i]); err != nil {
+			return err
+		}
+	}
+
+	themes := []models.Theme{}
+	err = conn.Select(&themes, "select * from production.theme where deleted_at is null and archived_at is null")
+	if err != nil {
+		return err
+	}
+
+	for i := range themes {
+		if err = s.IndexTheme(&themes[i]); err != nil {
+			return err
+		}
+	}
+
+	profiles := []models.Profile{}
+	err = conn.Select(&profiles, "select * from production.profiles where deleted_at is null")
+	if err != nil {
+		return err
+	}
+
+	for i := range profiles {
+		if err = s.IndexProfile(&profiles[i]); err != nil {
+			return err
+		}
+	}
+
+	for _, reindex := range s.reindexers {
+		if err = reindex(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RemoveSuggestions removes entity from suggest index. Tags are shared
+// between entities, so they are kept.
+func (s *SearchV1) RemoveSuggestions(suggestType string, refID int) error {
+	return s.deleteSuggestion(suggestDocID(suggestType, strconv.Itoa(refID)))
+}
+
+const (
+	themeIndex      = "theme"
+	messageIndex    = "message"
+	profileIndex    = "profile"
+	innovationIndex = "innovation"
+	searchLimit     = 5
+)
+
+const themeMapping = `{
+	"mappings" : {
+		"properties" : {
+			"id" : { "type" : "integer" },
+			"direction" : { "type" : "integer" },
+			"title" : { "type" : "text", "analyzer" : "russian" },
+			"tags" : { "type" : "text", "analyzer" : "russian" },
+			"author_id" : { "type" : "integer" },
+			"created_at" : { "type" : "date" }
+		}
+	}
+}`
+
+const messageMapping = `{
+	"mappings" : {
+		"properties" : {
+			"channel" : { "type" : "keyword" },
+			"channel_type" : { "type" : "keyword" },
+			"message_id" : { "type" : "integer" },
+			"sender" : { "type" : "integer" },
+			"text" : { "type" : "text", "analyzer" : "russian" },
+			"timestamp" : { "type" : "date" }
+		}
+	}
+}`
+
+const profileMapping = `{
+	"mappings" : {
+		"properties" : {
+			"id" : { "type" : "integer" },
+			"full_name" : { "type" : "text" },
+			"position" : { "type" : "text", "analyzer" : "russian" },
+			"company" : { "type" : "text", "analyzer" : "russian" },
+			"electro_group" : { "type" : "keyword" }
+		}
+	}
+}`
+
+// themeDoc is a document of theme index
+type themeDoc struct {
+	ID        int       `json:"id"`
+	Direction int       `json:"direction"`
+	Title     string    `json:"title"`
+	Tags      string    `json:"tags"`
+	AuthorID  int       `json:"author_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// messageDoc is a document of message index
+type messageDoc struct {
+	Channel     string    `json:"channel"`
+	ChannelType string    `json:"channel_type"`
+	MessageID   int       `json:"message_id"`
+	Sender      int       `json:"sender"`
+	Text        string    `json:"text"`
+	TimeStamp   time.Time `json:"timestamp"`
+}
+
+// profileDoc is a document of profile index, keys are never indexed
+type profileDoc struct {
+	ID           int    `json:"id"`
+	FullName     string `json:"full_name"`
+	Position     string `json:"position"`
+	Company      string `json:"company"`
+	ElectroGroup string `json:"electro_group"`
+}
+
+func fullName(data *models.Profile) string {
+	return strings.Join(strings.Fields(data.LastName+" "+data.FirstName+" "+data.MiddleName), " ")
+}
+
+// IndexTheme puts theme to theme and suggest indices, deleted or archived
+// theme is removed from them
+func (s *SearchV1) IndexTheme(data *models.Theme) error {
+	id := strconv.Itoa(data.ID)
+
+	if data.Removed() {
+		err := s.deleteDocument(themeIndex, id)
+		if err != nil {
+			return err
+		}
+	} else {
+		err := s.putDocument(themeIndex, id, &themeDoc{
+			ID:        data.ID,
+			Direction: data.Direction,
+			Title:     data.Title,
+			Tags:      data.Tags,
+			AuthorID:  data.AuthorID,
+			CreatedAt: data.CreatedAt.Time,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.IndexThemeSuggestions(data)
+}
+
+// IndexProfile puts employee profile to profile and suggest indices
+func (s *SearchV1) IndexProfile(data *models.Profile) error {
+	if data.DeletedAt.Valid {
+		return s.RemoveProfile(data.ID)
+	}
+
+	err := s.putDocument(profileIndex, strconv.Itoa(data.ID), &profileDoc{
+		ID:           data.ID,
+		FullName:     fullName(data),
+		Position:     data.Position,
+		Company:      data.Company,
+		ElectroGroup: data.UserElectroGroup,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.IndexProfileSuggestions(data)
+}
+
+// RemoveProfile removes employee profile from profile and suggest indices
+func (s *SearchV1) RemoveProfile(id int) error {
+	err := s.deleteDocument(profileIndex, strconv.Itoa(id))
+	if err != nil {
+		return err
+	}
+
+	return s.RemoveSuggestions(models.SuggestProfile, id)
+}
+
+// IndexMessage puts chat message to message index
+func (s *SearchV1) IndexMessage(channel, channelType string, msg *models.Message) error {
+	// Chats created before types were introduced are theme discussions
+	if channelType == "" {
+		channelType = models.SearchTheme
+	}
+
+	return s.putDocument(messageIndex, channel+"-"+strconv.Itoa(msg.ID), &messageDoc{
+		Channel:     channel,
+		ChannelType: channelType,
+		MessageID:   msg.ID,
+		Sender:      msg.Sender,
+		Text:        msg.Text,
+		TimeStamp:   msg.TimeStamp,
+	})
+}
+
+// RemoveMessage removes deleted chat message from message index
+func (s *SearchV1) RemoveMessage(channel string, id int) error {
+	return s.deleteDocument(messageIndex, channel+"-"+strconv.Itoa(id))
+}
+
+// RegisterReindexer adds function which puts entities stored outside of
+// postgres to search indices on Reindex
+func (s *SearchV1) RegisterReindexer(reindex func() error) {
+	s.reindexers = append(s.reindexers, reindex)
+}
+
+// searchQueries builds per type query bodies. Users below moderator do not
+// see foreign innovation drafts and messages of non-theme channels.
+func searchQueries(user *models.User, query string, size int) map[string]map[string]interface{} {
+	privileged := user.Role >= types.Moderator
+
+	innovationQuery := map[string]interface{}{
+		"must": map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":  query,
+				"fields": []string{"title^3", "tags^2", "problem", "descriptions", "effect"},
+			},
+		},
+	}
+
+	messageQuery := map[string]interface{}{
+		"must": map[string]interface{}{
+			"match": map[string]interface{}{
+				"text": query,
+			},
+		},
+	}
+
+	if !privileged {
+		innovationQuery["filter"] = map[string]interface{}{
+			"bool": map[string]interface{}{
+				"should": []interface{}{
+					map[string]interface{}{"bool": map[string]interface{}{
+						"must_not": map[string]interface{}{"term": map[string]interface{}{"state.keyword": types.Draft.String()}},
+					}},
+					map[string]interface{}{"term": map[string]interface{}{"author_id": user.ID}},
+				},
+			},
+		}
+
+		messageQuery["filter"] = map[string]interface{}{
+			"bool": map[string]interface{}{
+				"should": []interface{}{
+					map[string]interface{}{"term": map[string]interface{}{"channel_type": models.SearchTheme}},
+					// Legacy theme chats indexed before reindex have no type
+					map[string]interface{}{"term": map[string]interface{}{"channel_type": ""}},
+					map[string]interface{}{"bool": map[string]interface{}{
+						"must_not": map[string]interface{}{"exists": map[string]interface{}{"field": "channel_type"}},
+					}},
+					map[string]interface{}{"term": map[string]interface{}{"sender": user.ID}},
+				},
+			},
+		}
+	}
+
+	return map[string]map[string]interface{}{
+		models.SearchInnovation: {
+			"query":   map[string]interface{}{"bool": innovationQuery},
+			"_source": map[string]interface{}{"excludes": []string{"attachments"}},
+			"highlight": map[string]interface{}{
+				"fields": map[string]interface{}{"title": map[string]interface{}{}, "problem": map[string]interface{}{}, "descriptions": map[string]interface{}{}},
+			},
+			"size": size,
+		},
+		models.SearchTheme: {
+			"query": map[string]interface{}{
+				"multi_match": map[string]interface{}{
+					"query":  query,
+					"fields": []string{"title^3", "tags"},
+				},
+			},
+			"highlight": map[string]interface{}{
+				"fields": map[string]interface{}{"title": map[string]interface{}{}, "tags": map[string]interface{}{}},
+			},
+			"size": size,
+		},
+		models.SearchMessage: {
+			"query": map[string]interface{}{"bool": messageQuery},
+			"highlight": map[string]interface{}{
+				"fields": map[string]interface{}{"text": map[string]interface{}{}},
+			},
+			"size": size,
+		},
+		models.SearchProfile: {
+			"query": map[string]interface{}{
+				"multi_match": map[string]interface{}{
+					"query":  query,
+					"fields": []string{"full_name^3", "position", "company"},
+				},
+			},
+			"highlight": map[string]interface{}{
+				"fields": map[string]interface{}{"full_name": map[string]interface{}{}},
+			},
+			"size": size,
+		},
+	}
+}
+
+var searchIndices = map[string]string{
+	models.SearchInnovation: innovationIndex,
+	models.SearchTheme:      themeIndex,
+	models.SearchMessage:    messageIndex,
+	models.SearchProfile:    profileIndex,
+}
+
+// GlobalSearch looks for query in innovations, themes, chat messages and
+// profiles by one multi search request. Every group keeps its own relevance.
+func (s *SearchV1) GlobalSearch(user *models.User, query string, searchTypes []string, size int) (models.GlobalSearch, error) {
+	if *s.elasticDB == nil {
+		return nil, ErrSearchUnavailable
+	}
+
+	results := models.GlobalSearch{}
+
+	if strings.TrimSpace(query) == "" {
+		return results, nil
+	}
+
+	if size <= 0 {
+		size = searchLimit
+	}
+
+	if len(searchTypes) == 0 {
+		searchTypes = []string{models.SearchInnovation, models.SearchTheme, models.SearchMessage, models.SearchProfile}
+	}
+
+	queries := searchQueries(user, query, size)
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	requested := []string{}
+
+	for _, searchType := range searchTypes {
+		index, ok := searchIndices[searchType]
+		if !ok {
+			return results, fmt.Errorf("%w: %s", ErrUnknownSearchType, searchType)
+		}
+
+		if err := enc.Encode(map[string]interface{}{"index": index, "ignore_unavailable": true}); err != nil {
+			return results, err
+		}
+
+		if err := enc.Encode(queries[searchType]); err != nil {
+			return results, err
+		}
+
+		requested = append(requested, searchType)
+	}
+
+	es := *s.elasticDB
+	if es == nil {
+		return results, elastic.ErrConnNotEstablished
+	}
+
+	res, err := es.Msearch(
+		&buf,
+		es.Msearch.WithContext(context.Background()),
+	)
+	if err != nil {
+		return results, err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		var e map[string]interface{}
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
+			return results, err
+		}
+		return results, fmt.Errorf("[%s] %s: %s", res.Status(), e["error"].(map[string]interface{})["type"], e["error"].(map[string]interface{})["reason"])
+	}
+
+	type envelopeResponse struct {
+		Responses []struct {
+			Error json.RawMessage `json:"error"`
+			Hits  struct {
+				Total struct {
+					Value int
+				}
+				MaxScore float64 `json:"max_score"`
+				Hits     []struct {
+					ID         string              `json:"_id"`
+					Score      float64             `json:"_score"`
+					Source     json.RawMessage     `json:"_source"`
+					Highlights map[string][]string `json:"highlight"`
+				}
+			}
+		}
+	}
+
+	var r envelopeResponse
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return results, err
+	}
+
+	for i, searchType := range requested {
+		group := &models.SearchGroup{Hits: []*models.SearchHit{}}
+		results[searchType] = group
+
+		if i >= len(r.Responses) {
+			continue
+		}
+
+		response := r.Responses[i]
+		if len(response.Error) > 0 {
+			s.log.Warn().Msgf("search in %s failed: %s", searchType, response.Error)
+			continue
+		}
+
+		group.Total = response.Hits.Total.Value
+		group.MaxScore = response.Hits.MaxScore
+
+		for _, hit := range response.Hits.Hits {
+			group.Hits = append(group.Hits, &models.SearchHit{
+				ID:         hit.ID,
+				Score:      hit.Score,
+				Source:     hit.Source,
+				Highlights: hit.Highlights,
+			})
+		}
+	}
+
+	return results, nil
+}
@@ -2,6 +2,8 @@ package sessionv1
 
 import (
 	"errors"
+	"sync"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo/v4"
@@ -9,6 +11,7 @@ import (
 	"github.com/sqsinformatique/rosseti-innovation-back/internal/context"
 	"github.com/sqsinformatique/rosseti-innovation-back/internal/httpsrv"
 	"github.com/sqsinformatique/rosseti-innovation-back/internal/orm"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
 )
 
 type empty struct{}
@@ -18,6 +21,10 @@ type SessionV1 struct {
 	db       **sqlx.DB
 	orm      *orm.ORM
 	publicV1 *echo.Group
+	ttl      time.Duration
+
+	hooksMu     sync.RWMutex
+	revokeHooks []func(userID int)
 }
 
 func NewSessionV1(ctx *context.Context, orm *orm.ORM) (*SessionV1, error) {
@@ -25,7 +32,13 @@ func NewSessionV1(ctx *context.Context, orm *orm.ORM) (*SessionV1, error) {
 		return nil, errors.New("empty context or orm client")
 	}
 
+	ttl, err := time.ParseDuration(ctx.Config.Session.TTL)
+	if err != nil {
+		return nil, err
+	}
+
 	s := &SessionV1{}
+	s.ttl = ttl
 	s.log = ctx.GetPackageLogger(empty{})
 	s.publicV1 = ctx.GetHTTPGroup(httpsrv.PublicSrv, httpsrv.V1)
 	s.db = ctx.GetDatabase()
@@ -35,3 +48,26 @@ func NewSessionV1(ctx *context.Context, orm *orm.ORM) (*SessionV1, error) {
 
 	return s, nil
 }
+
+// ExpiresAt returns time when session becomes invalid
+func (s *SessionV1) ExpiresAt(session *models.Session) time.Time {
+	return session.CreatedAt.Time.Add(s.ttl)
+}
+
+// RegisterRevokeHook adds function called after session of user is
+// deleted, hooks are called synchronously in order of registration
+func (s *SessionV1) RegisterRevokeHook(hook func(userID int)) {
+	s.hooksMu.Lock()
+	defer s.hooksMu.Unlock()
+
+	s.revokeHooks = append(s.revokeHooks, hook)
+}
+
+func (s *SessionV1) revoked(userID int) {
+	s.hooksMu.RLock()
+	defer s.hooksMu.RUnlock()
+
+	for _, hook := range s.revokeHooks {
+		hook(userID)
+	}
+}
@@ -1,11 +1,17 @@
 package sessionv1
 
 import (
+	"database/sql"
+	"errors"
+	"time"
+
 	"github.com/sqsinformatique/rosseti-innovation-back/internal/db"
 	"github.com/sqsinformatique/rosseti-innovation-back/internal/utils"
 	"github.com/sqsinformatique/rosseti-innovation-back/models"
 )
 
+var ErrSessionExpired = errors.New("session expired")
+
 func (s *SessionV1) CreateSession(id int) (*models.Session, error) {
 	var request models.Session
 
@@ -38,22 +44,76 @@ func (s *SessionV1) GetSession(id string) (data *models.Session, err error) {
 		return nil, err
 	}
 
+	if time.Now().After(s.ExpiresAt(data)) {
+		return nil, ErrSessionExpired
+	}
+
 	s.log.Debug().Msgf("session %+v", data)
 
 	return
 }
 
+// DeleteSession deletes session and notifies revoke hooks, deleting of
+// unknown session is not an error
 func (s *SessionV1) DeleteSession(id string) (err error) {
 	conn := *s.db
 	if conn == nil {
 		return db.ErrDBConnNotEstablished
 	}
 
-	_, err = conn.Exec(conn.Rebind("DELETE FROM production.sessions WHERE id=$1"), id)
+	var userID int
+
+	err = conn.Get(&userID, "DELETE FROM production.sessions WHERE id=$1 RETURNING user_id", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	s.revoked(userID)
+
+	return nil
+}
+
+// DeleteUserSessions deletes all sessions of user, e.g. when user is
+// deactivated, and notifies revoke hooks
+func (s *SessionV1) DeleteUserSessions(userID int) error {
+	conn := *s.db
+	if conn == nil {
+		return db.ErrDBConnNotEstablished
+	}
 
+	_, err := conn.Exec("DELETE FROM production.sessions WHERE user_id=$1", userID)
 	if err != nil {
 		return err
 	}
 
+	s.revoked(userID)
+
 	return nil
 }
+
+// UserSessionExpiresAt returns expiration time of the latest valid session
+// of user, ErrSessionExpired is returned if user has no valid sessions
+func (s *SessionV1) UserSessionExpiresAt(userID int) (time.Time, error) {
+	conn := *s.db
+	if conn == nil {
+		return time.Time{}, db.ErrDBConnNotEstablished
+	}
+
+	var created sql.NullTime
+
+	err := conn.Get(&created, "SELECT max(created_at) FROM production.sessions WHERE user_id=$1", userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	expiresAt := created.Time.Add(s.ttl)
+	if !created.Valid || time.Now().After(expiresAt) {
+		return time.Time{}, ErrSessionExpired
+	}
+
+	return expiresAt, nil
+}
@@ -0,0 +1,19 @@
+package statisticsv1
+
+import (
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/httpsrv"
+)
+
+type StatisticsDataResult httpsrv.ResultAnsw
+
+type StatusesDataResult httpsrv.ResultAnsw
+
+type DirectionsDataResult httpsrv.ResultAnsw
+
+type CompaniesDataResult httpsrv.ResultAnsw
+
+type MonthlyDataResult httpsrv.ResultAnsw
+
+type ExpertiseDataResult httpsrv.ResultAnsw
+
+type AuthorsDataResult httpsrv.ResultAnsw
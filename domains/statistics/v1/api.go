@@ -0,0 +1,366 @@
+package statisticsv1
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	echoSwagger "github.com/sqsinformatique/rosseti-innovation-back/internal/echo-swagger"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/httpsrv"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/logger"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+)
+
+// dateLayout is a format of period bounds in query
+const dateLayout = "2006-01-02"
+
+var ErrBadPeriod = errors.New("period start is after its end")
+
+// statisticsFilter reads period and company from query, the last day of
+// period is included
+func statisticsFilter(ec echo.Context) (*models.StatisticsFilter, error) {
+	filter := &models.StatisticsFilter{Company: ec.QueryParam("company")}
+
+	if ec.QueryParam("from") != "" {
+		from, err := time.ParseInLocation(dateLayout, ec.QueryParam("from"), time.Local)
+		if err != nil {
+			return nil, err
+		}
+
+		filter.From = &from
+	}
+
+	if ec.QueryParam("to") != "" {
+		to, err := time.ParseInLocation(dateLayout, ec.QueryParam("to"), time.Local)
+		if err != nil {
+			return nil, err
+		}
+
+		to = to.AddDate(0, 0, 1)
+		filter.To = &to
+	}
+
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, ErrBadPeriod
+	}
+
+	return filter, nil
+}
+
+func (s *StatisticsV1) statisticsGetHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("statisticsGetHandler").
+			SetSummary("Get all statistics for dashboard").
+			AddInQueryParameter("from", "First day of period, YYYY-MM-DD", reflect.String, false).
+			AddInQueryParameter("to", "Last day of period, YYYY-MM-DD", reflect.String, false).
+			AddInQueryParameter("company", "Company of authors", reflect.String, false).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &StatisticsDataResult{Body: &models.Statistics{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&s.log, ec)
+
+	filter, err := statisticsFilter(ec)
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, from %s to %s", ec.QueryParam("from"), ec.QueryParam("to"))
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	data, err := s.GetStatistics(filter)
+	if err != nil {
+		hndlLog.Err(err).Msg("GET STATISTICS FAILED")
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		StatisticsDataResult{Body: data},
+	)
+}
+
+func (s *StatisticsV1) statusesGetHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("statusesGetHandler").
+			SetSummary("Get count of innovations per status").
+			AddInQueryParameter("from", "First day of period, YYYY-MM-DD", reflect.String, false).
+			AddInQueryParameter("to", "Last day of period, YYYY-MM-DD", reflect.String, false).
+			AddInQueryParameter("company", "Company of authors", reflect.String, false).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &StatusesDataResult{Body: &[]*models.StatusCount{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&s.log, ec)
+
+	filter, err := statisticsFilter(ec)
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, from %s to %s", ec.QueryParam("from"), ec.QueryParam("to"))
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	data, err := s.GetStatuses(filter)
+	if err != nil {
+		hndlLog.Err(err).Msg("GET STATUSES STATISTICS FAILED")
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		StatusesDataResult{Body: data},
+	)
+}
+
+func (s *StatisticsV1) directionsGetHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("directionsGetHandler").
+			SetSummary("Get count of innovations per direction").
+			AddInQueryParameter("from", "First day of period, YYYY-MM-DD", reflect.String, false).
+			AddInQueryParameter("to", "Last day of period, YYYY-MM-DD", reflect.String, false).
+			AddInQueryParameter("company", "Company of authors", reflect.String, false).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &DirectionsDataResult{Body: &[]*models.DirectionCount{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&s.log, ec)
+
+	filter, err := statisticsFilter(ec)
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, from %s to %s", ec.QueryParam("from"), ec.QueryParam("to"))
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	data, err := s.GetDirections(filter)
+	if err != nil {
+		hndlLog.Err(err).Msg("GET DIRECTIONS STATISTICS FAILED")
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		DirectionsDataResult{Body: data},
+	)
+}
+
+func (s *StatisticsV1) companiesGetHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("companiesGetHandler").
+			SetSummary("Get count of innovations per company and branch").
+			AddInQueryParameter("from", "First day of period, YYYY-MM-DD", reflect.String, false).
+			AddInQueryParameter("to", "Last day of period, YYYY-MM-DD", reflect.String, false).
+			AddInQueryParameter("company", "Company of authors", reflect.String, false).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &CompaniesDataResult{Body: &[]*models.CompanyCount{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&s.log, ec)
+
+	filter, err := statisticsFilter(ec)
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, from %s to %s", ec.QueryParam("from"), ec.QueryParam("to"))
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	data, err := s.GetCompanies(filter)
+	if err != nil {
+		hndlLog.Err(err).Msg("GET COMPANIES STATISTICS FAILED")
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		CompaniesDataResult{Body: data},
+	)
+}
+
+func (s *StatisticsV1) monthlyGetHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("monthlyGetHandler").
+			SetSummary("Get count of submitted and recognized innovations per month").
+			AddInQueryParameter("from", "First day of period, YYYY-MM-DD", reflect.String, false).
+			AddInQueryParameter("to", "Last day of period, YYYY-MM-DD", reflect.String, false).
+			AddInQueryParameter("company", "Company of authors", reflect.String, false).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &MonthlyDataResult{Body: &[]*models.MonthlyCount{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&s.log, ec)
+
+	filter, err := statisticsFilter(ec)
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, from %s to %s", ec.QueryParam("from"), ec.QueryParam("to"))
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	data, err := s.GetMonthly(filter)
+	if err != nil {
+		hndlLog.Err(err).Msg("GET MONTHLY STATISTICS FAILED")
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		MonthlyDataResult{Body: data},
+	)
+}
+
+func (s *StatisticsV1) expertiseGetHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("expertiseGetHandler").
+			SetSummary("Get average time in expertise and recognition rate").
+			AddInQueryParameter("from", "First day of period, YYYY-MM-DD", reflect.String, false).
+			AddInQueryParameter("to", "Last day of period, YYYY-MM-DD", reflect.String, false).
+			AddInQueryParameter("company", "Company of authors", reflect.String, false).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &ExpertiseDataResult{Body: &models.ExpertiseStatistics{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&s.log, ec)
+
+	filter, err := statisticsFilter(ec)
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, from %s to %s", ec.QueryParam("from"), ec.QueryParam("to"))
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	data, err := s.GetExpertise(filter)
+	if err != nil {
+		hndlLog.Err(err).Msg("GET EXPERTISE STATISTICS FAILED")
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		ExpertiseDataResult{Body: data},
+	)
+}
+
+func (s *StatisticsV1) authorsGetHandler(ec echo.Context) (err error) {
+	if echoSwagger.IsBuildingSwagger(ec) {
+		echoSwagger.AddToSwagger(ec).
+			SetProduces("application/json").
+			SetDescription("authorsGetHandler").
+			SetSummary("Get top authors").
+			AddInQueryParameter("from", "First day of period, YYYY-MM-DD", reflect.String, false).
+			AddInQueryParameter("to", "Last day of period, YYYY-MM-DD", reflect.String, false).
+			AddInQueryParameter("company", "Company of authors", reflect.String, false).
+			AddInQueryParameter("limit", "Max count of authors", reflect.Int64, false).
+			AddInHeaderParameter("Authorization", "Authorization header", reflect.String, true).
+			AddResponse(http.StatusOK, "OK", &AuthorsDataResult{Body: &[]*models.AuthorStatistics{}})
+		return nil
+	}
+
+	// Main code of handler
+	hndlLog := logger.HandlerLogger(&s.log, ec)
+
+	filter, err := statisticsFilter(ec)
+	if err != nil {
+		hndlLog.Err(err).Msgf("BAD REQUEST, from %s to %s", ec.QueryParam("from"), ec.QueryParam("to"))
+
+		return ec.JSON(
+			http.StatusBadRequest,
+			httpsrv.BadRequest(err),
+		)
+	}
+
+	limit := authorsLimit
+	if ec.QueryParam("limit") != "" {
+		limit, err = strconv.Atoi(ec.QueryParam("limit"))
+		if err != nil {
+			hndlLog.Err(err).Msgf("BAD REQUEST, limit %s", ec.QueryParam("limit"))
+
+			return ec.JSON(
+				http.StatusBadRequest,
+				httpsrv.BadRequest(err),
+			)
+		}
+	}
+
+	data, err := s.GetTopAuthors(filter, limit)
+	if err != nil {
+		hndlLog.Err(err).Msg("GET TOP AUTHORS FAILED")
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
+	return ec.JSON(
+		http.StatusOK,
+		AuthorsDataResult{Body: data},
+	)
+}
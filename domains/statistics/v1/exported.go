@@ -0,0 +1,44 @@
+package statisticsv1
+
+import (
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+	userv1 "github.com/sqsinformatique/rosseti-innovation-back/domains/user/v1"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/context"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/httpsrv"
+	"github.com/sqsinformatique/rosseti-innovation-back/types"
+)
+
+type empty struct{}
+
+type StatisticsV1 struct {
+	log      zerolog.Logger
+	db       **sqlx.DB
+	publicV1 *echo.Group
+	userV1   *userv1.UserV1
+}
+
+func NewStatisticsV1(ctx *context.Context, userV1 *userv1.UserV1) (*StatisticsV1, error) {
+	if ctx == nil || userV1 == nil {
+		return nil, errors.New("empty context or userV1 client")
+	}
+
+	s := &StatisticsV1{}
+	s.log = ctx.GetPackageLogger(empty{})
+	s.publicV1 = ctx.GetHTTPGroup(httpsrv.PublicSrv, httpsrv.V1)
+	s.db = ctx.GetDatabase()
+	s.userV1 = userV1
+
+	s.publicV1.GET("/statistics", s.userV1.Introspect(s.statisticsGetHandler, types.Business))
+	s.publicV1.GET("/statistics/statuses", s.userV1.Introspect(s.statusesGetHandler, types.Business))
+	s.publicV1.GET("/statistics/directions", s.userV1.Introspect(s.directionsGetHandler, types.Business))
+	s.publicV1.GET("/statistics/companies", s.userV1.Introspect(s.companiesGetHandler, types.Business))
+	s.publicV1.GET("/statistics/monthly", s.userV1.Introspect(s.monthlyGetHandler, types.Business))
+	s.publicV1.GET("/statistics/expertise", s.userV1.Introspect(s.expertiseGetHandler, types.Business))
+	s.publicV1.GET("/statistics/authors", s.userV1.Introspect(s.authorsGetHandler, types.Business))
+
+	return s, nil
+}
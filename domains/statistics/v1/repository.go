@@ -0,0 +1,253 @@
+package statisticsv1
+
+import (
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/db"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
+)
+
+const (
+	authorsLimit    = 10
+	authorsMaxLimit = 100
+)
+
+// filteredInnovations is a common part of statistics queries, it selects
+// innovations matching filter with company and branch of author and
+// direction. Parameters are $1 from, $2 to and $3 company.
+const filteredInnovations = `
+	with filtered as (
+		select i.id, i.author_id, i.state, i.like_counter, i.created_at,
+			coalesce(p.user_company, '') as company,
+			coalesce(p.meta->>'branch', '') as branch,
+			coalesce(i.direction, 0) as direction_id
+		from production.innovation i
+		left join production.profiles p on p.id = i.author_id
+		where i.deleted_at is null
+			and ($1::timestamptz is null or i.created_at >= $1)
+			and ($2::timestamptz is null or i.created_at < $2)
+			and ($3 = '' or p.user_company = $3)
+	)`
+
+// recognizedStates are states of recognized proposal, experiment and
+// replication follow recognition
+const recognizedStates = `('RECOGNIZED', 'EXPERIMENT', 'EXPERIMENT_SUCCESS', 'EXPERIMENT_FAILED', 'REPLICATION_SUCCESS', 'REPLICATION_FAILED')`
+
+func filterArgs(filter *models.StatisticsFilter) []interface{} {
+	return []interface{}{filter.From, filter.To, filter.Company}
+}
+
+func (s *StatisticsV1) GetStatuses(filter *models.StatisticsFilter) ([]*models.StatusCount, error) {
+	conn := *s.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	data := []*models.StatusCount{}
+
+	err := conn.Select(&data, filteredInnovations+`
+		select coalesce(state, '') as state, count(*) as count
+		from filtered
+		group by 1
+		order by count desc, state`,
+		filterArgs(filter)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (s *StatisticsV1) GetDirections(filter *models.StatisticsFilter) ([]*models.DirectionCount, error) {
+	conn := *s.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	data := []*models.DirectionCount{}
+
+	err := conn.Select(&data, filteredInnovations+`
+		select f.direction_id, coalesce(d.title, '') as title, count(*) as count,
+			count(*) filter (where f.state in `+recognizedStates+`) as recognized
+		from filtered f
+		left join production.direction d on d.id = f.direction_id
+		group by f.direction_id, d.title
+		order by count desc, f.direction_id`,
+		filterArgs(filter)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (s *StatisticsV1) GetCompanies(filter *models.StatisticsFilter) ([]*models.CompanyCount, error) {
+	conn := *s.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	data := []*models.CompanyCount{}
+
+	err := conn.Select(&data, filteredInnovations+`
+		select company, branch, count(*) as count,
+			count(*) filter (where state in `+recognizedStates+`) as recognized
+		from filtered
+		group by company, branch
+		order by count desc, company, branch`,
+		filterArgs(filter)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// GetMonthly counts innovations by month of creation and month of the
+// first recognition
+func (s *StatisticsV1) GetMonthly(filter *models.StatisticsFilter) ([]*models.MonthlyCount, error) {
+	conn := *s.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	data := []*models.MonthlyCount{}
+
+	err := conn.Select(&data, filteredInnovations+`
+		select month,
+			count(*) filter (where kind = 'submitted') as submitted,
+			count(*) filter (where kind = 'recognized') as recognized
+		from (
+			select date_trunc('month', created_at) as month, 'submitted' as kind
+			from filtered
+			union all
+			select date_trunc('month', min(s.created_at)), 'recognized'
+			from production.innovation_states s
+			join filtered f on f.id = s.innovation_id
+			where s.state = 'RECOGNIZED'
+			group by s.innovation_id
+		) events
+		group by month
+		order by month`,
+		filterArgs(filter)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// GetExpertise computes time in expertise by state history, time of
+// repeated expertise of one innovation is summed
+func (s *StatisticsV1) GetExpertise(filter *models.StatisticsFilter) (*models.ExpertiseStatistics, error) {
+	conn := *s.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	data := &models.ExpertiseStatistics{}
+
+	err := conn.Get(data, filteredInnovations+`,
+	periods as (
+		select s.innovation_id, s.state, s.created_at,
+			lead(s.created_at) over (partition by s.innovation_id order by s.created_at, s.id) as left_at
+		from production.innovation_states s
+		join filtered f on f.id = s.innovation_id
+	),
+	expertise as (
+		select innovation_id,
+			sum(extract(epoch from coalesce(left_at, now()) - created_at)) / 3600 as hours,
+			bool_or(left_at is null) as ongoing
+		from periods
+		where state = 'EXPERTISE'
+		group by innovation_id
+	)
+	select
+		(select count(*) from expertise) as expertised,
+		(select count(*) from expertise where ongoing) as in_expertise,
+		(select coalesce(avg(hours), 0) from expertise) as avg_expertise_hours,
+		(select count(*) from filtered where state in `+recognizedStates+`) as recognized,
+		(select count(*) from filtered where state = 'REVOKED') as revoked`,
+		filterArgs(filter)...)
+	if err != nil {
+		return nil, err
+	}
+
+	if decided := data.Recognized + data.Revoked; decided > 0 {
+		data.RecognitionRate = float64(data.Recognized) / float64(decided)
+	}
+
+	return data, nil
+}
+
+func (s *StatisticsV1) GetTopAuthors(filter *models.StatisticsFilter, limit int) ([]*models.AuthorStatistics, error) {
+	conn := *s.db
+	if conn == nil {
+		return nil, db.ErrDBConnNotEstablished
+	}
+
+	if limit <= 0 || limit > authorsMaxLimit {
+		limit = authorsLimit
+	}
+
+	data := []*models.AuthorStatistics{}
+
+	err := conn.Select(&data, filteredInnovations+`
+		select f.author_id,
+			trim(coalesce(p.user_first_name, '') || ' ' || coalesce(p.user_last_name, '')) as name,
+			coalesce(p.user_position, '') as position,
+			coalesce(p.user_company, '') as company,
+			count(*) as count,
+			count(*) filter (where f.state in `+recognizedStates+`) as recognized,
+			coalesce(sum(f.like_counter), 0) as likes
+		from filtered f
+		left join production.profiles p on p.id = f.author_id
+		group by f.author_id, p.user_first_name, p.user_last_name, p.user_position, p.user_company
+		order by recognized desc, count desc, likes desc, f.author_id
+		limit $4`,
+		append(filterArgs(filter), limit)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// GetStatistics collects all statistics for dashboard
+func (s *StatisticsV1) GetStatistics(filter *models.StatisticsFilter) (data *models.Statistics, err error) {
+	data = &models.Statistics{Filter: filter}
+
+	data.Statuses, err = s.GetStatuses(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, status := range data.Statuses {
+		data.Total += status.Count
+	}
+
+	data.Directions, err = s.GetDirections(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	data.Companies, err = s.GetCompanies(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	data.Monthly, err = s.GetMonthly(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	data.Expertise, err = s.GetExpertise(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	data.TopAuthors, err = s.GetTopAuthors(filter, authorsLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
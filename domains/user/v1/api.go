@@ -6,7 +6,6 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strconv"
-	"time"
 
 	"github.com/labstack/echo/v4"
 	echoSwagger "github.com/sqsinformatique/rosseti-innovation-back/internal/echo-swagger"
@@ -304,6 +303,17 @@ func (u *UserV1) UserDeleteHandler(ec echo.Context) (err error) {
 		)
 	}
 
+	// Deleted user must not stay connected with sessions created before
+	err = u.sessionV1.DeleteUserSessions(int(userID))
+	if err != nil {
+		hndlLog.Err(err).Msgf("DELETE SESSIONS FAILED, id %d", userID)
+
+		return ec.JSON(
+			http.StatusInternalServerError,
+			httpsrv.InternalServerError(err),
+		)
+	}
+
 	return ec.JSON(
 		http.StatusOK,
 		httpsrv.OkResult(),
@@ -369,7 +379,7 @@ func (u *UserV1) authPostHandler(ec echo.Context) (err error) {
 	cookie := new(http.Cookie)
 	cookie.Name = "rosseti-session"
 	cookie.Value = session.ID
-	cookie.Expires = time.Now().Add(24 * time.Hour)
+	cookie.Expires = u.sessionV1.ExpiresAt(session)
 
 	return ec.JSON(
 		http.StatusOK,
@@ -428,6 +438,8 @@ func (u *UserV1) Introspect(next echo.HandlerFunc, minRole types.Role) echo.Hand
 			)
 		}
 
+		ec.Set(currentUserKey, user)
+
 		return next(ec)
 	}
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/sqsinformatique/rosseti-innovation-back/internal/context"
 	"github.com/sqsinformatique/rosseti-innovation-back/internal/httpsrv"
 	"github.com/sqsinformatique/rosseti-innovation-back/internal/orm"
+	"github.com/sqsinformatique/rosseti-innovation-back/models"
 	"github.com/sqsinformatique/rosseti-innovation-back/types"
 )
 
@@ -48,3 +49,32 @@ func NewUserV1(ctx *context.Context, orm *orm.ORM, sessionV1 *sessionv1.SessionV
 
 	return u, nil
 }
+
+const currentUserKey = "rosseti-user"
+
+// CurrentUser returns user of request session. User stored in context by
+// Introspect is used if present, otherwise it resolved from session cookie
+func (u *UserV1) CurrentUser(ec echo.Context) (*models.User, error) {
+	if user, ok := ec.Get(currentUserKey).(*models.User); ok {
+		return user, nil
+	}
+
+	idCookie, err := ec.Cookie("rosseti-session")
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := u.sessionV1.GetSession(idCookie.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := u.GetUserByID(int64(session.UserID))
+	if err != nil {
+		return nil, err
+	}
+
+	ec.Set(currentUserKey, user)
+
+	return user, nil
+}
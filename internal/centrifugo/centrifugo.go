@@ -0,0 +1,244 @@
+package centrifugo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog"
+	intctx "github.com/sqsinformatique/rosseti-innovation-back/internal/context"
+)
+
+type empty struct{}
+
+var ErrBadStatus = errors.New("unexpected centrifugo API response status")
+
+// Error is an error returned by centrifugo in API reply
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("centrifugo error %d: %s", e.Code, e.Message)
+}
+
+// ClientInfo describes connection subscribed to channel
+type ClientInfo struct {
+	User     string          `json:"user"`
+	Client   string          `json:"client"`
+	ConnInfo json.RawMessage `json:"conn_info,omitempty"`
+	ChanInfo json.RawMessage `json:"chan_info,omitempty"`
+}
+
+// PresenceStats contains counters of channel presence
+type PresenceStats struct {
+	NumClients int `json:"num_clients"`
+	NumUsers   int `json:"num_users"`
+}
+
+// Publication is a message kept in channel history
+type Publication struct {
+	Data   json.RawMessage `json:"data"`
+	Info   *ClientInfo     `json:"info,omitempty"`
+	Offset uint64          `json:"offset,omitempty"`
+}
+
+// PersonalChannel returns user limited channel of user, only the user is
+// allowed to subscribe to it
+func PersonalChannel(userID int) string {
+	return "personal:#" + strconv.Itoa(userID)
+}
+
+// Client is a centrifugo server API client
+type Client interface {
+	Publish(channel string, data interface{}) error
+	Broadcast(channels []string, data interface{}) error
+	Unsubscribe(channel, user string) error
+	Disconnect(user string) error
+	Presence(channel string) (map[string]ClientInfo, error)
+	PresenceStats(channel string) (*PresenceStats, error)
+	History(channel string) ([]Publication, error)
+	HistoryRemove(channel string) error
+	Channels() ([]string, error)
+}
+
+// HTTPClient calls centrifugo HTTP API, connections are reused between calls
+type HTTPClient struct {
+	log    zerolog.Logger
+	dsn    string
+	apiKey string
+	client *http.Client
+}
+
+type request struct {
+	Method string      `json:"method"`
+	Params interface{} `json:"params"`
+}
+
+type reply struct {
+	Error  *Error          `json:"error"`
+	Result json.RawMessage `json:"result"`
+}
+
+// NewHTTPClient creates client for API endpoint from configuration
+func NewHTTPClient(ctx *intctx.Context) (*HTTPClient, error) {
+	if ctx == nil || ctx.Config == nil {
+		return nil, errors.New("empty context or config")
+	}
+
+	timeout, err := time.ParseDuration(ctx.Config.Centrifugo.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &HTTPClient{}
+	c.log = ctx.GetPackageLogger(empty{})
+	c.dsn = ctx.Config.Centrifugo.DSN
+	c.apiKey = ctx.Config.Centrifugo.APIKey
+	c.client = &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: 100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+
+	return c, nil
+}
+
+// call sends API command and decodes its result to result if it's not nil
+func (c *HTTPClient) call(method string, params interface{}, result interface{}) error {
+	jsonData, err := json.Marshal(&request{Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.dsn, bytes.NewReader(jsonData))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "apikey "+c.apiKey)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// Drain body to reuse connection
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		return fmt.Errorf("%w: %s on %s", ErrBadStatus, resp.Status, method)
+	}
+
+	var r reply
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return err
+	}
+
+	if r.Error != nil {
+		c.log.Debug().Msgf("centrifugo %s failed: %s", method, r.Error)
+		return r.Error
+	}
+
+	if result == nil || len(r.Result) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(r.Result, result)
+}
+
+func (c *HTTPClient) Publish(channel string, data interface{}) error {
+	return c.call("publish", map[string]interface{}{"channel": channel, "data": data}, nil)
+}
+
+func (c *HTTPClient) Broadcast(channels []string, data interface{}) error {
+	return c.call("broadcast", map[string]interface{}{"channels": channels, "data": data}, nil)
+}
+
+func (c *HTTPClient) Unsubscribe(channel, user string) error {
+	return c.call("unsubscribe", map[string]interface{}{"channel": channel, "user": user}, nil)
+}
+
+func (c *HTTPClient) Disconnect(user string) error {
+	return c.call("disconnect", map[string]interface{}{"user": user}, nil)
+}
+
+func (c *HTTPClient) Presence(channel string) (map[string]ClientInfo, error) {
+	var result struct {
+		Presence map[string]ClientInfo `json:"presence"`
+	}
+
+	err := c.call("presence", map[string]interface{}{"channel": channel}, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Presence == nil {
+		result.Presence = map[string]ClientInfo{}
+	}
+
+	return result.Presence, nil
+}
+
+func (c *HTTPClient) PresenceStats(channel string) (*PresenceStats, error) {
+	result := &PresenceStats{}
+
+	err := c.call("presence_stats", map[string]interface{}{"channel": channel}, result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (c *HTTPClient) History(channel string) ([]Publication, error) {
+	var result struct {
+		Publications []Publication `json:"publications"`
+	}
+
+	err := c.call("history", map[string]interface{}{"channel": channel}, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Publications == nil {
+		result.Publications = []Publication{}
+	}
+
+	return result.Publications, nil
+}
+
+func (c *HTTPClient) HistoryRemove(channel string) error {
+	return c.call("history_remove", map[string]interface{}{"channel": channel}, nil)
+}
+
+func (c *HTTPClient) Channels() ([]string, error) {
+	var result struct {
+		Channels []string `json:"channels"`
+	}
+
+	err := c.call("channels", map[string]interface{}{}, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Channels == nil {
+		result.Channels = []string{}
+	}
+
+	return result.Channels, nil
+}
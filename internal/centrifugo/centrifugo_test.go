@@ -0,0 +1,112 @@
+package centrifugo
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+// apiServer answers every API command with reply and keeps the last
+// request for checks
+type apiServer struct {
+	status  int
+	reply   string
+	auth    string
+	request request
+}
+
+func (s *apiServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.auth = r.Header.Get("Authorization")
+
+	var params json.RawMessage
+	s.request = request{Params: &params}
+
+	if err := json.NewDecoder(r.Body).Decode(&s.request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.request.Params = params
+
+	w.WriteHeader(s.status)
+	_, _ = w.Write([]byte(s.reply))
+}
+
+func newTestClient(t *testing.T, s *apiServer) *HTTPClient {
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+
+	return &HTTPClient{log: zerolog.Nop(), dsn: srv.URL, apiKey: "secret", client: srv.Client()}
+}
+
+func TestPublish(t *testing.T) {
+	s := &apiServer{status: http.StatusOK, reply: `{"result":{}}`}
+	c := newTestClient(t, s)
+
+	if err := c.Publish("theme:1", map[string]string{"text": "hi"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.auth != "apikey secret" {
+		t.Errorf("authorization %q, expected api key", s.auth)
+	}
+
+	params, _ := json.Marshal(s.request.Params)
+	if s.request.Method != "publish" || string(params) != `{"channel":"theme:1","data":{"text":"hi"}}` {
+		t.Errorf("sent %s %s", s.request.Method, params)
+	}
+}
+
+func TestPresence(t *testing.T) {
+	s := &apiServer{status: http.StatusOK, reply: `{"result":{"presence":{"c1":{"user":"10","client":"c1"}}}}`}
+	c := newTestClient(t, s)
+
+	presence, err := c.Presence("theme:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(presence) != 1 || presence["c1"].User != "10" {
+		t.Errorf("presence %v, expected client c1 of user 10", presence)
+	}
+}
+
+func TestEmptyResults(t *testing.T) {
+	s := &apiServer{status: http.StatusOK, reply: `{"result":{}}`}
+	c := newTestClient(t, s)
+
+	presence, err := c.Presence("theme:1")
+	if err != nil || presence == nil {
+		t.Errorf("presence %v, error %v, expected empty map", presence, err)
+	}
+
+	channels, err := c.Channels()
+	if err != nil || channels == nil {
+		t.Errorf("channels %v, error %v, expected empty list", channels, err)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	s := &apiServer{status: http.StatusOK, reply: `{"error":{"code":102,"message":"unknown channel"}}`}
+	c := newTestClient(t, s)
+
+	err := c.Publish("theme:1", nil)
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Code != 102 {
+		t.Errorf("error %v, expected centrifugo error 102", err)
+	}
+}
+
+func TestBadStatus(t *testing.T) {
+	s := &apiServer{status: http.StatusUnauthorized}
+	c := newTestClient(t, s)
+
+	if err := c.Disconnect("10"); !errors.Is(err, ErrBadStatus) {
+		t.Errorf("error %v, expected ErrBadStatus", err)
+	}
+}
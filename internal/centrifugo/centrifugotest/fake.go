@@ -0,0 +1,229 @@
+// Package centrifugotest provides in-memory centrifugo client for domains
+// which must run without centrifugo server.
+package centrifugotest
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/centrifugo"
+)
+
+// Call is an API command received by Fake
+type Call struct {
+	Method   string
+	Channels []string
+	User     string
+	Data     json.RawMessage
+}
+
+// Fake implements centrifugo.Client. Published data is kept in channel
+// history, presence is set by Subscribe. Err is returned by every call if set.
+type Fake struct {
+	mu       sync.Mutex
+	Calls    []Call
+	Err      error
+	history  map[string][]centrifugo.Publication
+	presence map[string]map[string]centrifugo.ClientInfo
+}
+
+var _ centrifugo.Client = &Fake{}
+
+// NewFake returns empty fake client
+func NewFake() *Fake {
+	return &Fake{
+		history:  make(map[string][]centrifugo.Publication),
+		presence: make(map[string]map[string]centrifugo.ClientInfo),
+	}
+}
+
+func (f *Fake) record(method string, channels []string, user string, data interface{}) (json.RawMessage, error) {
+	var raw json.RawMessage
+
+	if data != nil {
+		jsonData, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		raw = jsonData
+	}
+
+	f.Calls = append(f.Calls, Call{Method: method, Channels: channels, User: user, Data: raw})
+
+	return raw, f.Err
+}
+
+// Subscribe adds connection of user to channel presence
+func (f *Fake) Subscribe(channel, user, client string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.presence[channel] == nil {
+		f.presence[channel] = make(map[string]centrifugo.ClientInfo)
+	}
+
+	f.presence[channel][client] = centrifugo.ClientInfo{User: user, Client: client}
+}
+
+// CallsOf returns recorded calls of method
+func (f *Fake) CallsOf(method string) []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	calls := []Call{}
+	for _, call := range f.Calls {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+
+	return calls
+}
+
+func (f *Fake) publish(channel string, data json.RawMessage) {
+	offset := uint64(len(f.history[channel]) + 1)
+	f.history[channel] = append(f.history[channel], centrifugo.Publication{Data: data, Offset: offset})
+}
+
+func (f *Fake) Publish(channel string, data interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	raw, err := f.record("publish", []string{channel}, "", data)
+	if err != nil {
+		return err
+	}
+
+	f.publish(channel, raw)
+
+	return nil
+}
+
+func (f *Fake) Broadcast(channels []string, data interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	raw, err := f.record("broadcast", channels, "", data)
+	if err != nil {
+		return err
+	}
+
+	for _, channel := range channels {
+		f.publish(channel, raw)
+	}
+
+	return nil
+}
+
+func (f *Fake) Unsubscribe(channel, user string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.record("unsubscribe", []string{channel}, user, nil); err != nil {
+		return err
+	}
+
+	for client, info := range f.presence[channel] {
+		if info.User == user {
+			delete(f.presence[channel], client)
+		}
+	}
+
+	return nil
+}
+
+func (f *Fake) Disconnect(user string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.record("disconnect", nil, user, nil); err != nil {
+		return err
+	}
+
+	for channel := range f.presence {
+		for client, info := range f.presence[channel] {
+			if info.User == user {
+				delete(f.presence[channel], client)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (f *Fake) Presence(channel string) (map[string]centrifugo.ClientInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.record("presence", []string{channel}, "", nil); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]centrifugo.ClientInfo, len(f.presence[channel]))
+	for client, info := range f.presence[channel] {
+		result[client] = info
+	}
+
+	return result, nil
+}
+
+func (f *Fake) PresenceStats(channel string) (*centrifugo.PresenceStats, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.record("presence_stats", []string{channel}, "", nil); err != nil {
+		return nil, err
+	}
+
+	users := make(map[string]struct{})
+	for _, info := range f.presence[channel] {
+		users[info.User] = struct{}{}
+	}
+
+	return &centrifugo.PresenceStats{NumClients: len(f.presence[channel]), NumUsers: len(users)}, nil
+}
+
+func (f *Fake) History(channel string) ([]centrifugo.Publication, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.record("history", []string{channel}, "", nil); err != nil {
+		return nil, err
+	}
+
+	return append([]centrifugo.Publication{}, f.history[channel]...), nil
+}
+
+func (f *Fake) HistoryRemove(channel string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.record("history_remove", []string{channel}, "", nil); err != nil {
+		return err
+	}
+
+	delete(f.history, channel)
+
+	return nil
+}
+
+func (f *Fake) Channels() ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.record("channels", nil, "", nil); err != nil {
+		return nil, err
+	}
+
+	channels := []string{}
+	for channel, clients := range f.presence {
+		if len(clients) > 0 {
+			channels = append(channels, channel)
+		}
+	}
+
+	sort.Strings(channels)
+
+	return channels, nil
+}
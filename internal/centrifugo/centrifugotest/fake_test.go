@@ -0,0 +1,102 @@
+package centrifugotest
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestPublishHistory(t *testing.T) {
+	f := NewFake()
+
+	if err := f.Publish("theme:1", map[string]string{"text": "hi"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.Broadcast([]string{"theme:1", "theme:2"}, "bye"); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := f.History("theme:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(history) != 2 || string(history[0].Data) != `{"text":"hi"}` || history[1].Offset != 2 {
+		t.Errorf("history %+v, expected 2 publications in order", history)
+	}
+
+	if err = f.HistoryRemove("theme:1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if history, _ = f.History("theme:1"); len(history) != 0 {
+		t.Errorf("history %+v after remove, expected empty", history)
+	}
+
+	if calls := f.CallsOf("broadcast"); len(calls) != 1 || len(calls[0].Channels) != 2 {
+		t.Errorf("broadcast calls %+v, expected one to 2 channels", calls)
+	}
+}
+
+func TestPresence(t *testing.T) {
+	f := NewFake()
+	f.Subscribe("theme:1", "10", "a")
+	f.Subscribe("theme:1", "10", "b")
+	f.Subscribe("theme:2", "20", "c")
+
+	stats, err := f.PresenceStats("theme:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.NumClients != 2 || stats.NumUsers != 1 {
+		t.Errorf("stats %+v, expected 2 clients of 1 user", stats)
+	}
+
+	if err = f.Unsubscribe("theme:1", "10"); err != nil {
+		t.Fatal(err)
+	}
+
+	channels, err := f.Channels()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(channels) != 1 || channels[0] != "theme:2" {
+		t.Errorf("channels %v, expected only theme:2", channels)
+	}
+
+	if err = f.Disconnect("20"); err != nil {
+		t.Fatal(err)
+	}
+
+	presence, err := f.Presence("theme:2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(presence) != 0 {
+		t.Errorf("presence %v after disconnect, expected empty", presence)
+	}
+}
+
+func TestErr(t *testing.T) {
+	f := NewFake()
+	f.Err = errors.New("centrifugo is down")
+
+	if err := f.Publish("theme:1", "hi"); !errors.Is(err, f.Err) {
+		t.Errorf("error %v, expected Err", err)
+	}
+
+	calls := f.CallsOf("publish")
+	if len(calls) != 1 || !json.Valid(calls[0].Data) {
+		t.Errorf("calls %+v, expected failed publish recorded", calls)
+	}
+
+	f.Err = nil
+
+	if history, _ := f.History("theme:1"); len(history) != 0 {
+		t.Errorf("history %+v, failed publish must not be stored", history)
+	}
+}
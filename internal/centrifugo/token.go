@@ -0,0 +1,58 @@
+package centrifugo
+
+import (
+	"errors"
+
+	"github.com/golang-jwt/jwt"
+)
+
+var ErrNoTokenSecret = errors.New("centrifugo token secret is not configured")
+
+// connectionClaims are claims of connection token, see:
+// https://centrifugal.github.io/centrifugo/server/authentication/
+type connectionClaims struct {
+	Info interface{} `json:"info,omitempty"`
+	jwt.StandardClaims
+}
+
+// subscriptionClaims are claims of private channel subscription token, see:
+// https://centrifugal.github.io/centrifugo/server/private_channels/
+type subscriptionClaims struct {
+	Client  string      `json:"client"`
+	Channel string      `json:"channel"`
+	Info    interface{} `json:"info,omitempty"`
+	jwt.StandardClaims
+}
+
+func sign(secret string, claims jwt.Claims) (string, error) {
+	if secret == "" {
+		return "", ErrNoTokenSecret
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+}
+
+// ConnectionToken returns HS256 token which authenticates connection of
+// user until expireAt unix time, info is attached to the connection
+func ConnectionToken(secret, user string, expireAt int64, info interface{}) (string, error) {
+	return sign(secret, &connectionClaims{
+		Info: info,
+		StandardClaims: jwt.StandardClaims{
+			Subject:   user,
+			ExpiresAt: expireAt,
+		},
+	})
+}
+
+// SubscriptionToken returns HS256 token which allows client connection to
+// subscribe to channel until expireAt unix time
+func SubscriptionToken(secret, client, channel string, expireAt int64, info interface{}) (string, error) {
+	return sign(secret, &subscriptionClaims{
+		Client:  client,
+		Channel: channel,
+		Info:    info,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: expireAt,
+		},
+	})
+}
@@ -13,12 +13,37 @@ type AppCfg struct {
 		Enable bool `envconfig:"default=true"`
 	}
 
+	Session struct {
+		TTL string `envconfig:"default=24h"`
+	}
+
+	Search struct {
+		Backend string `envconfig:"default=elastic"`
+	}
+
 	Elastic struct {
-		DSN string `envconfig:"default=http://elastic:9200"`
+		DSN            string `envconfig:"default=http://elastic:9200"`
+		SuggestTimeout string `envconfig:"default=300ms"`
 	}
 
 	Centrifugo struct {
-		DSN string `envconfig:"default=http://centrifugo:8100"`
+		DSN                string `envconfig:"default=http://centrifugo:8100/api"`
+		APIKey             string `envconfig:"optional"`
+		Timeout            string `envconfig:"default=5s"`
+		TokenHMACSecretKey string `envconfig:"optional"`
+		TokenTTL           string `envconfig:"default=15m"`
+	}
+
+	Attachments struct {
+		MaxSize       int64 `envconfig:"default=20971520"`
+		ThumbnailSize int   `envconfig:"default=320"`
+	}
+
+	Documents struct {
+		// FontDir is relative to working directory, default works for run
+		// from repository root, container sets it to copied fonts
+		FontDir   string `envconfig:"default=internal/documents/fonts"`
+		VerifyURL string `envconfig:"default=http://localhost:9000/api/v1/documents"`
 	}
 
 	Mongo struct {
@@ -8,9 +8,15 @@ import (
 
 	centrifugov1 "github.com/sqsinformatique/rosseti-innovation-back/domains/centrifugo/v1"
 	innovationv1 "github.com/sqsinformatique/rosseti-innovation-back/domains/innovation/v1"
+	likev1 "github.com/sqsinformatique/rosseti-innovation-back/domains/like/v1"
+	moderationv1 "github.com/sqsinformatique/rosseti-innovation-back/domains/moderation/v1"
+	notificationv1 "github.com/sqsinformatique/rosseti-innovation-back/domains/notification/v1"
 	profilev1 "github.com/sqsinformatique/rosseti-innovation-back/domains/profile/v1"
+	searchv1 "github.com/sqsinformatique/rosseti-innovation-back/domains/search/v1"
 	sessionv1 "github.com/sqsinformatique/rosseti-innovation-back/domains/session/v1"
+	statisticsv1 "github.com/sqsinformatique/rosseti-innovation-back/domains/statistics/v1"
 	userv1 "github.com/sqsinformatique/rosseti-innovation-back/domains/user/v1"
+	"github.com/sqsinformatique/rosseti-innovation-back/internal/centrifugo"
 	"github.com/sqsinformatique/rosseti-innovation-back/internal/cfg"
 	"github.com/sqsinformatique/rosseti-innovation-back/internal/context"
 	"github.com/sqsinformatique/rosseti-innovation-back/internal/db"
@@ -56,6 +62,11 @@ func serveHandler(cmd *cobra.Command, args []string) {
 		log.Fatal().Err(err).Msg("Failed create ElasticDB")
 	}
 
+	Centrifugo, err := centrifugo.NewHTTPClient(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed create Centrifugo client")
+	}
+
 	// Initilize ORM
 	ORM, err := orm.NewORM("production", ctx)
 	if err != nil {
@@ -83,21 +94,46 @@ func serveHandler(cmd *cobra.Command, args []string) {
 		log.Fatal().Err(err).Msg("Failed create UserV1")
 	}
 
-	ProfileV1, err := profilev1.NewProfileV1(ctx, ORM, UserV1)
+	LikeV1, err := likev1.NewLikeV1(ctx, UserV1)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed create LikeV1")
+	}
+
+	NotificationV1, err := notificationv1.NewNotificationV1(ctx, UserV1, Centrifugo)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed create NotificationV1")
+	}
+
+	ModerationV1, err := moderationv1.NewModerationV1(ctx, UserV1, Centrifugo)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed create ModerationV1")
+	}
+
+	SearchV1, err := searchv1.NewSearchV1(ctx, UserV1)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed create SearchV1")
+	}
+
+	ProfileV1, err := profilev1.NewProfileV1(ctx, ORM, UserV1, SearchV1)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed create ProfileV1")
 	}
 
-	_, err = centrifugov1.NewCentrifugoV1(ctx, ORM, SessionV1)
+	CentrifugoV1, err := centrifugov1.NewCentrifugoV1(ctx, ORM, SessionV1, UserV1, LikeV1, NotificationV1, ModerationV1, SearchV1, Centrifugo)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed create CentrifugoV1")
 	}
 
-	_, err = innovationv1.NewInnovationV1(ctx, ProfileV1, ORM, UserV1)
+	_, err = innovationv1.NewInnovationV1(ctx, ProfileV1, ORM, UserV1, SearchV1, LikeV1, NotificationV1, CentrifugoV1)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed create InnovationV1")
 	}
 
+	_, err = statisticsv1.NewStatisticsV1(ctx, UserV1)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed create StatisticsV1")
+	}
+
 	// Start connect
 	if err := DB.Start(); err != nil {
 		log.Fatal().Err(err).Msg("Failed connect to DB")
@@ -107,8 +143,12 @@ func serveHandler(cmd *cobra.Command, args []string) {
 		log.Fatal().Err(err).Msg("Failed connect to MongoDB")
 	}
 
-	if err := Elastic.Start(); err != nil {
-		log.Fatal().Err(err).Msg("Failed connect to ElasticDB")
+	// Postgres search backend runs without elasticsearch node, suggest and
+	// global search are unavailable then
+	if config.Search.Backend == innovationv1.SearchBackendElastic {
+		if err := Elastic.Start(); err != nil {
+			log.Fatal().Err(err).Msg("Failed connect to ElasticDB")
+		}
 	}
 
 	// Start swagger
@@ -18,6 +18,9 @@ type Context struct {
 	Config      *cfg.AppCfg
 	HTTPServers map[string]*echo.Echo
 	HTTPGroups  map[string]*echo.Group
+
+	ElasticIndices  map[string]string
+	MongoMigrations []func() error
 }
 
 func NewContext() *Context {
@@ -60,6 +63,31 @@ func (ctx *Context) GetElasticDB() **elasticsearch.Client {
 	return ctx.ElasticDB
 }
 
+// RegisterElasticIndex registers index mapping, index will be created
+// on ElasticDB start if it doesn't exist
+func (ctx *Context) RegisterElasticIndex(name, mapping string) {
+	if ctx.ElasticIndices == nil {
+		ctx.ElasticIndices = make(map[string]string)
+	}
+
+	ctx.ElasticIndices[name] = mapping
+}
+
+func (ctx *Context) GetElasticIndices() map[string]string {
+	return ctx.ElasticIndices
+}
+
+// RegisterMongoMigration registers function which prepares collections,
+// migrations are run on MongoDB start in order of registration and start
+// fails on the first failed migration
+func (ctx *Context) RegisterMongoMigration(migration func() error) {
+	ctx.MongoMigrations = append(ctx.MongoMigrations, migration)
+}
+
+func (ctx *Context) GetMongoMigrations() []func() error {
+	return ctx.MongoMigrations
+}
+
 func (ctx *Context) RegisterHTTPServer(name string, srv *echo.Echo) {
 	if ctx.HTTPServers == nil {
 		ctx.HTTPServers = make(map[string]*echo.Echo)
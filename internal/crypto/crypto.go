@@ -11,6 +11,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"strings"
 )
 
 func HashString(data string) string {
@@ -90,3 +91,33 @@ func DataSign(data interface{}, key *rsa.PrivateKey) (string, error) {
 
 	return base64.StdEncoding.EncodeToString(signature), nil
 }
+
+func DataVerify(data interface{}, signature string, key *rsa.PublicKey) error {
+	d, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	sign, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return err
+	}
+
+	dataHashSum := sha256.Sum256(d)
+
+	return rsa.VerifyPSS(key, crypto.SHA256, dataHashSum[:], sign, nil)
+}
+
+// KeyFingerprint is a SHA-256 of public key in hex pairs, it identifies key
+// in printed documents
+func KeyFingerprint(publicKey string) string {
+	uDec, _ := base64.StdEncoding.DecodeString(publicKey)
+	sum := sha256.Sum256(uDec)
+
+	pairs := make([]string, 0, len(sum))
+	for _, b := range sum {
+		pairs = append(pairs, hex.EncodeToString([]byte{b}))
+	}
+
+	return strings.ToUpper(strings.Join(pairs, ":"))
+}
@@ -0,0 +1,25 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS production.notifications (
+    id serial PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    type character varying(64) NOT NULL,
+    title character varying(512) DEFAULT '',
+    data jsonb,
+    read_at timestamp with time zone,
+    created_at timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS notifications_user_idx ON production.notifications (user_id, id DESC);
+CREATE INDEX IF NOT EXISTS notifications_unread_idx ON production.notifications (user_id) WHERE read_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS production.notification_preferences (
+    user_id INTEGER NOT NULL,
+    type character varying(64) NOT NULL,
+    enabled boolean NOT NULL DEFAULT true,
+    updated_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT notification_preferences_user_type_unique UNIQUE (user_id, type)
+);
+
+-- +goose Down
+DROP TABLE production.notification_preferences;
+DROP TABLE production.notifications;
@@ -0,0 +1,50 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS production.chat_sanctions (
+    id serial PRIMARY KEY,
+    theme_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    kind character varying(16) NOT NULL,
+    reason character varying(1024) DEFAULT '',
+    until timestamp with time zone NOT NULL,
+    created_by INTEGER NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    revoked_at timestamp with time zone
+);
+
+CREATE INDEX IF NOT EXISTS chat_sanctions_active_idx ON production.chat_sanctions (theme_id, user_id, until) WHERE revoked_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS production.theme_locks (
+    theme_id INTEGER PRIMARY KEY,
+    reason character varying(1024) DEFAULT '',
+    locked_by INTEGER NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS production.stop_words (
+    id serial PRIMARY KEY,
+    word character varying(255) NOT NULL,
+    action character varying(16) NOT NULL,
+    created_by INTEGER NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT stop_words_word_unique UNIQUE (word)
+);
+
+CREATE TABLE IF NOT EXISTS production.moderation_log (
+    id serial PRIMARY KEY,
+    moderator_id INTEGER NOT NULL,
+    action character varying(64) NOT NULL,
+    chat_id INTEGER NOT NULL DEFAULT 0,
+    user_id INTEGER NOT NULL DEFAULT 0,
+    message_id INTEGER NOT NULL DEFAULT 0,
+    details jsonb,
+    created_at timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS moderation_log_chat_idx ON production.moderation_log (chat_id, id DESC);
+CREATE INDEX IF NOT EXISTS moderation_log_user_idx ON production.moderation_log (user_id, id DESC);
+
+-- +goose Down
+DROP TABLE production.moderation_log;
+DROP TABLE production.stop_words;
+DROP TABLE production.theme_locks;
+DROP TABLE production.chat_sanctions;
@@ -0,0 +1,21 @@
+-- +goose Up
+ALTER TABLE production.direction ADD COLUMN IF NOT EXISTS position INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE production.direction ADD COLUMN IF NOT EXISTS archived_at timestamp with time zone;
+
+UPDATE production.direction SET position = id;
+
+ALTER TABLE production.theme ADD COLUMN IF NOT EXISTS pinned boolean NOT NULL DEFAULT false;
+ALTER TABLE production.theme ADD COLUMN IF NOT EXISTS closed_at timestamp with time zone;
+ALTER TABLE production.theme ADD COLUMN IF NOT EXISTS archived_at timestamp with time zone;
+
+CREATE INDEX IF NOT EXISTS theme_direction_idx ON production.theme (direction) WHERE deleted_at IS NULL;
+
+-- +goose Down
+DROP INDEX production.theme_direction_idx;
+
+ALTER TABLE production.theme DROP COLUMN archived_at;
+ALTER TABLE production.theme DROP COLUMN closed_at;
+ALTER TABLE production.theme DROP COLUMN pinned;
+
+ALTER TABLE production.direction DROP COLUMN archived_at;
+ALTER TABLE production.direction DROP COLUMN position;
@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE production.theme ADD COLUMN IF NOT EXISTS innovation_id INTEGER;
+ALTER TABLE production.innovation ADD COLUMN IF NOT EXISTS theme_id INTEGER;
+
+CREATE UNIQUE INDEX IF NOT EXISTS innovation_theme_unique ON production.innovation (theme_id) WHERE theme_id IS NOT NULL;
+
+-- +goose Down
+DROP INDEX production.innovation_theme_unique;
+
+ALTER TABLE production.innovation DROP COLUMN theme_id;
+ALTER TABLE production.theme DROP COLUMN innovation_id;
@@ -0,0 +1,37 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS production.innovation_states (
+    id serial PRIMARY KEY,
+    innovation_id INTEGER NOT NULL,
+    state character varying(255) NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS innovation_states_innovation_idx ON production.innovation_states (innovation_id, created_at);
+CREATE INDEX IF NOT EXISTS innovation_created_at_idx ON production.innovation (created_at);
+
+-- State changes are recorded by trigger, so every code path updating
+-- innovation keeps the history
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION production.innovation_state_changed()
+RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' OR NEW.state IS DISTINCT FROM OLD.state THEN
+        INSERT INTO production.innovation_states (innovation_id, state) VALUES (NEW.id, coalesce(NEW.state, ''));
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER innovation_state_changed AFTER INSERT OR UPDATE OF state ON production.innovation
+    FOR EACH ROW EXECUTE PROCEDURE production.innovation_state_changed();
+
+-- Current state of existing innovations is the only known one
+INSERT INTO production.innovation_states (innovation_id, state, created_at)
+SELECT id, coalesce(state, ''), updated_at FROM production.innovation;
+
+-- +goose Down
+DROP TRIGGER innovation_state_changed ON production.innovation;
+DROP FUNCTION production.innovation_state_changed();
+DROP INDEX production.innovation_created_at_idx;
+DROP TABLE production.innovation_states;
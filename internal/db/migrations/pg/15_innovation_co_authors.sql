@@ -0,0 +1,13 @@
+-- +goose Up
+-- Co-authors of innovation, id is an innovation id like in experts
+CREATE TABLE IF NOT EXISTS production.innovation_co_authors (
+    id INTEGER NOT NULL,
+    author_id INTEGER NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    updated_at timestamp with time zone NOT NULL DEFAULT now(),
+    deleted_at timestamp with time zone,
+    CONSTRAINT innovation_co_authors_pair_unique UNIQUE (id, author_id)
+);
+
+-- +goose Down
+DROP TABLE production.innovation_co_authors;
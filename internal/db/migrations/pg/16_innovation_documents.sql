@@ -0,0 +1,26 @@
+-- +goose Up
+-- Electronic signatures of innovation application by its authors
+CREATE TABLE IF NOT EXISTS production.innovation_signatures (
+    id serial PRIMARY KEY,
+    innovation_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    signature character varying(2048) NOT NULL,
+    data_hash character varying(64) NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT innovation_signatures_pair_unique UNIQUE (innovation_id, user_id)
+);
+
+-- Issued documents, code is a part of public verification URL
+CREATE TABLE IF NOT EXISTS production.innovation_documents (
+    id serial PRIMARY KEY,
+    innovation_id INTEGER NOT NULL,
+    kind character varying(32) NOT NULL,
+    code character varying(64) NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT innovation_documents_kind_unique UNIQUE (innovation_id, kind),
+    CONSTRAINT innovation_documents_code_unique UNIQUE (code)
+);
+
+-- +goose Down
+DROP TABLE production.innovation_documents;
+DROP TABLE production.innovation_signatures;
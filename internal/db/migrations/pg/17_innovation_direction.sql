@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE production.innovation ADD COLUMN IF NOT EXISTS direction INTEGER;
+
+-- Direction was kept in meta before
+UPDATE production.innovation SET direction = (meta->>'direction')::int
+WHERE direction IS NULL AND meta->>'direction' ~ '^[0-9]+$';
+
+CREATE INDEX IF NOT EXISTS innovation_direction_idx ON production.innovation (direction) WHERE deleted_at IS NULL;
+
+-- +goose Down
+DROP INDEX production.innovation_direction_idx;
+
+ALTER TABLE production.innovation DROP COLUMN direction;
@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS production.innovation_duplicates (
+    id serial PRIMARY KEY,
+    innovation_id INTEGER NOT NULL,
+    duplicate_id INTEGER NOT NULL,
+    moderator_id INTEGER NOT NULL,
+    score double precision NOT NULL DEFAULT 0,
+    meta jsonb,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    updated_at timestamp with time zone NOT NULL DEFAULT now(),
+    deleted_at timestamp with time zone,
+    CONSTRAINT innovation_duplicates_pair_unique UNIQUE (innovation_id, duplicate_id)
+);
+
+CREATE INDEX IF NOT EXISTS innovation_duplicates_duplicate_id_idx ON production.innovation_duplicates (duplicate_id);
+
+-- +goose Down
+DROP TABLE production.innovation_duplicates;
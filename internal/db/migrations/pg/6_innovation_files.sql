@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS production.innovation_files (
+    id INTEGER NOT NULL,
+    file_id character varying(255) DEFAULT '',
+    file_name character varying(255) DEFAULT '',
+    content text DEFAULT '',
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    updated_at timestamp with time zone NOT NULL DEFAULT now(),
+    deleted_at timestamp with time zone,
+    CONSTRAINT innovation_files_unique UNIQUE (id, file_name)
+);
+
+-- +goose Down
+DROP TABLE production.innovation_files;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION production.innovation_document(title text, tags text, problem text, descriptions text, effect text)
+RETURNS tsvector AS $$
+    SELECT setweight(to_tsvector('russian', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('russian', coalesce(tags, '')), 'B') ||
+        setweight(to_tsvector('russian', coalesce(problem, '') || ' ' || coalesce(descriptions, '') || ' ' || coalesce(effect, '')), 'C')
+$$ LANGUAGE sql IMMUTABLE;
+-- +goose StatementEnd
+
+CREATE INDEX IF NOT EXISTS innovation_document_idx ON production.innovation
+    USING GIN (production.innovation_document(title, tags, problem, descriptions, effect));
+CREATE INDEX IF NOT EXISTS innovation_title_idx ON production.innovation
+    USING GIN (to_tsvector('russian', coalesce(title, '')));
+CREATE INDEX IF NOT EXISTS innovation_files_content_idx ON production.innovation_files
+    USING GIN (to_tsvector('russian', coalesce(content, '')));
+
+-- +goose Down
+DROP INDEX production.innovation_files_content_idx;
+DROP INDEX production.innovation_title_idx;
+DROP INDEX production.innovation_document_idx;
+DROP FUNCTION production.innovation_document(text, text, text, text, text);
@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS production.theme_activity (
+    theme_id INTEGER PRIMARY KEY,
+    last_message_at timestamp with time zone NOT NULL DEFAULT now(),
+    message_count INTEGER NOT NULL DEFAULT 0,
+    participants INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS theme_activity_last_message_idx ON production.theme_activity (last_message_at DESC, theme_id DESC);
+
+CREATE TABLE IF NOT EXISTS production.theme_participants (
+    theme_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT theme_participants_pair_unique UNIQUE (theme_id, user_id)
+);
+
+-- +goose Down
+DROP TABLE production.theme_participants;
+DROP TABLE production.theme_activity;
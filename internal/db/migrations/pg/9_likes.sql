@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS production.likes (
+    id serial PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    target_type character varying(32) NOT NULL,
+    target_id INTEGER NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT likes_user_target_unique UNIQUE (user_id, target_type, target_id)
+);
+
+CREATE INDEX IF NOT EXISTS likes_target_idx ON production.likes (target_type, target_id);
+
+ALTER TABLE production.innovation ADD COLUMN IF NOT EXISTS like_counter INTEGER NOT NULL DEFAULT 0;
+
+-- Theme likes could not be stored before, counters must match empty likes table
+UPDATE production.theme SET like_counter = 0;
+
+-- +goose Down
+ALTER TABLE production.innovation DROP COLUMN like_counter;
+DROP TABLE production.likes;
@@ -0,0 +1,150 @@
+// Package documents renders printable innovation documents to PDF. Layout
+// of document is a template of blocks, text of every block is a
+// text/template executed with document data.
+package documents
+
+import (
+	"text/template"
+	"time"
+)
+
+// Block styles
+const (
+	// StyleAddressee is a right aligned text at the top of the page
+	StyleAddressee = "addressee"
+	// StyleTitle is a centered bold document name
+	StyleTitle = "title"
+	// StyleSubtitle is a centered text under the title
+	StyleSubtitle = "subtitle"
+	// StyleHeading is a bold heading of section
+	StyleHeading = "heading"
+	// StyleText is a justified paragraph
+	StyleText = "text"
+	// StyleField is a bold label followed by value
+	StyleField = "field"
+	// StyleAuthors is a table of authors with signature marks
+	StyleAuthors = "authors"
+	// StyleSignatures lists electronic signatures of authors
+	StyleSignatures = "signatures"
+)
+
+// Person is an author of innovation
+type Person struct {
+	Name     string
+	Position string
+	Company  string
+	// Signed is set if electronic signature of person is valid
+	Signed bool
+}
+
+// Signature is a stored electronic signature of document data
+type Signature struct {
+	Name           string
+	SignedAt       time.Time
+	Value          string
+	KeyFingerprint string
+	Valid          bool
+}
+
+// Data fills document template
+type Data struct {
+	Number       int
+	Title        string
+	Direction    string
+	Problem      string
+	Description  string
+	Effect       string
+	Company      string
+	Authors      []*Person
+	Signatures   []*Signature
+	SubmittedAt  time.Time
+	RecognizedAt time.Time
+	IssuedAt     time.Time
+	// VerifyURL is encoded to QR code, document is verified by it
+	VerifyURL string
+}
+
+// Block is a part of document layout. Authors and signatures are drawn
+// from data, other blocks are skipped if their text is empty.
+type Block struct {
+	Style string
+	Label string
+	Text  string
+}
+
+// Template is a layout of document
+type Template struct {
+	Name   string
+	Blocks []*Block
+
+	texts []*template.Template
+}
+
+// NewTemplate parses texts of blocks, it panics on error like
+// template.Must
+func NewTemplate(name string, blocks ...*Block) *Template {
+	t := &Template{Name: name, Blocks: blocks}
+
+	for _, block := range blocks {
+		t.texts = append(t.texts, template.Must(template.New(name).Funcs(funcs).Parse(block.Text)))
+	}
+
+	return t
+}
+
+var funcs = template.FuncMap{
+	"date": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+
+		return t.Format("02.01.2006")
+	},
+	"names": func(persons []*Person) []string {
+		names := make([]string, 0, len(persons))
+		for _, person := range persons {
+			names = append(names, person.Name)
+		}
+
+		return names
+	},
+}
+
+// Application is an application for rationalization proposal
+var Application = NewTemplate("application",
+	&Block{Style: StyleAddressee, Text: `В бюро рационализации и изобретательства
+{{- if .Company}}
+{{.Company}}{{end}}`},
+	&Block{Style: StyleTitle, Text: `ЗАЯВЛЕНИЕ`},
+	&Block{Style: StyleSubtitle, Text: `на рационализаторское предложение № {{.Number}} от {{date .SubmittedAt}}`},
+	&Block{Style: StyleText, Text: `Прошу признать предложение «{{.Title}}» рационализаторским и принять его к использованию. ` +
+		`Предложение является новым и полезным для организации, сведения о нём достоверны.`},
+	&Block{Style: StyleField, Label: "Наименование предложения", Text: `{{.Title}}`},
+	&Block{Style: StyleField, Label: "Направление", Text: `{{.Direction}}`},
+	&Block{Style: StyleField, Label: "Описание проблемы", Text: `{{.Problem}}`},
+	&Block{Style: StyleField, Label: "Предлагаемое решение", Text: `{{.Description}}`},
+	&Block{Style: StyleField, Label: "Ожидаемый эффект", Text: `{{.Effect}}`},
+	&Block{Style: StyleHeading, Text: `Авторы предложения`},
+	&Block{Style: StyleText, Text: `Творческий вклад авторов считается равным, если иное не указано в заявлении.`},
+	&Block{Style: StyleAuthors},
+	&Block{Style: StyleHeading, Text: `Электронные подписи`},
+	&Block{Style: StyleSignatures},
+)
+
+// Certificate is a certificate of authorship of recognized proposal
+var Certificate = NewTemplate("certificate",
+	&Block{Style: StyleAddressee, Text: `{{.Company}}`},
+	&Block{Style: StyleTitle, Text: `УДОСТОВЕРЕНИЕ`},
+	&Block{Style: StyleSubtitle, Text: `на рационализаторское предложение № {{.Number}}`},
+	&Block{Style: StyleText, Text: `Настоящее удостоверение выдано` +
+		`{{range $i, $name := names .Authors}}{{if $i}},{{end}} {{$name}}{{end}} ` +
+		`в том, что {{if gt (len .Authors) 1}}ими{{else}}им{{end}} подано предложение «{{.Title}}», ` +
+		`которое {{date .RecognizedAt}} признано рационализаторским.`},
+	&Block{Style: StyleField, Label: "Направление", Text: `{{.Direction}}`},
+	&Block{Style: StyleField, Label: "Дата подачи заявления", Text: `{{date .SubmittedAt}}`},
+	&Block{Style: StyleField, Label: "Дата признания", Text: `{{date .RecognizedAt}}`},
+	&Block{Style: StyleField, Label: "Экономический эффект", Text: `{{.Effect}}`},
+	&Block{Style: StyleHeading, Text: `Авторы предложения`},
+	&Block{Style: StyleAuthors},
+	&Block{Style: StyleText, Text: `Дата выдачи {{date .IssuedAt}}`},
+)
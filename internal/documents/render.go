@@ -0,0 +1,258 @@
+package documents
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// Font files are looked up in font directory, DejaVu fonts cover
+// cyrillic
+const (
+	fontRegular = "DejaVuSerif.ttf"
+	fontBold    = "DejaVuSerif-Bold.ttf"
+	fontMono    = "DejaVuSansMono.ttf"
+)
+
+const (
+	familySerif = "serif"
+	familyMono  = "mono"
+
+	margin     = 20.0
+	lineHeight = 5.5
+	qrSize     = 30.0
+	qrPixels   = 256
+)
+
+// Renderer draws documents with fonts loaded once
+type Renderer struct {
+	regular []byte
+	bold    []byte
+	mono    []byte
+}
+
+// NewRenderer loads fonts from fontDir
+func NewRenderer(fontDir string) (*Renderer, error) {
+	r := &Renderer{}
+
+	fonts := []struct {
+		name string
+		data *[]byte
+	}{
+		{fontRegular, &r.regular},
+		{fontBold, &r.bold},
+		{fontMono, &r.mono},
+	}
+
+	for _, font := range fonts {
+		data, err := ioutil.ReadFile(filepath.Join(fontDir, font.name))
+		if err != nil {
+			return nil, err
+		}
+
+		*font.data = data
+	}
+
+	return r, nil
+}
+
+// Render writes document of template filled with data as PDF to w
+func (r *Renderer) Render(w io.Writer, t *Template, data *Data) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes(familySerif, "", r.regular)
+	pdf.AddUTF8FontFromBytes(familySerif, "B", r.bold)
+	pdf.AddUTF8FontFromBytes(familyMono, "", r.mono)
+	pdf.SetMargins(margin, margin, margin)
+	pdf.SetAutoPageBreak(true, margin+qrSize)
+	pdf.SetTitle(t.Name+" "+data.Title, true)
+	pdf.SetCreator("rosseti-innovation-back", true)
+	pdf.SetCreationDate(data.IssuedAt)
+	pdf.AliasNbPages("")
+
+	if data.VerifyURL != "" {
+		code, err := qrcode.Encode(data.VerifyURL, qrcode.Medium, qrPixels)
+		if err != nil {
+			return err
+		}
+
+		pdf.RegisterImageOptionsReader("qr", gofpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(code))
+	}
+
+	pdf.SetFooterFunc(func() { r.footer(pdf, data) })
+	pdf.AddPage()
+
+	for i, block := range t.Blocks {
+		var text strings.Builder
+
+		err := t.texts[i].Execute(&text, data)
+		if err != nil {
+			return err
+		}
+
+		r.block(pdf, block, strings.TrimSpace(text.String()), data)
+	}
+
+	if err := pdf.Error(); err != nil {
+		return err
+	}
+
+	return pdf.Output(w)
+}
+
+func (r *Renderer) block(pdf *gofpdf.Fpdf, block *Block, text string, data *Data) {
+	width, _ := pdf.GetPageSize()
+	contentWidth := width - 2*margin
+
+	switch block.Style {
+	case StyleAuthors:
+		r.authors(pdf, data.Authors, contentWidth)
+		return
+	case StyleSignatures:
+		r.signatures(pdf, data.Signatures, contentWidth)
+		return
+	}
+
+	if text == "" {
+		return
+	}
+
+	switch block.Style {
+	case StyleAddressee:
+		pdf.SetFont(familySerif, "", 11)
+		pdf.SetX(width / 2)
+		pdf.MultiCell(width/2-margin, lineHeight, text, "", "L", false)
+		pdf.Ln(lineHeight * 2)
+	case StyleTitle:
+		pdf.SetFont(familySerif, "B", 16)
+		pdf.MultiCell(contentWidth, lineHeight*1.5, text, "", "C", false)
+	case StyleSubtitle:
+		pdf.SetFont(familySerif, "", 12)
+		pdf.MultiCell(contentWidth, lineHeight, text, "", "C", false)
+		pdf.Ln(lineHeight)
+	case StyleHeading:
+		pdf.Ln(lineHeight / 2)
+		pdf.SetFont(familySerif, "B", 12)
+		pdf.MultiCell(contentWidth, lineHeight*1.2, text, "", "L", false)
+	case StyleField:
+		pdf.SetFont(familySerif, "B", 11)
+		pdf.MultiCell(contentWidth, lineHeight, block.Label+":", "", "L", false)
+		pdf.SetFont(familySerif, "", 11)
+		pdf.MultiCell(contentWidth, lineHeight, text, "", "J", false)
+		pdf.Ln(lineHeight / 2)
+	default:
+		pdf.SetFont(familySerif, "", 11)
+		pdf.MultiCell(contentWidth, lineHeight, text, "", "J", false)
+		pdf.Ln(lineHeight / 2)
+	}
+}
+
+// authors draws table of authors, signature column tells whether author
+// signed the application electronically
+func (r *Renderer) authors(pdf *gofpdf.Fpdf, authors []*Person, contentWidth float64) {
+	columns := []struct {
+		title string
+		width float64
+	}{
+		{"№", 10},
+		{"ФИО", 55},
+		{"Должность", 40},
+		{"Организация", contentWidth - 135},
+		{"Подпись", 30},
+	}
+
+	pdf.SetFont(familySerif, "B", 10)
+
+	for _, column := range columns {
+		pdf.CellFormat(column.width, lineHeight+1, column.title, "1", 0, "C", false, 0, "")
+	}
+
+	pdf.Ln(-1)
+	pdf.SetFont(familySerif, "", 10)
+
+	for i, author := range authors {
+		signed := ""
+		if author.Signed {
+			signed = "подписано ЭП"
+		}
+
+		cells := []string{strconv.Itoa(i + 1), author.Name, author.Position, author.Company, signed}
+
+		// Row height fits the longest cell
+		lines := 1
+		for j, cell := range cells {
+			if n := len(pdf.SplitText(cell, columns[j].width-2)); n > lines {
+				lines = n
+			}
+		}
+
+		height := float64(lines) * lineHeight
+		_, pageHeight := pdf.GetPageSize()
+
+		if pdf.GetY()+height > pageHeight-margin-qrSize {
+			pdf.AddPage()
+		}
+
+		x, y := pdf.GetX(), pdf.GetY()
+
+		for j, cell := range cells {
+			pdf.Rect(x, y, columns[j].width, height, "D")
+			pdf.SetXY(x, y)
+			pdf.MultiCell(columns[j].width, lineHeight, cell, "", "L", false)
+			x += columns[j].width
+		}
+
+		pdf.SetXY(margin, y+height)
+	}
+
+	pdf.Ln(lineHeight)
+}
+
+// signatures prints stored signatures with fingerprints of public keys,
+// signature is valid if it matches current data of document
+func (r *Renderer) signatures(pdf *gofpdf.Fpdf, signatures []*Signature, contentWidth float64) {
+	if len(signatures) == 0 {
+		pdf.SetFont(familySerif, "", 11)
+		pdf.MultiCell(contentWidth, lineHeight, "Заявление не подписано электронной подписью.", "", "L", false)
+
+		return
+	}
+
+	for _, signature := range signatures {
+		status := "действительна"
+		if !signature.Valid {
+			status = "не соответствует текущей редакции предложения"
+		}
+
+		pdf.SetFont(familySerif, "B", 10)
+		pdf.MultiCell(contentWidth, lineHeight, signature.Name+", "+signature.SignedAt.Format("02.01.2006 15:04"), "", "L", false)
+		pdf.SetFont(familySerif, "", 10)
+		pdf.MultiCell(contentWidth, lineHeight, "Подпись "+status+". Отпечаток ключа: "+signature.KeyFingerprint, "", "L", false)
+		pdf.SetFont(familyMono, "", 7)
+		pdf.MultiCell(contentWidth, 3.5, signature.Value, "", "L", false)
+		pdf.Ln(lineHeight / 2)
+	}
+}
+
+// footer draws QR code of verification URL and page number
+func (r *Renderer) footer(pdf *gofpdf.Fpdf, data *Data) {
+	width, height := pdf.GetPageSize()
+	top := height - margin - qrSize + 5
+
+	if data.VerifyURL != "" {
+		pdf.ImageOptions("qr", width-margin-qrSize+5, top, qrSize-5, qrSize-5, false, gofpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+
+		pdf.SetXY(margin, top+5)
+		pdf.SetFont(familySerif, "", 8)
+		pdf.MultiCell(width-2*margin-qrSize, 4, "Подлинность документа можно проверить по QR-коду или по адресу "+data.VerifyURL, "", "L", false)
+	}
+
+	pdf.SetXY(margin, height-margin+2)
+	pdf.SetFont(familySerif, "", 8)
+	pdf.CellFormat(width-2*margin, 4, "Страница "+strconv.Itoa(pdf.PageNo())+" из {nb}", "", 0, "C", false, 0, "")
+}
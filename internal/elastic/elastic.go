@@ -1,9 +1,17 @@
 package elastic
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
 	"github.com/rs/zerolog"
 	"github.com/sqsinformatique/rosseti-innovation-back/internal/cfg"
 	intctx "github.com/sqsinformatique/rosseti-innovation-back/internal/context"
@@ -11,10 +19,16 @@ import (
 
 type empty struct{}
 
+var (
+	ErrConnNotEstablished = errors.New("elasticsearch connection not established")
+	errMappingConflict    = errors.New("mapping conflicts with existing index")
+)
+
 type DB struct {
 	log    zerolog.Logger
 	conn   *elasticsearch.Client
 	config *cfg.AppCfg
+	ctx    *intctx.Context
 }
 
 // Initialize initialize database
@@ -25,6 +39,7 @@ func NewElasticDB(ctx *intctx.Context) (*DB, error) {
 	db := &DB{}
 	db.config = ctx.Config
 	db.log = ctx.GetPackageLogger(empty{})
+	db.ctx = ctx
 	ctx.RegisterElasticDB(&db.conn)
 
 	return db, nil
@@ -43,5 +58,214 @@ func (db *DB) Start() error {
 
 	db.conn = es
 
+	// Search doesn't work with index of stale mapping, so it stops startup
+	for name, mapping := range db.ctx.GetElasticIndices() {
+		err = db.ensureIndex(name, mapping)
+		if err != nil {
+			return fmt.Errorf("ensure index %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// ensureIndex creates index with mapping if it doesn't exist
+func (db *DB) ensureIndex(name, mapping string) error {
+	res, err := esapi.IndicesExistsRequest{Index: []string{name}}.Do(context.Background(), db.conn)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode == http.StatusOK {
+		err = db.putMapping(name, mapping)
+		if errors.Is(err, errMappingConflict) {
+			return db.migrateIndex(name, mapping)
+		}
+
+		return err
+	}
+
+	res, err = esapi.IndicesCreateRequest{Index: name, Body: strings.NewReader(mapping)}.Do(context.Background(), db.conn)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("[%s] failed create index %s", res.Status(), name)
+	}
+
+	db.log.Info().Msgf("Index %s created", name)
+
+	return nil
+}
+
+// putMapping adds new fields from mapping to existing index. Types of
+// already mapped fields can't be changed, errMappingConflict is returned
+// then.
+func (db *DB) putMapping(name, mapping string) error {
+	var index struct {
+		Mappings json.RawMessage `json:"mappings"`
+	}
+
+	err := json.Unmarshal([]byte(mapping), &index)
+	if err != nil {
+		return err
+	}
+
+	if len(index.Mappings) == 0 {
+		return nil
+	}
+
+	res, err := esapi.IndicesPutMappingRequest{Index: []string{name}, Body: bytes.NewReader(index.Mappings)}.Do(context.Background(), db.conn)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusBadRequest {
+		return fmt.Errorf("%w: [%s] index %s", errMappingConflict, res.Status(), name)
+	}
+
+	if res.IsError() {
+		return fmt.Errorf("[%s] failed put mapping to index %s", res.Status(), name)
+	}
+
+	return nil
+}
+
+// migrateIndex copies documents of index to new index with mapping and
+// replaces index by alias of the new one. Documents written to the old
+// index during copy are lost, so it runs on startup only.
+func (db *DB) migrateIndex(name, mapping string) error {
+	current, err := db.concreteIndices(name)
+	if err != nil {
+		return err
+	}
+
+	target := fmt.Sprintf("%s_%d", name, time.Now().Unix())
+
+	res, err := esapi.IndicesCreateRequest{Index: target, Body: strings.NewReader(mapping)}.Do(context.Background(), db.conn)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("[%s] failed create index %s", res.Status(), target)
+	}
+
+	err = db.copyIndex(name, target)
+	if err == nil {
+		err = db.swapAlias(name, target, current)
+	}
+
+	if err != nil {
+		res, deleteErr := esapi.IndicesDeleteRequest{Index: []string{target}}.Do(context.Background(), db.conn)
+		if deleteErr == nil {
+			res.Body.Close()
+		}
+
+		return err
+	}
+
+	db.log.Info().Msgf("Index %s migrated to %s", name, target)
+
+	return nil
+}
+
+// concreteIndices returns indices behind name, name may be an alias left
+// by previous migration
+func (db *DB) concreteIndices(name string) ([]string, error) {
+	res, err := esapi.IndicesGetRequest{Index: []string{name}}.Do(context.Background(), db.conn)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("[%s] failed get index %s", res.Status(), name)
+	}
+
+	indices := map[string]json.RawMessage{}
+
+	err = json.NewDecoder(res.Body).Decode(&indices)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(indices))
+	for index := range indices {
+		names = append(names, index)
+	}
+
+	return names, nil
+}
+
+// copyIndex copies all documents of source to target
+func (db *DB) copyIndex(source, target string) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"source": map[string]interface{}{"index": source},
+		"dest":   map[string]interface{}{"index": target},
+	})
+	if err != nil {
+		return err
+	}
+
+	wait, refresh := true, true
+
+	res, err := esapi.ReindexRequest{Body: bytes.NewReader(body), WaitForCompletion: &wait, Refresh: &refresh}.Do(context.Background(), db.conn)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("[%s] failed copy index %s to %s", res.Status(), source, target)
+	}
+
+	var result struct {
+		Failures []json.RawMessage `json:"failures"`
+	}
+
+	err = json.NewDecoder(res.Body).Decode(&result)
+	if err != nil {
+		return err
+	}
+
+	if len(result.Failures) > 0 {
+		return fmt.Errorf("failed copy %d documents of index %s to %s: %s", len(result.Failures), source, target, result.Failures[0])
+	}
+
+	return nil
+}
+
+// swapAlias points alias name to target and removes indices behind name in
+// one request, so the name is never missing
+func (db *DB) swapAlias(name, target string, current []string) error {
+	actions := []interface{}{
+		map[string]interface{}{"add": map[string]interface{}{"index": target, "alias": name}},
+	}
+
+	for _, index := range current {
+		actions = append(actions, map[string]interface{}{"remove_index": map[string]interface{}{"index": index}})
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"actions": actions})
+	if err != nil {
+		return err
+	}
+
+	res, err := esapi.IndicesUpdateAliasesRequest{Body: bytes.NewReader(body)}.Do(context.Background(), db.conn)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("[%s] failed point alias %s to %s", res.Status(), name, target)
+	}
+
 	return nil
 }
@@ -88,3 +88,8 @@ func InternalServerError(err error) ErrorAnsw {
 func PreconditionFailed(err error) ErrorAnsw {
 	return NewErrorAnsw(http.StatusPreconditionFailed, "precondition failed", err)
 }
+
+// NotImplemented return err 501
+func NotImplemented(err error) ErrorAnsw {
+	return NewErrorAnsw(http.StatusNotImplemented, "not implemented", err)
+}
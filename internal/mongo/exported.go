@@ -3,6 +3,7 @@ package mongo
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -19,6 +20,7 @@ type DB struct {
 	conn   *mongo.Client
 	config *cfg.AppCfg
 	cancel context.CancelFunc
+	ctx    *intctx.Context
 }
 
 // Initialize initialize database
@@ -29,6 +31,7 @@ func NewMongoDB(ctx *intctx.Context) (*DB, error) {
 	db := &DB{}
 	db.config = ctx.Config
 	db.log = ctx.GetPackageLogger(empty{})
+	db.ctx = ctx
 	ctx.RegisterMongoDB(&db.conn)
 
 	return db, nil
@@ -48,6 +51,15 @@ func (db *DB) Start() error {
 	db.conn = dbConn
 	db.cancel = cancel
 
+	// Domains rely on collections and indexes prepared by migrations, so
+	// service doesn't start without them
+	for _, migration := range db.ctx.GetMongoMigrations() {
+		err = migration()
+		if err != nil {
+			return fmt.Errorf("migrate Mongo database: %w", err)
+		}
+	}
+
 	return nil
 }
 
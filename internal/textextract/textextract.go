@@ -0,0 +1,205 @@
+package textextract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// MaxTextLength limits size of extracted text
+const MaxTextLength = 1 << 20
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported document format")
+	ErrBadDocument       = errors.New("bad document structure")
+)
+
+// Supported reports whether text can be extracted from file with fileName
+func Supported(fileName string) bool {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".txt", ".docx", ".odt":
+		return true
+	}
+
+	return false
+}
+
+// Text extracts plain text from document. Format is detected by file extension.
+func Text(fileName string, r io.ReaderAt, size int64) (string, error) {
+	var (
+		text string
+		err  error
+	)
+
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".txt":
+		text, err = plainText(io.NewSectionReader(r, 0, size))
+	case ".docx":
+		text, err = zipXMLText(r, size, "word/document.xml", docxHandler)
+	case ".odt":
+		text, err = zipXMLText(r, size, "content.xml", odtHandler)
+	default:
+		return "", ErrUnsupportedFormat
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return truncate(strings.TrimSpace(text)), nil
+}
+
+func truncate(text string) string {
+	if len(text) <= MaxTextLength {
+		return text
+	}
+
+	text = text[:MaxTextLength]
+	for !utf8.ValidString(text) {
+		text = text[:len(text)-1]
+	}
+
+	return text
+}
+
+// plainText reads UTF-8 text, non UTF-8 text is treated as Windows-1251
+func plainText(r io.Reader) (string, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, MaxTextLength))
+	if err != nil {
+		return "", err
+	}
+
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if utf8.Valid(data) {
+		return string(data), nil
+	}
+
+	decoded, err := charmap.Windows1251.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", err
+	}
+
+	return string(decoded), nil
+}
+
+// xmlHandler reacts on elements of document XML, it returns text which
+// should be written for element and whether character data inside the
+// element is document text
+type xmlHandler func(name xml.Name, start bool) (text string, isText bool)
+
+func docxHandler(name xml.Name, start bool) (string, bool) {
+	switch name.Local {
+	case "t":
+		return "", true
+	case "p":
+		if !start {
+			return "\n", false
+		}
+	case "tab":
+		if start {
+			return "\t", false
+		}
+	case "br", "cr":
+		if start {
+			return "\n", false
+		}
+	}
+
+	return "", false
+}
+
+func odtHandler(name xml.Name, start bool) (string, bool) {
+	switch name.Local {
+	case "p", "h":
+		if !start {
+			return "\n", true
+		}
+		return "", true
+	case "span", "a":
+		return "", true
+	case "s":
+		if start {
+			return " ", false
+		}
+	case "tab":
+		if start {
+			return "\t", false
+		}
+	case "line-break":
+		if start {
+			return "\n", false
+		}
+	}
+
+	return "", false
+}
+
+func zipXMLText(r io.ReaderAt, size int64, entry string, handler xmlHandler) (string, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return "", err
+	}
+
+	for _, file := range archive.File {
+		if file.Name != entry {
+			continue
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return "", err
+		}
+		defer rc.Close()
+
+		return xmlText(rc, handler)
+	}
+
+	return "", ErrBadDocument
+}
+
+func xmlText(r io.Reader, handler xmlHandler) (string, error) {
+	var (
+		b     strings.Builder
+		stack []bool
+	)
+
+	decoder := xml.NewDecoder(r)
+
+	for b.Len() < MaxTextLength {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return "", err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			text, isText := handler(t.Name, true)
+			b.WriteString(text)
+			stack = append(stack, isText)
+		case xml.EndElement:
+			text, _ := handler(t.Name, false)
+			b.WriteString(text)
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if len(stack) > 0 && stack[len(stack)-1] {
+				b.Write(t)
+			}
+		}
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,151 @@
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+
+	// Decoders of supported formats
+	_ "image/gif"
+	_ "image/png"
+)
+
+// MaxPixels limits size of decoded image, so a small file with huge
+// dimensions can't exhaust memory
+const MaxPixels = 50 * 1000 * 1000
+
+const jpegQuality = 80
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrImageTooLarge     = errors.New("image dimensions are too large")
+)
+
+// Supported reports whether thumbnail can be made of content type
+func Supported(contentType string) bool {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+		return true
+	}
+
+	return false
+}
+
+// Config returns dimensions of image without decoding it
+func Config(r io.Reader) (width, height int, err error) {
+	config, _, err := image.DecodeConfig(r)
+	if errors.Is(err, image.ErrFormat) {
+		return 0, 0, ErrUnsupportedFormat
+	}
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return config.Width, config.Height, nil
+}
+
+// Make decodes image of r and encodes it as JPEG scaled down to fit into
+// size x size box keeping proportions. Image smaller than the box is not
+// enlarged, transparent areas are filled with white.
+func Make(r io.ReadSeeker, size int) (*bytes.Buffer, error) {
+	width, height, err := Config(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if width*height > MaxPixels {
+		return nil, ErrImageTooLarge
+	}
+
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	dst := scale(src, size)
+
+	var buf bytes.Buffer
+
+	err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: jpegQuality})
+	if err != nil {
+		return nil, err
+	}
+
+	return &buf, nil
+}
+
+// scale averages source pixels covered by every pixel of the result, it
+// is good enough for downscaling photos and schemes
+func scale(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	dstWidth, dstHeight := width, height
+	if width > size || height > size {
+		if width >= height {
+			dstWidth, dstHeight = size, height*size/width
+		} else {
+			dstWidth, dstHeight = width*size/height, size
+		}
+	}
+
+	if dstWidth < 1 {
+		dstWidth = 1
+	}
+
+	if dstHeight < 1 {
+		dstHeight = 1
+	}
+
+	flat := image.NewRGBA(bounds)
+	draw.Draw(flat, bounds, image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, bounds, src, bounds.Min, draw.Over)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+
+	for y := 0; y < dstHeight; y++ {
+		y0, y1 := y*height/dstHeight, (y+1)*height/dstHeight
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+
+		for x := 0; x < dstWidth; x++ {
+			x0, x1 := x*width/dstWidth, (x+1)*width/dstWidth
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, count int
+
+			for sy := y0; sy < y1; sy++ {
+				offset := flat.PixOffset(bounds.Min.X+x0, bounds.Min.Y+sy)
+				for sx := x0; sx < x1; sx++ {
+					r += int(flat.Pix[offset])
+					g += int(flat.Pix[offset+1])
+					b += int(flat.Pix[offset+2])
+					count++
+					offset += 4
+				}
+			}
+
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / count),
+				G: uint8(g / count),
+				B: uint8(b / count),
+				A: 0xff,
+			})
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,225 @@
+// Package xlsx writes single sheet Office Open XML spreadsheets. Rows are
+// streamed into the archive one by one, so a sheet of any size is written
+// without keeping it in memory.
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// DateTimeLayout is a format of time cells, time is written as text so
+// the sheet doesn't depend on styles and time zone of spreadsheet editor
+const DateTimeLayout = "02.01.2006 15:04"
+
+var ErrClosed = errors.New("xlsx writer is closed")
+
+const contentTypes = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`</Types>`
+
+const rootRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const workbook = xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+	`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+	`<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets>` +
+	`</workbook>`
+
+const workbookRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+	`</Relationships>`
+
+// styles has the default style and bold style of header, header cells
+// refer to it as s="1"
+const styles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+	`</styleSheet>`
+
+const (
+	sheetBegin = xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>` +
+		`<sheetData>`
+	sheetEnd = `</sheetData></worksheet>`
+)
+
+// Writer writes rows of the sheet, the first row is a header
+type Writer struct {
+	zip   *zip.Writer
+	sheet io.Writer
+	rows  int
+}
+
+// NewWriter writes parts of workbook preceding the sheet and opens the
+// sheet for rows
+func NewWriter(w io.Writer, sheetName string) (*Writer, error) {
+	zw := zip.NewWriter(w)
+
+	var name xmlText
+
+	err := name.escape(sheetName)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", contentTypes},
+		{"_rels/.rels", rootRels},
+		{"xl/workbook.xml", fmt.Sprintf(workbook, name)},
+		{"xl/_rels/workbook.xml.rels", workbookRels},
+		{"xl/styles.xml", styles},
+	}
+
+	for _, part := range parts {
+		pw, err := zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = io.WriteString(pw, part.content)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = io.WriteString(sheet, sheetBegin)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Writer{zip: zw, sheet: sheet}, nil
+}
+
+// WriteRow writes a row of cells. Integers and floats are written as
+// numbers, time as text in DateTimeLayout, nil as an empty cell and
+// anything else as text.
+func (w *Writer) WriteRow(cells ...interface{}) error {
+	if w.sheet == nil {
+		return ErrClosed
+	}
+
+	w.rows++
+
+	style := ""
+	if w.rows == 1 {
+		style = ` s="1"`
+	}
+
+	var row xmlText
+
+	row = append(row, `<row r="`+strconv.Itoa(w.rows)+`">`...)
+
+	for i, cell := range cells {
+		ref := columnName(i) + strconv.Itoa(w.rows)
+
+		switch value := cell.(type) {
+		case nil:
+			continue
+		case int:
+			row = append(row, `<c r="`+ref+`"`+style+`><v>`+strconv.Itoa(value)+`</v></c>`...)
+		case int64:
+			row = append(row, `<c r="`+ref+`"`+style+`><v>`+strconv.FormatInt(value, 10)+`</v></c>`...)
+		case float64:
+			row = append(row, `<c r="`+ref+`"`+style+`><v>`+strconv.FormatFloat(value, 'f', -1, 64)+`</v></c>`...)
+		default:
+			text := ""
+
+			switch value := value.(type) {
+			case string:
+				text = value
+			case time.Time:
+				text = value.Format(DateTimeLayout)
+			default:
+				text = fmt.Sprint(value)
+			}
+
+			if text == "" {
+				continue
+			}
+
+			row = append(row, `<c r="`+ref+`" t="inlineStr"`+style+`><is><t xml:space="preserve">`...)
+
+			err := row.escape(text)
+			if err != nil {
+				return err
+			}
+
+			row = append(row, `</t></is></c>`...)
+		}
+	}
+
+	row = append(row, `</row>`...)
+
+	_, err := w.sheet.Write(row)
+
+	return err
+}
+
+// Flush writes buffered data to the underlying writer
+func (w *Writer) Flush() error {
+	return w.zip.Flush()
+}
+
+// Close completes the sheet and the archive, the underlying writer is not
+// closed
+func (w *Writer) Close() error {
+	if w.sheet == nil {
+		return ErrClosed
+	}
+
+	_, err := io.WriteString(w.sheet, sheetEnd)
+	if err != nil {
+		return err
+	}
+
+	w.sheet = nil
+
+	return w.zip.Close()
+}
+
+// columnName converts zero based column index to letters, 0 is A and 26
+// is AA
+func columnName(index int) string {
+	name := ""
+
+	for index++; index > 0; index = (index - 1) / 26 {
+		name = string(rune('A'+(index-1)%26)) + name
+	}
+
+	return name
+}
+
+// xmlText is a buffer of XML markup
+type xmlText []byte
+
+func (t *xmlText) Write(p []byte) (int, error) {
+	*t = append(*t, p...)
+	return len(p), nil
+}
+
+// escape appends text escaped for XML, characters not allowed in XML are
+// replaced
+func (t *xmlText) escape(text string) error {
+	return xml.EscapeText(t, []byte(text))
+}
@@ -1,6 +1,10 @@
 package models
 
-import "github.com/sqsinformatique/rosseti-innovation-back/types"
+import (
+	"time"
+
+	"github.com/sqsinformatique/rosseti-innovation-back/types"
+)
 
 // CentrifugoIntrospection contains an access token's session data as specified by Centrifugo documentation, see:
 // https://centrifugal.github.io/centrifugo/server/proxy/#connect-proxy
@@ -43,14 +47,101 @@ type CentrifugoIntrospectionResult struct {
 	Result interface{} `json:"result"`
 }
 
-type CentrifugoParams struct {
-	Channel string
-	Data    interface{}
+// CentrifugoError is an error replied to centrifugo proxy request, see:
+// https://centrifugal.github.io/centrifugo/server/proxy/#subscribe-proxy
+type CentrifugoError struct {
+	Code    uint32 `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *CentrifugoError) Error() string {
+	return e.Message
+}
+
+// CentrifugoSubscribeRequest is sent by centrifugo when client subscribes
+// to channel of namespace with enabled subscribe proxy
+type CentrifugoSubscribeRequest struct {
+	Client    string `json:"client"`
+	Transport string `json:"transport"`
+	Protocol  string `json:"protocol"`
+	Encoding  string `json:"encoding"`
+	User      string `json:"user"`
+	Channel   string `json:"channel"`
+	Token     string `json:"token,omitempty"`
+}
+
+// CentrifugoSubscribeResult allows subscription, Info is attached to
+// client presence in channel
+type CentrifugoSubscribeResult struct {
+	Info interface{} `json:"info,omitempty"`
+}
+
+// CentrifugoRefreshRequest is sent by centrifugo when connection expires
+type CentrifugoRefreshRequest struct {
+	Client    string `json:"client"`
+	Transport string `json:"transport"`
+	Protocol  string `json:"protocol"`
+	Encoding  string `json:"encoding"`
+	User      string `json:"user"`
 }
 
-type CentrifugoAPIRequest struct {
-	Method string
-	Params *CentrifugoParams
+// CentrifugoRefreshResult either extends connection till ExpireAt or
+// tells centrifugo to close expired connection, see:
+// https://centrifugal.github.io/centrifugo/server/proxy/#refresh-proxy
+type CentrifugoRefreshResult struct {
+	Expired  bool  `json:"expired,omitempty"`
+	ExpireAt int64 `json:"expire_at,omitempty"`
+}
+
+// CentrifugoConnInfo is attached to connection authenticated by token
+type CentrifugoConnInfo struct {
+	Name     string `json:"name"`
+	Position string `json:"position,omitempty"`
+	Role     string `json:"role"`
+}
+
+// CentrifugoToken is a signed connection or subscription token valid till
+// ExpireAt unix time
+type CentrifugoToken struct {
+	Channel  string `json:"channel,omitempty"`
+	Token    string `json:"token"`
+	ExpireAt int64  `json:"expire_at"`
+}
+
+// CentrifugoSubscriptionTokenRequest is sent by centrifuge-js to get tokens
+// of private channels for client connection
+type CentrifugoSubscriptionTokenRequest struct {
+	Client   string   `json:"client"`
+	Channels []string `json:"channels"`
+}
+
+// CentrifugoSubscriptionTokens is a reply to subscription tokens request in
+// the format expected by centrifuge-js
+type CentrifugoSubscriptionTokens struct {
+	Channels []*CentrifugoToken `json:"channels"`
+}
+
+// PresenceUser is a user online in channel, Clients counts connections of
+// the user subscribed to the channel
+type PresenceUser struct {
+	ID       int    `json:"id" db:"id"`
+	Name     string `json:"name" db:"name"`
+	Position string `json:"position" db:"position"`
+	Clients  int    `json:"clients" db:"-"`
+}
+
+// ThemePresence lists users reading theme discussion right now
+type ThemePresence struct {
+	ThemeID    int             `json:"theme_id"`
+	NumUsers   int             `json:"num_users"`
+	NumClients int             `json:"num_clients"`
+	Users      []*PresenceUser `json:"users"`
+}
+
+// CentrifugoProxyReply contains either result or error of proxy request
+type CentrifugoProxyReply struct {
+	Result interface{}      `json:"result,omitempty"`
+	Error  *CentrifugoError `json:"error,omitempty"`
 }
 
 type Theme struct {
@@ -60,7 +151,15 @@ type Theme struct {
 	Title       string         `json:"title" db:"title"`
 	AuthorID    int            `json:"author_id" db:"author_id"`
 	LikeCounter int            `json:"like_counter" db:"like_counter"`
-	Meta        types.NullMeta `json:"meta" db:"meta"`
+	LikedByMe   bool           `json:"liked_by_me,omitempty" db:"-"`
+	Unread      int            `json:"unread,omitempty" db:"-"`
+	Online      int            `json:"online" db:"-"`
+	Pinned      bool           `json:"pinned" db:"pinned"`
+	ClosedAt    types.NullTime `json:"closed_at" db:"closed_at"`
+	ArchivedAt  types.NullTime `json:"archived_at" db:"archived_at"`
+	// InnovationID is a draft the theme is promoted to
+	InnovationID *int           `json:"innovation_id,omitempty" db:"innovation_id"`
+	Meta         types.NullMeta `json:"meta" db:"meta"`
 	Timestamp
 }
 
@@ -78,10 +177,45 @@ func (u *Theme) SQLParamsRequest() []string {
 	}
 }
 
+// Removed tells whether theme is deleted or archived, removed theme isn't
+// listed and searched
+func (u *Theme) Removed() bool {
+	return u.DeletedAt.Valid || u.ArchivedAt.Valid
+}
+
+// ThemeUpdate is a body of theme update request, empty fields are not
+// changed
+type ThemeUpdate struct {
+	Direction int             `json:"direction"`
+	Title     string          `json:"title"`
+	Tags      *string         `json:"tags"`
+	Meta      *types.NullMeta `json:"meta"`
+}
+
+// ActiveTheme is a theme with activity of its chat
+type ActiveTheme struct {
+	Theme
+	LastMessageAt time.Time `json:"last_message_at" db:"last_message_at"`
+	MessageCount  int       `json:"message_count" db:"message_count"`
+	Participants  int       `json:"participants" db:"participants"`
+}
+
+// ActiveThemes is a page of themes ordered by the last message time
+type ActiveThemes struct {
+	Themes []*ActiveTheme `json:"themes"`
+	Total  int            `json:"total"`
+	Limit  int            `json:"limit"`
+	Offset int            `json:"offset"`
+}
+
+// Direction groups themes, Meta may keep "icon" of direction. Directions
+// are listed in ascending Position order.
 type Direction struct {
-	ID    int            `json:"id"`
-	Title string         `json:"title"`
-	Meta  types.NullMeta `json:"meta" db:"meta"`
+	ID         int            `json:"id"`
+	Title      string         `json:"title"`
+	Position   int            `json:"position" db:"position"`
+	ArchivedAt types.NullTime `json:"archived_at" db:"archived_at"`
+	Meta       types.NullMeta `json:"meta" db:"meta"`
 	Timestamp
 }
 
@@ -89,6 +223,8 @@ func (u *Direction) SQLParamsRequest() []string {
 	return []string{
 		"id",
 		"title",
+		"position",
+		"archived_at",
 		"meta",
 		"created_at",
 		"updated_at",
@@ -96,6 +232,11 @@ func (u *Direction) SQLParamsRequest() []string {
 	}
 }
 
+// DirectionOrder lists direction IDs in new display order
+type DirectionOrder struct {
+	IDs []int `json:"ids"`
+}
+
 type DirectionDetailed struct {
 	Themes []Theme `json:"themes"`
 	Direction
@@ -2,16 +2,157 @@ package models
 
 import "time"
 
+// Message is stored as a separate document, ID is a sequence number
+// inside the channel. Deleted message is kept as a tombstone without text,
+// attachments, edits and reactions, so replies to it still resolve. Text
+// and attachments of message hidden by moderator are kept but not shown to
+// clients.
 type Message struct {
-	ID        int       `bson:"id"`
-	Text      string    `bson:"text"`
-	Sender    int       `bson:"sender"`
-	TimeStamp time.Time `bson:"timestamp"`
+	ChannelID   int              `json:"channel_id" bson:"channel_id"`
+	ID          int              `json:"id" bson:"id"`
+	Text        string           `json:"text" bson:"text"`
+	Sender      int              `json:"sender" bson:"sender"`
+	TimeStamp   time.Time        `json:"timestamp" bson:"timestamp"`
+	ReplyTo     int              `json:"reply_to,omitempty" bson:"reply_to,omitempty"`
+	EditedAt    *time.Time       `json:"edited_at,omitempty" bson:"edited_at,omitempty"`
+	Edits       []*MessageEdit   `json:"edits,omitempty" bson:"edits,omitempty"`
+	Deleted     bool             `json:"deleted,omitempty" bson:"deleted,omitempty"`
+	DeletedAt   *time.Time       `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
+	DeletedBy   int              `json:"deleted_by,omitempty" bson:"deleted_by,omitempty"`
+	Reactions   map[string][]int `json:"reactions,omitempty" bson:"reactions,omitempty"`
+	Hidden      bool             `json:"hidden,omitempty" bson:"hidden,omitempty"`
+	HiddenAt    *time.Time       `json:"hidden_at,omitempty" bson:"hidden_at,omitempty"`
+	HiddenBy    int              `json:"hidden_by,omitempty" bson:"hidden_by,omitempty"`
+	Mentions    []int            `json:"mentions,omitempty" bson:"mentions,omitempty"`
+	Entities    []*MessageEntity `json:"entities,omitempty" bson:"entities,omitempty"`
+	Attachments []*Attachment    `json:"attachments,omitempty" bson:"attachments,omitempty"`
 }
 
+// Attachment is a file of chat message stored in GridFS, images get JPEG
+// thumbnail. Files are downloaded by ID through chat of the message.
+type Attachment struct {
+	ID           string `json:"id" bson:"id"`
+	FileName     string `json:"file_name" bson:"file_name"`
+	ContentType  string `json:"content_type" bson:"content_type"`
+	Size         int64  `json:"size" bson:"size"`
+	Width        int    `json:"width,omitempty" bson:"width,omitempty"`
+	Height       int    `json:"height,omitempty" bson:"height,omitempty"`
+	HasThumbnail bool   `json:"has_thumbnail,omitempty" bson:"has_thumbnail,omitempty"`
+}
+
+// Message entity types
+const (
+	MessageEntityMention = "mention"
+)
+
+// MessageEntity marks part of message text for client rendering, Offset and
+// Length are counted in characters. Mention entity links "@handle" to
+// profile of UserID.
+type MessageEntity struct {
+	Type   string `json:"type" bson:"type"`
+	Offset int    `json:"offset" bson:"offset"`
+	Length int    `json:"length" bson:"length"`
+	UserID int    `json:"user_id,omitempty" bson:"user_id,omitempty"`
+	Name   string `json:"name,omitempty" bson:"name,omitempty"`
+}
+
+// MessageEdit is a previous version of edited message text
+type MessageEdit struct {
+	Text      string    `json:"text" bson:"text"`
+	TimeStamp time.Time `json:"timestamp" bson:"timestamp"`
+}
+
+// MessageUpdate is a body of message edit request
+type MessageUpdate struct {
+	Message string `json:"message"`
+}
+
+// Chat events published to channel, the message is sent in its state after
+// the change
+const (
+	ChatEventCreated  = "created"
+	ChatEventEdited   = "edited"
+	ChatEventDeleted  = "deleted"
+	ChatEventReaction = "reaction"
+	ChatEventUnread   = "unread"
+	ChatEventHidden   = "hidden"
+	ChatEventUnhidden = "unhidden"
+)
+
+// ChatEvent is published to channel on every message change
+type ChatEvent struct {
+	Event   string   `json:"event"`
+	Message *Message `json:"message"`
+}
+
+// ChatChannel keeps channel attributes and the last allocated message ID
 type ChatChannel struct {
-	ID        int        `bson:"id"`
-	Name      string     `bson:"name"`
-	Messages  []*Message `bson:"messages"`
-	LastMsgID int        `bson:"lastid"`
+	ID        int    `json:"id" bson:"id"`
+	Name      string `json:"name" bson:"name"`
+	Type      string `json:"type" bson:"type"`
+	LastMsgID int    `json:"last_id" bson:"lastid"`
+}
+
+// ChatHistory is a page of channel messages in ascending order. Pass
+// PrevCursor as before and NextCursor as after to get adjacent pages.
+type ChatHistory struct {
+	Channel    *ChatChannel `json:"channel"`
+	Messages   []*Message   `json:"messages"`
+	PrevCursor int          `json:"prev_cursor"`
+	NextCursor int          `json:"next_cursor"`
+	HasPrev    bool         `json:"has_prev"`
+	HasNext    bool         `json:"has_next"`
+}
+
+// ChatRead is a read receipt, messages up to LastRead are read by user.
+// Unread counts messages of other users after LastRead.
+type ChatRead struct {
+	UserID    int       `json:"user_id" bson:"user_id"`
+	ChannelID int       `json:"channel_id" bson:"channel_id"`
+	LastRead  int       `json:"last_read" bson:"last_read"`
+	Unread    int       `json:"unread" bson:"-"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// ChatReadRequest moves read pointer to message, the last message of chat
+// is used if MessageID is zero
+type ChatReadRequest struct {
+	MessageID int `json:"message_id"`
+}
+
+// UnreadEvent is published to personal channel of user when count of
+// unread messages in chat is changed
+type UnreadEvent struct {
+	Event string `json:"event"`
+	*ChatRead
+}
+
+// Chat export formats
+const (
+	ChatExportJSON = "json"
+	ChatExportCSV  = "csv"
+	ChatExportHTML = "html"
+)
+
+// ChatExportMessage is a message of chat transcript with resolved sender
+// name and download links of attachments
+type ChatExportMessage struct {
+	ID          int                     `json:"id"`
+	TimeStamp   time.Time               `json:"timestamp"`
+	Sender      int                     `json:"sender"`
+	SenderName  string                  `json:"sender_name"`
+	Text        string                  `json:"text"`
+	ReplyTo     int                     `json:"reply_to,omitempty"`
+	EditedAt    *time.Time              `json:"edited_at,omitempty"`
+	Deleted     bool                    `json:"deleted,omitempty"`
+	Hidden      bool                    `json:"hidden,omitempty"`
+	Attachments []*ChatExportAttachment `json:"attachments,omitempty"`
+}
+
+// ChatExportAttachment is a file of exported message, URL is relative to
+// public API host
+type ChatExportAttachment struct {
+	FileName string `json:"file_name"`
+	Size     int64  `json:"size"`
+	URL      string `json:"url"`
 }
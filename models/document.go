@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// Innovation document kinds
+const (
+	DocumentApplication = "application"
+	DocumentCertificate = "certificate"
+)
+
+// InnovationSignature is an electronic signature of innovation application
+// made by author or co-author with profile key
+type InnovationSignature struct {
+	ID           int       `json:"id" db:"id"`
+	InnovationID int       `json:"innovation_id" db:"innovation_id"`
+	UserID       int       `json:"user_id" db:"user_id"`
+	Signature    string    `json:"signature" db:"signature"`
+	DataHash     string    `json:"data_hash" db:"data_hash"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	// Valid is set if signature matches current data of application
+	Valid bool `json:"valid" db:"-"`
+}
+
+// InnovationDocument is an issued document, it is verified by Code
+type InnovationDocument struct {
+	ID           int       `json:"id" db:"id"`
+	InnovationID int       `json:"innovation_id" db:"innovation_id"`
+	Kind         string    `json:"kind" db:"kind"`
+	Code         string    `json:"code" db:"code"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+// DocumentVerification is a public information about issued document
+type DocumentVerification struct {
+	Kind         string                   `json:"kind"`
+	InnovationID int                      `json:"innovation_id"`
+	Title        string                   `json:"title"`
+	State        string                   `json:"state"`
+	Authors      []string                 `json:"authors"`
+	IssuedAt     time.Time                `json:"issued_at"`
+	Signatures   []*SignatureVerification `json:"signatures"`
+}
+
+// SignatureVerification tells whether signature of author matches current
+// data of application
+type SignatureVerification struct {
+	Name     string    `json:"name"`
+	SignedAt time.Time `json:"signed_at"`
+	Valid    bool      `json:"valid"`
+}
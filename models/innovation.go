@@ -1,6 +1,10 @@
 package models
 
-import "github.com/sqsinformatique/rosseti-innovation-back/types"
+import (
+	"time"
+
+	"github.com/sqsinformatique/rosseti-innovation-back/types"
+)
 
 type Innovation struct {
 	ID          int            `json:"id" db:"id"`
@@ -9,8 +13,16 @@ type Innovation struct {
 	Tags        string         `json:"tags" db:"tags"`
 	Problem     string         `json:"problem" db:"problem"`
 	Description string         `json:"descriptions" db:"descriptions"`
+	Effect      string         `json:"effect" db:"effect"`
 	State       types.Status   `json:"state" db:"state"`
+	LikeCounter int            `json:"like_counter" db:"like_counter"`
+	LikedByMe   bool           `json:"liked_by_me,omitempty" db:"-"`
 	Meta        types.NullMeta `json:"meta" db:"meta"`
+	// Direction is nil for innovation without direction
+	Direction *int `json:"direction" db:"direction"`
+	// ThemeID is a discussion the innovation is promoted from, it is set on
+	// promotion only
+	ThemeID *int `json:"theme_id,omitempty" db:"theme_id"`
 	Timestamp
 }
 
@@ -24,6 +36,7 @@ func (u *Innovation) SQLParamsRequest() []string {
 		"effect",
 		"state",
 		"meta",
+		"direction",
 		"created_at",
 		"updated_at",
 		"deleted_at",
@@ -83,6 +96,7 @@ type InnovationFiles struct {
 	ID       int    `json:"id" db:"id"`
 	FileID   string `json:"file_id" db:"file_id"`
 	FileName string `json:"file_name" db:"file_name"`
+	Content  string `json:"-" db:"content"`
 	Timestamp
 }
 
@@ -91,8 +105,76 @@ func (u *InnovationFiles) SQLParamsRequest() []string {
 		"id",
 		"file_id",
 		"file_name",
+		"content",
 		"created_at",
 		"updated_at",
 		"deleted_at",
 	}
 }
+
+// InnovationDuplicate is a moderator decision that DuplicateID repeats InnovationID
+type InnovationDuplicate struct {
+	ID           int            `json:"id" db:"id"`
+	InnovationID int            `json:"innovation_id" db:"innovation_id"`
+	DuplicateID  int            `json:"duplicate_id" db:"duplicate_id"`
+	ModeratorID  int            `json:"moderator_id" db:"moderator_id"`
+	Score        float64        `json:"score" db:"score"`
+	Meta         types.NullMeta `json:"meta" db:"meta"`
+	Timestamp
+}
+
+func (u *InnovationDuplicate) SQLParamsRequest() []string {
+	return []string{
+		"innovation_id",
+		"duplicate_id",
+		"moderator_id",
+		"score",
+		"meta",
+		"created_at",
+		"updated_at",
+		"deleted_at",
+	}
+}
+
+// ThemeParticipant is a user who wrote to theme discussion, Messages counts
+// messages which are not deleted or hidden
+type ThemeParticipant struct {
+	UserID   int    `json:"user_id" bson:"_id"`
+	Name     string `json:"name" bson:"-"`
+	Messages int    `json:"messages" bson:"messages"`
+}
+
+// ThemePromotion is a draft innovation created from theme discussion,
+// the most active participants of discussion are linked as co-authors and
+// author may remove them
+type ThemePromotion struct {
+	Innovation         *Innovation         `json:"innovation"`
+	Theme              *Theme              `json:"theme"`
+	SuggestedCoAuthors []*ThemeParticipant `json:"suggested_co_authors"`
+	Transcript         string              `json:"transcript,omitempty"`
+}
+
+// Registry export formats
+const (
+	RegistryExportCSV  = "csv"
+	RegistryExportXLSX = "xlsx"
+)
+
+// RegistryRow is an innovation of registry export with resolved names of
+// author, co-authors and direction
+type RegistryRow struct {
+	ID             int            `json:"id" db:"id"`
+	Title          string         `json:"title" db:"title"`
+	State          string         `json:"state" db:"state"`
+	Direction      string         `json:"direction" db:"direction"`
+	AuthorID       int            `json:"author_id" db:"author_id"`
+	Author         string         `json:"author" db:"author"`
+	AuthorPosition string         `json:"author_position" db:"author_position"`
+	Company        string         `json:"company" db:"company"`
+	CoAuthors      string         `json:"co_authors" db:"co_authors"`
+	CreatedAt      time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at" db:"updated_at"`
+	RecognizedAt   types.NullTime `json:"recognized_at" db:"recognized_at"`
+	Effect         string         `json:"effect" db:"effect"`
+	Likes          int            `json:"likes" db:"likes"`
+}
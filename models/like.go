@@ -0,0 +1,15 @@
+package models
+
+// Targets of likes, every target table has like_counter column
+const (
+	LikeTargetTheme      = "theme"
+	LikeTargetInnovation = "innovation"
+)
+
+// LikeState is a likes counter of target and whether current user likes it
+type LikeState struct {
+	TargetType  string `json:"target_type" db:"-"`
+	TargetID    int    `json:"target_id" db:"id"`
+	LikeCounter int    `json:"like_counter" db:"like_counter"`
+	Liked       bool   `json:"liked" db:"liked"`
+}
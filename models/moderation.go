@@ -0,0 +1,99 @@
+package models
+
+import (
+	"time"
+
+	"github.com/sqsinformatique/rosseti-innovation-back/types"
+)
+
+// Sanction kinds, muted user reads theme chat but can't post to it, banned
+// user can't subscribe to it either
+const (
+	SanctionMute = "mute"
+	SanctionBan  = "ban"
+)
+
+// Stop word actions, message with rejecting word is not saved, masked word
+// is replaced with asterisks
+const (
+	StopWordReject = "reject"
+	StopWordMask   = "mask"
+)
+
+// Moderation actions recorded in moderation log
+const (
+	ModerationHideMessage    = "hide_message"
+	ModerationUnhideMessage  = "unhide_message"
+	ModerationDeleteMessage  = "delete_message"
+	ModerationSanction       = "sanction"
+	ModerationRevokeSanction = "revoke_sanction"
+	ModerationLockTheme      = "lock_theme"
+	ModerationUnlockTheme    = "unlock_theme"
+	ModerationAddStopWord    = "add_stop_word"
+	ModerationRemoveStopWord = "remove_stop_word"
+)
+
+// Sanction restricts user in theme chat until Until
+type Sanction struct {
+	ID        int            `json:"id" db:"id"`
+	ThemeID   int            `json:"theme_id" db:"theme_id"`
+	UserID    int            `json:"user_id" db:"user_id"`
+	Kind      string         `json:"kind" db:"kind"`
+	Reason    string         `json:"reason" db:"reason"`
+	Until     time.Time      `json:"until" db:"until"`
+	CreatedBy int            `json:"created_by" db:"created_by"`
+	CreatedAt time.Time      `json:"created_at" db:"created_at"`
+	RevokedAt types.NullTime `json:"revoked_at" db:"revoked_at"`
+}
+
+// SanctionRequest is a body of sanction request, Duration is in minutes
+type SanctionRequest struct {
+	UserID   int    `json:"user_id"`
+	Kind     string `json:"kind"`
+	Duration int    `json:"duration"`
+	Reason   string `json:"reason"`
+}
+
+// ThemeLock makes theme chat read-only for everyone except moderators
+type ThemeLock struct {
+	ThemeID   int       `json:"theme_id" db:"theme_id"`
+	Reason    string    `json:"reason" db:"reason"`
+	LockedBy  int       `json:"locked_by" db:"locked_by"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// ThemeLockRequest is a body of theme lock request
+type ThemeLockRequest struct {
+	Reason string `json:"reason"`
+}
+
+// StopWord is checked in every chat message before it is saved
+type StopWord struct {
+	ID        int       `json:"id" db:"id"`
+	Word      string    `json:"word" db:"word"`
+	Action    string    `json:"action" db:"action"`
+	CreatedBy int       `json:"created_by" db:"created_by"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// ModerationLogEntry is a moderation action, zero IDs mean the action isn't
+// related to chat, user or message. Details keep data changed by action,
+// e.g. text of hidden message.
+type ModerationLogEntry struct {
+	ID          int            `json:"id" db:"id"`
+	ModeratorID int            `json:"moderator_id" db:"moderator_id"`
+	Action      string         `json:"action" db:"action"`
+	ChatID      int            `json:"chat_id,omitempty" db:"chat_id"`
+	UserID      int            `json:"user_id,omitempty" db:"user_id"`
+	MessageID   int            `json:"message_id,omitempty" db:"message_id"`
+	Details     types.NullMeta `json:"details" db:"details"`
+	CreatedAt   time.Time      `json:"created_at" db:"created_at"`
+}
+
+// ModerationLog is a page of moderation log in descending order, pass
+// NextCursor as before to get the next page
+type ModerationLog struct {
+	Entries    []*ModerationLogEntry `json:"entries"`
+	NextCursor int                   `json:"next_cursor"`
+	HasNext    bool                  `json:"has_next"`
+}
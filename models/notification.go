@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"github.com/sqsinformatique/rosseti-innovation-back/types"
+)
+
+// Notification types, user may disable any of them in preferences
+const (
+	NotificationInnovationStatus  = "innovation_status"
+	NotificationExpertReview      = "expert_review"
+	NotificationInnovationComment = "innovation_comment"
+	NotificationChatReply         = "chat_reply"
+	NotificationChatMention       = "chat_mention"
+	NotificationCoAuthor          = "co_author"
+)
+
+// NotificationTypes lists all known notification types
+var NotificationTypes = []string{
+	NotificationInnovationStatus,
+	NotificationExpertReview,
+	NotificationInnovationComment,
+	NotificationChatReply,
+	NotificationChatMention,
+	NotificationCoAuthor,
+}
+
+// NotificationEvent is published to personal channel of recipient
+const NotificationEvent = "notification"
+
+// Notification is a message in user's inbox, Data holds ids of related
+// entities for client links
+type Notification struct {
+	ID        int            `json:"id" db:"id"`
+	UserID    int            `json:"user_id" db:"user_id"`
+	Type      string         `json:"type" db:"type"`
+	Title     string         `json:"title" db:"title"`
+	Data      types.NullMeta `json:"data" db:"data"`
+	ReadAt    types.NullTime `json:"read_at" db:"read_at"`
+	CreatedAt time.Time      `json:"created_at" db:"created_at"`
+}
+
+// Notifications is a page of inbox in descending order, pass NextCursor as
+// before to get the next page
+type Notifications struct {
+	Notifications []*Notification `json:"notifications"`
+	Unread        int             `json:"unread"`
+	NextCursor    int             `json:"next_cursor"`
+	HasNext       bool            `json:"has_next"`
+}
+
+// NotificationPreference enables or disables notification type for user,
+// types without stored preference are enabled
+type NotificationPreference struct {
+	Type    string `json:"type" db:"type"`
+	Enabled bool   `json:"enabled" db:"enabled"`
+}
+
+// NotificationPush is published to personal channel of recipient
+type NotificationPush struct {
+	Event        string        `json:"event"`
+	Notification *Notification `json:"notification"`
+}
@@ -3,5 +3,9 @@ package models
 type Publish struct {
 	Channel string `json:"channel"`
 	Message string `json:"message"`
+	// Type is ignored, chat type is defined by channel namespace
 	Type    string `json:"type"`
+	ReplyTo int    `json:"reply_to,omitempty"`
+	// Attachments are IDs of files uploaded to the channel by sender
+	Attachments []string `json:"attachments,omitempty"`
 }
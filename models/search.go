@@ -1,6 +1,51 @@
 package models
 
+import "encoding/json"
+
 type Search struct {
 	Value     string                 `json:"value"`
 	ExtFilter map[string]interface{} `json:"ext_filter"`
 }
+
+const (
+	SuggestInnovation = "innovation"
+	SuggestTag        = "tag"
+	SuggestTheme      = "theme"
+	SuggestProfile    = "profile"
+)
+
+// Suggestion is a typed search-as-you-type result
+type Suggestion struct {
+	Type      string  `json:"type"`
+	RefID     int     `json:"ref_id"`
+	Text      string  `json:"text"`
+	Position  string  `json:"position,omitempty"`
+	Company   string  `json:"company,omitempty"`
+	Highlight string  `json:"highlight,omitempty"`
+	Score     float64 `json:"score"`
+}
+
+const (
+	SearchInnovation = "innovation"
+	SearchTheme      = "theme"
+	SearchMessage    = "message"
+	SearchProfile    = "profile"
+)
+
+// SearchHit is a single document found by global search
+type SearchHit struct {
+	ID         string              `json:"id"`
+	Score      float64             `json:"score"`
+	Source     json.RawMessage     `json:"source"`
+	Highlights map[string][]string `json:"highlights,omitempty"`
+}
+
+// SearchGroup contains hits of one entity type ordered by relevance
+type SearchGroup struct {
+	Total    int          `json:"total"`
+	MaxScore float64      `json:"max_score"`
+	Hits     []*SearchHit `json:"hits"`
+}
+
+// GlobalSearch is a global search result grouped by entity type
+type GlobalSearch map[string]*SearchGroup
@@ -0,0 +1,78 @@
+package models
+
+import "time"
+
+// StatisticsFilter limits innovations counted in statistics by creation
+// date and company of author. Empty fields are not applied, To is
+// exclusive.
+type StatisticsFilter struct {
+	From    *time.Time `json:"from,omitempty"`
+	To      *time.Time `json:"to,omitempty"`
+	Company string     `json:"company,omitempty"`
+}
+
+// StatusCount is a count of innovations in state
+type StatusCount struct {
+	State string `json:"state" db:"state"`
+	Count int    `json:"count" db:"count"`
+}
+
+// DirectionCount is a count of innovations of direction, innovations
+// without direction have zero DirectionID
+type DirectionCount struct {
+	DirectionID int    `json:"direction_id" db:"direction_id"`
+	Title       string `json:"title" db:"title"`
+	Count       int    `json:"count" db:"count"`
+	Recognized  int    `json:"recognized" db:"recognized"`
+}
+
+// CompanyCount is a count of innovations by authors of company branch,
+// branch is taken from profile meta
+type CompanyCount struct {
+	Company    string `json:"company" db:"company"`
+	Branch     string `json:"branch" db:"branch"`
+	Count      int    `json:"count" db:"count"`
+	Recognized int    `json:"recognized" db:"recognized"`
+}
+
+// MonthlyCount is a count of innovations submitted and recognized in month
+type MonthlyCount struct {
+	Month      time.Time `json:"month" db:"month"`
+	Submitted  int       `json:"submitted" db:"submitted"`
+	Recognized int       `json:"recognized" db:"recognized"`
+}
+
+// ExpertiseStatistics describes expertise outcome. Time in expertise is
+// in hours, an ongoing expertise counts until now. RecognitionRate is a
+// share of recognized among recognized and revoked innovations.
+type ExpertiseStatistics struct {
+	Expertised        int     `json:"expertised" db:"expertised"`
+	InExpertise       int     `json:"in_expertise" db:"in_expertise"`
+	AvgExpertiseHours float64 `json:"avg_expertise_hours" db:"avg_expertise_hours"`
+	Recognized        int     `json:"recognized" db:"recognized"`
+	Revoked           int     `json:"revoked" db:"revoked"`
+	RecognitionRate   float64 `json:"recognition_rate" db:"-"`
+}
+
+// AuthorStatistics is a count of innovations of author
+type AuthorStatistics struct {
+	AuthorID   int    `json:"author_id" db:"author_id"`
+	Name       string `json:"name" db:"name"`
+	Position   string `json:"position" db:"position"`
+	Company    string `json:"company" db:"company"`
+	Count      int    `json:"count" db:"count"`
+	Recognized int    `json:"recognized" db:"recognized"`
+	Likes      int    `json:"likes" db:"likes"`
+}
+
+// Statistics is a summary of all statistics for dashboard
+type Statistics struct {
+	Filter     *StatisticsFilter    `json:"filter"`
+	Total      int                  `json:"total"`
+	Statuses   []*StatusCount       `json:"statuses"`
+	Directions []*DirectionCount    `json:"directions"`
+	Companies  []*CompanyCount      `json:"companies"`
+	Monthly    []*MonthlyCount      `json:"monthly"`
+	Expertise  *ExpertiseStatistics `json:"expertise"`
+	TopAuthors []*AuthorStatistics  `json:"top_authors"`
+}
@@ -50,6 +50,12 @@ func (st Status) String() string {
 	return ""
 }
 
+// IsRecognized tells whether proposal is recognized, experiment and
+// replication follow recognition
+func (st Status) IsRecognized() bool {
+	return st >= Recognized && st <= ReplicationFailed
+}
+
 // UnmarshalJSON method is called by json.Unmarshal,
 // whenever it is of type Role
 func (st *Status) UnmarshalJSON(data []byte) error {